@@ -0,0 +1,263 @@
+package block
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const ADDRESS_TRANSACTIONS_LIMIT = 100
+
+// 주소가 sender나 recipient로 들어간 Transaction과 그 위치
+// body를 버린 높이의 항목은 hash와 위치만 남고 transaction과 proof는 nil
+type AddressTransaction struct {
+	height      int
+	index       int
+	blockHash   [32]byte
+	timestamp   int64
+	hash        [32]byte
+	transaction *Transaction
+	proof       *MerkleProof
+}
+
+func (at *AddressTransaction) Height() int {
+	return at.height
+}
+
+func (at *AddressTransaction) Hash() [32]byte {
+	return at.hash
+}
+
+func (at *AddressTransaction) Transaction() *Transaction {
+	return at.transaction
+}
+
+func (at *AddressTransaction) Proof() *MerkleProof {
+	return at.proof
+}
+
+func (at *AddressTransaction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Height      int          `json:"height"`
+		Index       int          `json:"index"`
+		BlockHash   string       `json:"block_hash"`
+		Hash        string       `json:"hash"`
+		Timestamp   int64        `json:"timestamp"`
+		Transaction *Transaction `json:"transaction,omitempty"`
+	}{
+		Height:      at.height,
+		Index:       at.index,
+		BlockHash:   fmt.Sprintf("%x", at.blockHash),
+		Hash:        fmt.Sprintf("%x", at.hash),
+		Timestamp:   at.timestamp,
+		Transaction: at.transaction,
+	})
+}
+
+// 주소 -> Transaction, 높이 순서로 쌓인다
+// body를 버린 Block의 항목은 hash와 위치만 남기므로 정리된 노드도 기록의 위치는 보여줄 수 있다
+type AddressIndex struct {
+	entries map[string][]*AddressTransaction
+	// Transaction hash -> 위치, 보상 Transaction처럼 내용이 같으면 여러 곳
+	hashes map[[32]byte][]*AddressTransaction
+	// 아직 transaction과 proof를 들고 있는 항목, 높이 순서
+	bodies []*AddressTransaction
+}
+
+func NewAddressIndex() *AddressIndex {
+	return &AddressIndex{
+		entries: make(map[string][]*AddressTransaction),
+		hashes:  make(map[[32]byte][]*AddressTransaction),
+	}
+}
+
+func (ai *AddressIndex) addBlock(b *Block, height int) {
+	blockHash := b.Hash()
+	levels := merkleLevels(transactionLeaves(b.transactions))
+	for i, t := range b.transactions {
+		h := t.Hash()
+		at := &AddressTransaction{
+			height:      height,
+			index:       i,
+			blockHash:   blockHash,
+			timestamp:   b.timestamp,
+			hash:        h,
+			transaction: t,
+			proof:       merkleProof(levels, i),
+		}
+		ai.bodies = append(ai.bodies, at)
+		ai.hashes[h] = append(ai.hashes[h], at)
+		ai.entries[t.senderBlockchainAddress] = append(ai.entries[t.senderBlockchainAddress], at)
+		if t.recipientBlockchainAddress != t.senderBlockchainAddress {
+			ai.entries[t.recipientBlockchainAddress] = append(ai.entries[t.recipientBlockchainAddress], at)
+		}
+	}
+}
+
+// height 이상의 Block에서 추가된 항목을 되돌린다
+func (ai *AddressIndex) removeFrom(height int) {
+	for address, entries := range ai.entries {
+		if entries = truncateFrom(entries, height); len(entries) == 0 {
+			delete(ai.entries, address)
+		} else {
+			ai.entries[address] = entries
+		}
+	}
+	for h, entries := range ai.hashes {
+		if entries = truncateFrom(entries, height); len(entries) == 0 {
+			delete(ai.hashes, h)
+		} else {
+			ai.hashes[h] = entries
+		}
+	}
+	ai.bodies = truncateFrom(ai.bodies, height)
+}
+
+// height보다 낮은 Block 항목의 transaction과 proof를 버린다
+func (ai *AddressIndex) prune(height int) {
+	n := 0
+	for n < len(ai.bodies) && ai.bodies[n].height < height {
+		ai.bodies[n].transaction = nil
+		ai.bodies[n].proof = nil
+		n++
+	}
+	if n > 0 {
+		ai.bodies = append([]*AddressTransaction{}, ai.bodies[n:]...)
+	}
+}
+
+func truncateFrom(entries []*AddressTransaction, height int) []*AddressTransaction {
+	n := len(entries)
+	for n > 0 && entries[n-1].height >= height {
+		n--
+	}
+	return entries[:n]
+}
+
+// 주소가 들어간 Transaction이 하나라도 있는지
+func (ai *AddressIndex) Has(address string) bool {
+	return len(ai.entries[address]) > 0
+}
+
+// hash가 같은 Transaction의 위치
+func (ai *AddressIndex) Lookup(hash [32]byte) []*AddressTransaction {
+	return ai.hashes[hash]
+}
+
+// fromHeight부터 toHeight까지(toHeight < 0이면 끝까지)의 Transaction
+// limit을 넘으면 Block 단위로 끊고 다음에 요청할 높이를 돌려준다, 더 없으면 0
+func (ai *AddressIndex) Transactions(address string, fromHeight int, toHeight int,
+	limit int) ([]*AddressTransaction, int) {
+	result := []*AddressTransaction{}
+	for _, at := range ai.entries[address] {
+		if at.height < fromHeight {
+			continue
+		}
+		if toHeight >= 0 && at.height > toHeight {
+			break
+		}
+		if len(result) > 0 && len(result) >= limit && at.height != result[len(result)-1].height {
+			return result, at.height
+		}
+		result = append(result, at)
+	}
+	return result, 0
+}
+
+// chain이 바뀌는 동안에는 읽지 않는다, 다른 goroutine에서는 아래의 lock을 잡는 method를 쓴다
+func (bc *Blockchain) AddressIndex() *AddressIndex {
+	return bc.addressIndex
+}
+
+// prune이 항목의 transaction과 proof를 지우므로 복사해서 돌려준다
+func copyAddressTransactions(entries []*AddressTransaction) []*AddressTransaction {
+	copied := make([]*AddressTransaction, len(entries))
+	for i, at := range entries {
+		c := *at
+		copied[i] = &c
+	}
+	return copied
+}
+
+func (bc *Blockchain) HasAddress(address string) bool {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	return bc.addressIndex.Has(address)
+}
+
+func (bc *Blockchain) LookupTransaction(hash [32]byte) []*AddressTransaction {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	return copyAddressTransactions(bc.addressIndex.Lookup(hash))
+}
+
+// AddressIndex.Transactions와 같은 범위
+func (bc *Blockchain) AddressTransactions(address string, fromHeight int, toHeight int,
+	limit int) ([]*AddressTransaction, int) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	transactions, next := bc.addressIndex.Transactions(address, fromHeight, toHeight, limit)
+	return copyAddressTransactions(transactions), next
+}
+
+// 같은 범위의 Merkle proof, body를 버린 높이는 proof를 만들 수 없으므로 뺀다
+func (bc *Blockchain) AddressProofs(address string, fromHeight int, toHeight int,
+	limit int) ([]*TransactionProof, int) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	transactions, next := bc.addressIndex.Transactions(address, fromHeight, toHeight, limit)
+	proofs := make([]*TransactionProof, 0, len(transactions))
+	for _, at := range transactions {
+		if p := at.TransactionProof(); p != nil {
+			proofs = append(proofs, p)
+		}
+	}
+	return proofs, next
+}
+
+// chain을 바꿀 때 갈라진 높이부터 index를 다시 만들고 그 높이를 돌려준다
+func (bc *Blockchain) reindexAddresses(chain []*Block) int {
+	fork := 0
+	for fork < len(chain) && fork < len(bc.chain) && chain[fork].Hash() == bc.chain[fork].Hash() {
+		fork++
+	}
+	bc.addressIndex.removeFrom(fork)
+	for i := fork; i < len(chain); i++ {
+		if !chain[i].pruned {
+			bc.addressIndex.addBlock(chain[i], i)
+		}
+	}
+	return fork
+}
+
+// light client가 header의 Merkle root로 확인하는 Transaction
+type TransactionProof struct {
+	Height      int          `json:"height"`
+	BlockHash   string       `json:"block_hash"`
+	Transaction *Transaction `json:"transaction"`
+	Proof       *MerkleProof `json:"proof"`
+}
+
+// body를 버린 높이의 항목이면 nil
+func (at *AddressTransaction) TransactionProof() *TransactionProof {
+	if at.transaction == nil {
+		return nil
+	}
+	return &TransactionProof{
+		Height:      at.height,
+		BlockHash:   fmt.Sprintf("%x", at.blockHash),
+		Transaction: at.transaction,
+		Proof:       at.proof,
+	}
+}
+
+type AddressProofsResponse struct {
+	Address    string              `json:"address"`
+	Proofs     []*TransactionProof `json:"proofs"`
+	NextHeight int                 `json:"next_height,omitempty"`
+}
+
+type AddressTransactionsResponse struct {
+	Address      string                `json:"address"`
+	Transactions []*AddressTransaction `json:"transactions"`
+	NextHeight   int                   `json:"next_height,omitempty"`
+}
@@ -0,0 +1,72 @@
+package block
+
+import (
+	"testing"
+	"time"
+)
+
+// body를 버린 높이의 항목은 hash와 위치만 남긴다
+func TestAddressIndexDropsPrunedBodies(t *testing.T) {
+	bc := forkedChain(3, time.Second)
+	hashes := make(map[int][32]byte)
+	for _, at := range bc.AddressIndex().Lookup(NewTransaction(MINING_SENDER, "alice", 1).Hash()) {
+		hashes[at.Height()] = at.Hash()
+	}
+	bc.SetPruneDepth(1)
+
+	transactions, _ := bc.AddressIndex().Transactions("alice", 0, -1, ADDRESS_TRANSACTIONS_LIMIT)
+	if len(transactions) != 3 {
+		t.Fatalf("unexpected entries %d", len(transactions))
+	}
+	for _, at := range transactions {
+		pruned := at.Height() < bc.PrunedHeight()
+		if pruned != (at.Transaction() == nil) || pruned != (at.TransactionProof() == nil) {
+			t.Fatalf("height %d: unexpected body", at.Height())
+		}
+		if at.Hash() != hashes[at.Height()] {
+			t.Fatalf("height %d: hash changed", at.Height())
+		}
+	}
+	if len(bc.AddressIndex().bodies) == 0 || bc.AddressIndex().bodies[0].height < bc.PrunedHeight() {
+		t.Fatal("pruned entries still kept with bodies")
+	}
+}
+
+// Block을 만들고 정리하는 동안에도 HTTP handler처럼 다른 goroutine에서 읽을 수 있다
+func TestAddressReadsWhileMining(t *testing.T) {
+	bc := forkedChain(1, time.Second)
+	bc.SetPruneDepth(2)
+	proofs, _ := bc.AddressProofs("alice", 0, -1, ADDRESS_TRANSACTIONS_LIMIT)
+	transactions, _ := bc.AddressTransactions("alice", 0, -1, ADDRESS_TRANSACTIONS_LIMIT)
+	if len(proofs) != 1 || len(transactions) != 1 {
+		t.Fatalf("unexpected entries %d %d", len(proofs), len(transactions))
+	}
+
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 20; i++ {
+			bc.AddTransaction(NewTransaction(MINING_SENDER, "alice", 1), nil, nil)
+			bc.Mining()
+		}
+		close(done)
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		bc.AddressTransactions("alice", 0, -1, ADDRESS_TRANSACTIONS_LIMIT)
+		bc.AddressProofs("alice", 0, -1, ADDRESS_TRANSACTIONS_LIMIT)
+		bc.HasAddress("alice")
+		bc.LookupTransaction(NewTransaction(MINING_SENDER, "alice", 1).Hash())
+	}
+
+	// 이미 돌려준 항목은 정리되어도 바뀌지 않는다
+	if transactions[0].Transaction() == nil || proofs[0].Transaction == nil {
+		t.Fatal("returned entry changed by prune")
+	}
+	if latest, _ := bc.AddressProofs("alice", 0, -1, ADDRESS_TRANSACTIONS_LIMIT); len(latest) != 2 {
+		t.Fatalf("%d proofs after pruning", len(latest))
+	}
+}
@@ -13,7 +13,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/sw90lee/blockchain_study/clock"
+	"github.com/sw90lee/blockchain_study/script"
+	"github.com/sw90lee/blockchain_study/token"
 	"github.com/sw90lee/blockchain_study/utils"
+	"github.com/sw90lee/blockchain_study/vm"
 )
 
 const (
@@ -27,18 +31,59 @@ const (
 	NEIGHBOR_IP_RANGE_START          = 0
 	NEIGHBOR_IP_RANGE_END            = 1
 	BLOCKCHIN_NEIGHBOR_SYNC_TIME_SEC = 20
+
+	CONSENSUS_PROOF_OF_WORK  = "pow"
+	CONSENSUS_PROOF_OF_STAKE = "pos"
+	CONSENSUS_MODE           = CONSENSUS_PROOF_OF_WORK
+
+	STAKING_RECIPIENT   = "THE STAKE"
+	STAKE_SLOT_SEC      = 20
+	MISSED_SLOT_PENALTY = 0.05
+	DOUBLE_SIGN_PENALTY = 1.0
+
+	LOCKTIME_THRESHOLD = script.LOCKTIME_THRESHOLD
+
+	CONTRACT_DEPLOY_RECIPIENT = "THE CONTRACT"
+	MAX_GAS_LIMIT             = 1000000
+
+	MAX_MEMO_SIZE = 256
+
+	SNAPSHOT_INTERVAL = 100
 )
 
+// 모든 노드의 genesis Block이 같은 hash를 갖도록 노드의 시계 대신 쓰는 시각
+var GENESIS_TIME = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
 type Block struct {
 	timestamp    int64
 	nonce        int
 	previousHash [32]byte
 	transactions []*Transaction
+
+	// Proof of Stake 모드에서만 사용
+	proposer          string
+	proposerPublicKey string
+	round             int
+	signature         string
+	evidence          []*Evidence
+
+	// contract 실행 결과
+	stateRoot [32]byte
+	receipts  []*Receipt
+
+	// body를 버리고 header만 남은 Block은 두 hash를 보관한다
+	pruned     bool
+	merkleRoot [32]byte
+	bodyHash   [32]byte
 }
 
 func NewBlock(nonce int, previousHash [32]byte, transactions []*Transaction) *Block {
+	return newBlockAt(time.Now(), nonce, previousHash, transactions)
+}
+
+func newBlockAt(now time.Time, nonce int, previousHash [32]byte, transactions []*Transaction) *Block {
 	b := new(Block)
-	b.timestamp = time.Now().UnixNano()
+	b.timestamp = now.UnixNano()
 	b.nonce = nonce
 	b.previousHash = previousHash
 	b.transactions = transactions
@@ -61,136 +106,389 @@ func (b *Block) Print() {
 	fmt.Printf("timestamp       %d\n", b.timestamp)
 	fmt.Printf("nonce           %d\n", b.nonce)
 	fmt.Printf("previous_hash   %x\n", b.previousHash)
+	if b.proposer != "" {
+		fmt.Printf("proposer        %s\n", b.proposer)
+		fmt.Printf("round           %d\n", b.round)
+	}
 	for _, t := range b.transactions {
 		t.Print()
 	}
 }
 
+// Transaction hash로 만든 Merkle tree의 root
+func (b *Block) MerkleRoot() [32]byte {
+	if b.pruned {
+		return b.merkleRoot
+	}
+	return transactionsRoot(b.transactions)
+}
+
+// transactions를 제외한 body(evidence, receipts)의 hash
+func (b *Block) BodyHash() [32]byte {
+	if b.pruned {
+		return b.bodyHash
+	}
+	m, _ := json.Marshal(struct {
+		Evidence []*Evidence `json:"evidence,omitempty"`
+		Receipts []*Receipt  `json:"receipts,omitempty"`
+	}{
+		Evidence: b.evidence,
+		Receipts: b.receipts,
+	})
+	return sha256.Sum256([]byte(m))
+}
+
+// body 대신 두 hash를 넣은 header의 Hash, body를 버린 Block도 같은 값이 나온다
 func (b *Block) Hash() [32]byte {
-	m, _ := json.Marshal(b)
+	m, _ := json.Marshal(struct {
+		Timestamp         int64  `json:"timestamp"`
+		Nonce             int    `json:"nonce"`
+		PreviousHash      string `json:"previous_hash"`
+		MerkleRoot        string `json:"merkle_root"`
+		BodyHash          string `json:"body_hash"`
+		StateRoot         string `json:"state_root,omitempty"`
+		Proposer          string `json:"proposer,omitempty"`
+		ProposerPublicKey string `json:"proposer_public_key,omitempty"`
+		Round             int    `json:"round,omitempty"`
+		Signature         string `json:"signature,omitempty"`
+	}{
+		Timestamp:         b.timestamp,
+		Nonce:             b.nonce,
+		PreviousHash:      fmt.Sprintf("%x", b.previousHash),
+		MerkleRoot:        fmt.Sprintf("%x", b.MerkleRoot()),
+		BodyHash:          fmt.Sprintf("%x", b.BodyHash()),
+		StateRoot:         stateRootString(b.stateRoot),
+		Proposer:          b.proposer,
+		ProposerPublicKey: b.proposerPublicKey,
+		Round:             b.round,
+		Signature:         b.signature,
+	})
 	return sha256.Sum256([]byte(m))
 }
 
 func (b *Block) MarshalJSON() ([]byte, error) {
+	bodyHash, merkleRoot := "", ""
+	if b.pruned {
+		bodyHash = fmt.Sprintf("%x", b.bodyHash)
+		merkleRoot = fmt.Sprintf("%x", b.merkleRoot)
+	}
 	return json.Marshal(struct {
-		Timestamp    int64          `json:"timestamp"`
-		Nonce        int            `json:"nonce"`
-		PreviousHash string         `json:"previous_hash"`
-		Transactions []*Transaction `json:"transactions"`
+		Version           int            `json:"version"`
+		Timestamp         int64          `json:"timestamp"`
+		Nonce             int            `json:"nonce"`
+		PreviousHash      string         `json:"previous_hash"`
+		Transactions      []*Transaction `json:"transactions"`
+		Proposer          string         `json:"proposer,omitempty"`
+		ProposerPublicKey string         `json:"proposer_public_key,omitempty"`
+		Round             int            `json:"round,omitempty"`
+		Signature         string         `json:"signature,omitempty"`
+		Evidence          []*Evidence    `json:"evidence,omitempty"`
+		StateRoot         string         `json:"state_root,omitempty"`
+		Receipts          []*Receipt     `json:"receipts,omitempty"`
+		Pruned            bool           `json:"pruned,omitempty"`
+		MerkleRoot        string         `json:"merkle_root,omitempty"`
+		BodyHash          string         `json:"body_hash,omitempty"`
 	}{
-		Timestamp:    b.timestamp,
-		Nonce:        b.nonce,
-		PreviousHash: fmt.Sprintf("%x", b.previousHash),
-		Transactions: b.transactions,
+		Version:           SCHEMA_VERSION,
+		Timestamp:         b.timestamp,
+		Nonce:             b.nonce,
+		PreviousHash:      fmt.Sprintf("%x", b.previousHash),
+		Transactions:      b.transactions,
+		Proposer:          b.proposer,
+		ProposerPublicKey: b.proposerPublicKey,
+		Round:             b.round,
+		Signature:         b.signature,
+		Evidence:          b.evidence,
+		StateRoot:         stateRootString(b.stateRoot),
+		Receipts:          b.receipts,
+		Pruned:            b.pruned,
+		MerkleRoot:        merkleRoot,
+		BodyHash:          bodyHash,
 	})
 }
 
+// 모르는 field, 빠진 field, 다른 형식 번호는 에러
 func (b *Block) UnmarshalJSON(data []byte) error {
+	if err := checkFields("block", data, blockFields, blockOptionalFields); err != nil {
+		return err
+	}
+	var version int
 	var previousHash string
+	var stateRoot string
+	var bodyHash, merkleRoot string
 	v := &struct {
-		Timestamp    *int64          `json:"timestamp"`
-		Nonce        *int            `json:"nonce"`
-		PreviousHash *string         `json: previous_hash`
-		Transactions *[]*Transaction `json:"transactions"`
+		Version           *int            `json:"version"`
+		Timestamp         *int64          `json:"timestamp"`
+		Nonce             *int            `json:"nonce"`
+		PreviousHash      *string         `json:"previous_hash"`
+		Transactions      *[]*Transaction `json:"transactions"`
+		Proposer          *string         `json:"proposer"`
+		ProposerPublicKey *string         `json:"proposer_public_key"`
+		Round             *int            `json:"round"`
+		Signature         *string         `json:"signature"`
+		Evidence          *[]*Evidence    `json:"evidence"`
+		StateRoot         *string         `json:"state_root"`
+		Receipts          *[]*Receipt     `json:"receipts"`
+		Pruned            *bool           `json:"pruned"`
+		MerkleRoot        *string         `json:"merkle_root"`
+		BodyHash          *string         `json:"body_hash"`
 	}{
-		Timestamp:    &b.timestamp,
-		Nonce:        &b.nonce,
-		PreviousHash: &previousHash,
-		Transactions: &b.transactions,
+		Version:           &version,
+		Timestamp:         &b.timestamp,
+		Nonce:             &b.nonce,
+		PreviousHash:      &previousHash,
+		Transactions:      &b.transactions,
+		Proposer:          &b.proposer,
+		ProposerPublicKey: &b.proposerPublicKey,
+		Round:             &b.round,
+		Signature:         &b.signature,
+		Evidence:          &b.evidence,
+		StateRoot:         &stateRoot,
+		Receipts:          &b.receipts,
+		Pruned:            &b.pruned,
+		MerkleRoot:        &merkleRoot,
+		BodyHash:          &bodyHash,
 	}
 	if err := json.Unmarshal(data, &v); err != nil {
 		return err
 	}
-	ph, _ := hex.DecodeString(*v.PreviousHash)
-	copy(b.previousHash[:], ph[:32])
+	if err := checkVersion("block", version); err != nil {
+		return err
+	}
+	var err error
+	if b.previousHash, err = decodeHash("block.previous_hash", previousHash, false); err != nil {
+		return err
+	}
+	if b.stateRoot, err = decodeHash("block.state_root", stateRoot, true); err != nil {
+		return err
+	}
+	// body를 버린 Block만 두 hash를 가진다
+	if !b.pruned {
+		if merkleRoot != "" || bodyHash != "" {
+			return fmt.Errorf("%w: block.merkle_root in a block with a body", ErrUnknownField)
+		}
+		return nil
+	}
+	if b.merkleRoot, err = decodeHash("block.merkle_root", merkleRoot, false); err != nil {
+		return err
+	}
+	if b.bodyHash, err = decodeHash("block.body_hash", bodyHash, false); err != nil {
+		return err
+	}
 	return nil
 }
 
 type Blockchain struct {
 	transactionPool   []*Transaction
+	lockedPool        []*Transaction
 	chain             []*Block
 	blockchainAddress string
 	port              uint16
 	mux               sync.Mutex
+	// timestamp, lock time, mining과 이웃 동기화 timer에 쓰는 시계
+	clock  clock.Clock
+	params Params
+
+	consensus    string
+	validatorKey *ecdsa.PrivateKey
+	evidencePool []*Evidence
+
+	// chain 끝까지 적용한 잔액, token, NFT, contract, stake
+	state *State
+	// 주소 -> Transaction 기록
+	addressIndex *AddressIndex
+	// SNAPSHOT_INTERVAL 높이마다 만드는 최근 상태
+	snapshot *Snapshot
+	fastSync bool
+	// 0이 아니면 마지막 pruneDepth개 Block만 body를 보관
+	pruneDepth   int
+	prunedHeight int
 
 	neighbors    []string
 	muxNeighbors sync.Mutex
+	// UseNeighbors로 정했으면 주소 범위를 훑지 않는다
+	staticNeighbors bool
+	// nil이면 http.DefaultClient
+	httpClient *http.Client
+	// nil이면 HTTP 이웃에게만 알린다
+	relay Relay
+
+	mining bool
+	// /events 구독자
+	subscribers    map[int]chan *Event
+	nextSubscriber int
+	muxSubscribers sync.Mutex
+
+	// light client가 등록한 Bloom filter
+	filters    map[string]*loadedFilter
+	muxFilters sync.Mutex
 }
 
+// 다른 goroutine이 Block을 붙이거나 정리해도 바뀌지 않는 복사본
 func (bc *Blockchain) Chain() []*Block {
-	return bc.chain
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	return append([]*Block{}, bc.chain...)
 }
 
 func (bc *Blockchain) SetNeighbors() {
 	bc.neighbors = utils.FindNeighbors(
 		utils.GetHost(), bc.port,
-		NEIGHBOR_IP_RANGE_START,
-		NEIGHBOR_IP_RANGE_END,
-		BLOCKCHAIN_PORT_RANGE_START, BLOCKCHAIN_PORT_RANGE_END)
+		bc.params.IpRangeStart,
+		bc.params.IpRangeEnd,
+		bc.params.PortRangeStart, bc.params.PortRangeEnd)
 	log.Printf("%v", bc.neighbors)
 }
 
+// 주소 범위를 훑지 않고 정해진 이웃을 쓴다, Run도 StartSyncNeighbors를 부르지 않는다
+func (bc *Blockchain) UseNeighbors(neighbors []string) {
+	bc.neighbors = append([]string{}, neighbors...)
+	bc.staticNeighbors = true
+}
+
+func (bc *Blockchain) Neighbors() []string {
+	return bc.neighbors
+}
+
+// 이웃 노드에 요청할 때 쓰는 client, 시뮬레이터는 메모리 안의 transport를 넣는다
+func (bc *Blockchain) SetHTTPClient(client *http.Client) {
+	bc.httpClient = client
+}
+
+func (bc *Blockchain) client() *http.Client {
+	if bc.httpClient == nil {
+		return http.DefaultClient
+	}
+	return bc.httpClient
+}
+
 func (bc *Blockchain) SyncNeighbors() {
 	bc.muxNeighbors.Lock()
-	defer bc.mux.Unlock()
+	defer bc.muxNeighbors.Unlock()
 	bc.SetNeighbors()
 }
 
 func (bc *Blockchain) StartSyncNeighbors() {
 	bc.SyncNeighbors()
-	_ = time.AfterFunc(time.Second*BLOCKCHIN_NEIGHBOR_SYNC_TIME_SEC, bc.StartSyncNeighbors)
+	_ = bc.clock.AfterFunc(bc.params.neighborSync(), bc.StartSyncNeighbors)
 }
 
 func NewBlockchain(blockchainAddress string, port uint16) *Blockchain {
+	return NewBlockchainWithClock(blockchainAddress, port, clock.System)
+}
+
+// genesis Block은 GENESIS_TIME으로 만들고 그 다음 Block부터 c를 쓴다
+func NewBlockchainWithClock(blockchainAddress string, port uint16, c clock.Clock) *Blockchain {
 	b := &Block{}
 	bc := new(Blockchain)
+	bc.clock = c
 	bc.blockchainAddress = blockchainAddress
-	bc.CreateBlock(0, b.Hash())
+	bc.consensus = CONSENSUS_MODE
+	bc.params = DefaultParams()
+	bc.state = NewState()
+	bc.addressIndex = NewAddressIndex()
+	bc.filters = make(map[string]*loadedFilter)
+	bc.subscribers = make(map[int]chan *Event)
+	bc.createBlockAt(GENESIS_TIME, 0, b.Hash())
 	bc.port = port
 	return bc
 }
 
 func (bc *Blockchain) Run() {
-	bc.StartSyncNeighbors()
+	if !bc.staticNeighbors {
+		bc.StartSyncNeighbors()
+	}
+	if bc.fastSync {
+		bc.FastSync()
+	}
 	bc.ResolveConflicts()
 }
 
 func (bc *Blockchain) TransactionPool() []*Transaction {
-	return bc.transactionPool
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	return append([]*Transaction{}, bc.transactionPool...)
+}
+
+// lock time이 지나지 않아 아직 Block에 넣을 수 없는 Transaction
+func (bc *Blockchain) LockedTransactionPool() []*Transaction {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	return append([]*Transaction{}, bc.lockedPool...)
 }
 
 func (bc *Blockchain) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
-		Blocks []*Block `json:"chains"`
+		Version int      `json:"version"`
+		Blocks  []*Block `json:"chain"`
 	}{
-		Blocks: bc.chain,
+		Version: SCHEMA_VERSION,
+		Blocks:  bc.chain,
 	})
 }
 
 func (bc *Blockchain) UnmarshalJSON(data []byte) error {
+	if err := checkFields("chain", data, chainFields, nil); err != nil {
+		return err
+	}
+	var version int
 	v := &struct {
-		Block *[]*Block `json:"chain"`
+		Version *int      `json:"version"`
+		Block   *[]*Block `json:"chain"`
 	}{
-		Block: &bc.chain,
+		Version: &version,
+		Block:   &bc.chain,
 	}
 	if err := json.Unmarshal(data, &v); err != nil {
 		return err
 	}
-	return nil
+	return checkVersion("chain", version)
 }
 
+// pool의 Transaction을 적용할 수 없으면 Block을 붙이지 않고 nil
 func (bc *Blockchain) CreateBlock(nonce int, previousHash [32]byte) *Block {
-	b := NewBlock(nonce, previousHash, bc.transactionPool)
+	return bc.createBlockAt(bc.clock.Now(), nonce, previousHash)
+}
+
+func (bc *Blockchain) createBlockAt(now time.Time, nonce int, previousHash [32]byte) *Block {
+	b := newBlockAt(now, nonce, previousHash, bc.transactionPool)
+	if err := bc.executeBlock(b); err != nil {
+		log.Printf("ERROR: %v", err)
+		return nil
+	}
+	if err := bc.appendBlock(b); err != nil {
+		log.Printf("ERROR: %v", err)
+		return nil
+	}
+	return b
+}
+
+// state에 적용할 수 없는 Block은 chain에 붙이지 않는다
+func (bc *Blockchain) appendBlock(b *Block) error {
+	height := len(bc.chain)
+	state := bc.state.Copy()
+	if err := state.applyBlock(b, height); err != nil {
+		return err
+	}
+	bc.state = state
 	bc.chain = append(bc.chain, b)
+	bc.addressIndex.addBlock(b, height)
+	if height > 0 {
+		blocksTotal.Inc(BLOCK_RESULT_MINED)
+	}
+	bc.publish(newBlockEvent(b, height, EVENT_SOURCE_LOCAL))
+	if height > 0 && height%SNAPSHOT_INTERVAL == 0 {
+		bc.snapshot = NewSnapshot(height, b.Hash(), bc.state.Copy())
+	}
+	bc.prune()
 	bc.transactionPool = []*Transaction{}
 	for _, n := range bc.neighbors {
-		endpoint := fmt.Sprintf("http://%s/transcations", n)
-		client := &http.Client{}
+		endpoint := fmt.Sprintf("http://%s/transactions", n)
 		req, _ := http.NewRequest("DELETE", endpoint, nil)
-		resp, _ := client.Do(req)
+		resp, _ := neighborDo(bc.client(), req)
 		log.Printf("%v", resp)
 	}
-	return b
+	return nil
 }
 
 func (bc *Blockchain) LastBlock() *Block {
@@ -206,47 +504,61 @@ func (bc *Blockchain) Print() {
 	fmt.Printf("%s\n", strings.Repeat("*", 25))
 }
 
-func (bc *Blockchain) CreateTransaction(sender string, recipient string, value float32,
+func (bc *Blockchain) CreateTransaction(t *Transaction,
 	senderPublicKey *ecdsa.PublicKey, s *utils.Signature) bool {
-	isTransacted := bc.AddTransaction(sender, recipient, value, senderPublicKey, s)
+	isTransacted := bc.AddTransaction(t, senderPublicKey, s)
 
 	if isTransacted {
-		for _, n := range bc.neighbors {
-			publicKeyStr := fmt.Sprintf("%064x%064x", senderPublicKey.X.Bytes(),
-				senderPublicKey.Y.Bytes())
-			signatureStr := s.String()
-			bt := &TransactionRequest{&sender, &recipient, &publicKeyStr, &value, &signatureStr}
-			m, _ := json.Marshal(bt)
-			buf := bytes.NewBuffer(m)
-			endpoint := fmt.Sprintf("http://%s/transcations", n)
-			client := &http.Client{}
-			req, _ := http.NewRequest("PUT", endpoint, buf)
-			resp, _ := client.Do(req)
-			log.Printf("%v", resp)
-		}
+		publicKeyStr := fmt.Sprintf("%064x%064x", senderPublicKey.X.Bytes(),
+			senderPublicKey.Y.Bytes())
+		signatureStr := s.String()
+		bt := t.Request()
+		bt.SenderPublicKey = &publicKeyStr
+		bt.Signature = &signatureStr
+		bc.broadcastTransaction(bt)
 	}
 
 	return isTransacted
 }
 
-func (bc *Blockchain) AddTransaction(sender string, recipient string, value float32,
-	senderPublicKey *ecdsa.PublicKey, s *utils.Signature) bool {
-	t := NewTransaction(sender, recipient, value)
+func (bc *Blockchain) broadcastTransaction(bt *TransactionRequest) {
+	if bc.relay != nil {
+		bc.relay.RelayTransaction(bt)
+	}
+	for _, n := range bc.neighbors {
+		m, _ := json.Marshal(bt)
+		buf := bytes.NewBuffer(m)
+		endpoint := fmt.Sprintf("http://%s/transactions", n)
+		req, _ := http.NewRequest("PUT", endpoint, buf)
+		resp, _ := neighborDo(bc.client(), req)
+		log.Printf("%v", resp)
+	}
+}
 
-	if sender == MINING_SENDER {
+func (bc *Blockchain) AddTransaction(t *Transaction,
+	senderPublicKey *ecdsa.PublicKey, s *utils.Signature) bool {
+	if t.senderBlockchainAddress == MINING_SENDER {
 		bc.transactionPool = append(bc.transactionPool, t)
 		return true
 	}
 
+	if senderPublicKey == nil || s == nil {
+		log.Println("ERROR: missing public key or signature")
+		return false
+	}
+	// 다른 주소를 sender로 적고 자기 key로 서명하지 못하게 한다
+	if utils.BlockchainAddress(senderPublicKey) != t.senderBlockchainAddress {
+		log.Println("ERROR: sender address does not match public key")
+		return false
+	}
 	if bc.VerifyTransactionSignature(senderPublicKey, s, t) {
 		/*
-			if bc.CalculateTotalAmount(sender) < value {
+			if bc.CalculateTotalAmount(t.senderBlockchainAddress) < t.value {
 				log.Println("ERROR: Not enough balance in a wallet")
 				return false
 			}
 		*/
-		bc.transactionPool = append(bc.transactionPool, t)
-		return true
+		return bc.addToPool(t)
 	} else {
 		log.Println("ERROR: Verify Transaction")
 	}
@@ -256,25 +568,80 @@ func (bc *Blockchain) AddTransaction(sender string, recipient string, value floa
 
 func (bc *Blockchain) VerifyTransactionSignature(
 	senderPublicKey *ecdsa.PublicKey, s *utils.Signature, t *Transaction) bool {
-	m, _ := json.Marshal(t)
-	h := sha256.Sum256([]byte(m))
+	h := t.SigningHash()
 	return ecdsa.Verify(senderPublicKey, h[:], s.R, s.S)
 }
 
+// 내용을 확인하고 lock time이 남은 Transaction은 따로 보관
+func (bc *Blockchain) addToPool(t *Transaction) bool {
+	if len(t.memo) > bc.params.MaxMemoSize {
+		log.Println("ERROR: memo too long")
+		return false
+	}
+	if bc.inPool(t.Hash()) {
+		log.Printf("ERROR: %v", ErrDuplicateTransaction)
+		return false
+	}
+	if !bc.ValidContractTransaction(t) ||
+		!bc.ValidStakeTransaction(t) ||
+		!bc.ValidTokenTransaction(t) ||
+		!bc.ValidNFTTransaction(t) {
+		return false
+	}
+	if !t.IsFinal(int64(len(bc.chain)), bc.clock.Now().Unix()) {
+		bc.lockedPool = append(bc.lockedPool, t)
+		bc.publish(newTransactionEvent(t))
+		return true
+	}
+	bc.transactionPool = append(bc.transactionPool, t)
+	bc.publish(newTransactionEvent(t))
+	return true
+}
+
+func (bc *Blockchain) inPool(hash [32]byte) bool {
+	for _, pool := range [][]*Transaction{bc.transactionPool, bc.lockedPool} {
+		for _, t := range pool {
+			if t.Hash() == hash {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lock time이 지난 Transaction을 transactionPool로 옮김
+func (bc *Blockchain) releaseLockedTransactions() {
+	height := int64(len(bc.chain))
+	now := bc.clock.Now().Unix()
+	locked := make([]*Transaction, 0)
+	for _, t := range bc.lockedPool {
+		if t.IsFinal(height, now) {
+			bc.transactionPool = append(bc.transactionPool, t)
+		} else {
+			locked = append(locked, t)
+		}
+	}
+	bc.lockedPool = locked
+}
+
 func (bc *Blockchain) CopyTransactionPool() []*Transaction {
 	transactions := make([]*Transaction, 0)
 	for _, t := range bc.transactionPool {
-		transactions = append(transactions,
-			NewTransaction(t.senderBlockchainAddress,
-				t.recipientBlockchainAddress,
-				t.value))
+		c := *t
+		transactions = append(transactions, &c)
 	}
 	return transactions
 }
 
 func (bc *Blockchain) ValidProof(nonce int, previousHash [32]byte, transactions []*Transaction, difficulty int) bool {
+	return validProofHash(nonce, previousHash, transactionsRoot(transactions), difficulty)
+}
+
+// transactions 대신 Merkle root만으로 확인, header만 받은 경우에도 사용
+func validProofHash(nonce int, previousHash [32]byte, merkleRoot [32]byte, difficulty int) bool {
 	zeros := strings.Repeat("0", difficulty)
-	guessBlock := Block{0, nonce, previousHash, transactions}
+	guessBlock := Block{nonce: nonce, previousHash: previousHash, pruned: true,
+		merkleRoot: merkleRoot, bodyHash: (&Block{}).BodyHash()}
 	guessHashStr := fmt.Sprintf("%x", guessBlock.Hash())
 	return guessHashStr[:difficulty] == zeros
 }
@@ -282,10 +649,15 @@ func (bc *Blockchain) ValidProof(nonce int, previousHash [32]byte, transactions
 func (bc *Blockchain) ProofOfWork() int {
 	transactions := bc.CopyTransactionPool()
 	previousHash := bc.LastBlock().Hash()
+	merkleRoot := transactionsRoot(transactions)
+	start := time.Now()
 	nonce := 0
-	for !bc.ValidProof(nonce, previousHash, transactions, MINING_DIFFICULTY) {
+	for !validProofHash(nonce, previousHash, merkleRoot, bc.params.MiningDifficulty) {
 		nonce += 1
 	}
+	if elapsed := time.Since(start).Seconds(); elapsed > 0 {
+		hashrate.Set(float64(nonce+1) / elapsed)
+	}
 	return nonce
 }
 
@@ -293,30 +665,93 @@ func (bc *Blockchain) Mining() bool {
 	bc.mux.Lock()
 	defer bc.mux.Unlock()
 
-	if len(bc.transactionPool) == 0 {
-		return false
-	}
+	bc.releaseLockedTransactions()
+	bc.dropInvalidNFTTransactions()
 
-	bc.AddTransaction(MINING_SENDER, bc.blockchainAddress, MINING_REWARD, nil, nil)
-	nonce := bc.ProofOfWork()
-	previousHash := bc.LastBlock().Hash()
-	bc.CreateBlock(nonce, previousHash)
-	log.Println("action=mining, status=success")
+	if bc.consensus == CONSENSUS_PROOF_OF_STAKE {
+		if !bc.Staking() {
+			return false
+		}
+	} else {
+		if len(bc.transactionPool) == 0 {
+			return false
+		}
+
+		pending := len(bc.transactionPool)
+		bc.AddTransaction(NewTransaction(MINING_SENDER, bc.blockchainAddress, MINING_REWARD), nil, nil)
+		nonce := bc.ProofOfWork()
+		previousHash := bc.LastBlock().Hash()
+		if bc.CreateBlock(nonce, previousHash) == nil {
+			// 보상이 pool에 쌓이지 않게 되돌리고, 다음 Block도 막지 않도록 실패한 Transaction을 뺀다
+			bc.transactionPool = bc.transactionPool[:pending]
+			bc.dropFailingTransactions()
+			log.Println("action=mining, status=failure")
+			return false
+		}
+		log.Println("action=mining, status=success")
+	}
 
+	if bc.relay != nil {
+		bc.relay.RelayBlock(bc.LastBlock(), len(bc.chain)-1)
+	}
 	for _, n := range bc.neighbors {
 		endpoint := fmt.Sprintf("http://%s/consensus", n)
-		client := &http.Client{}
 		req, _ := http.NewRequest("PUT", endpoint, nil)
-		resp, _ := client.Do(req)
+		resp, _ := neighborDo(bc.client(), req)
 		log.Printf("%v", resp)
 	}
 
 	return true
 }
 
+// 다음 Block에서 실행할 수 없는 Transaction을 pool에서 제거
+func (bc *Blockchain) dropFailingTransactions() {
+	height := len(bc.chain)
+	previousHash := bc.LastBlock().Hash()
+	pool := make([]*Transaction, 0, len(bc.transactionPool))
+	for _, t := range bc.transactionPool {
+		b := newBlockAt(bc.clock.Now(), 0, previousHash, append(pool[:len(pool):len(pool)], t))
+		if _, err := bc.state.Copy().execute(b, height); err != nil {
+			log.Printf("action=drop_transaction, hash=%x, error=%v", t.Hash(), err)
+			continue
+		}
+		pool = append(pool, t)
+	}
+	bc.transactionPool = pool
+}
+
 func (bc *Blockchain) StartMining() {
+	if bc.mining {
+		return
+	}
+	bc.mining = true
+	bc.publish(&Event{Type: EVENT_MINING_START})
+	bc.miningLoop()
+}
+
+func (bc *Blockchain) StopMining() {
+	if !bc.mining {
+		return
+	}
+	bc.mining = false
+	bc.publish(&Event{Type: EVENT_MINING_STOP})
+}
+
+func (bc *Blockchain) IsMining() bool {
+	return bc.mining
+}
+
+func (bc *Blockchain) miningLoop() {
+	if !bc.mining {
+		return
+	}
 	bc.Mining()
-	_ = time.AfterFunc(time.Second*MINING_TIMER_SEC, bc.StartMining)
+	if bc.consensus == CONSENSUS_PROOF_OF_STAKE {
+		// slot을 놓치지 않도록 slot 길이보다 짧은 간격으로 확인
+		_ = bc.clock.AfterFunc(time.Second*STAKE_SLOT_SEC/4, bc.miningLoop)
+		return
+	}
+	_ = bc.clock.AfterFunc(bc.params.miningTimer(), bc.miningLoop)
 }
 
 func (bc *Blockchain) ClearTransactionPool() {
@@ -324,30 +759,58 @@ func (bc *Blockchain) ClearTransactionPool() {
 }
 
 func (bc *Blockchain) CalculateTotalAmount(blockchainAddress string) float32 {
-	var totalAmount float32 = 0.0
-	for _, b := range bc.chain {
-		for _, t := range b.transactions {
-			value := t.value
-			if blockchainAddress == t.recipientBlockchainAddress {
-				totalAmount += value
-			}
-
-			if blockchainAddress == t.senderBlockchainAddress {
-				totalAmount -= value
-			}
-		}
-	}
-	return totalAmount
+	return bc.state.Balance(blockchainAddress)
 }
 
 type Transaction struct {
 	senderBlockchainAddress    string
 	recipientBlockchainAddress string
 	value                      float32
+	lockTime                   int64
+	memo                       string
+	contract                   *vm.Payload
+	token                      *token.Payload
+	nft                        *token.NFTPayload
+
+	// script 주소에서 보낸 경우, 서명 대상에는 포함되지 않는다
+	lockingScript   []byte
+	unlockingScript []byte
+}
+
+// Block의 Transaction이 모두 lock time이 지났는지 확인
+func (b *Block) ValidLockTimes(height int64) bool {
+	for _, t := range b.transactions {
+		if !t.IsFinal(height, b.timestamp/int64(time.Second)) {
+			log.Println("ERROR: transaction included before lock time")
+			return false
+		}
+	}
+	return true
+}
+
+func (b *Block) ValidMemos(maxSize int) bool {
+	for _, t := range b.transactions {
+		if len(t.memo) > maxSize {
+			log.Println("ERROR: memo too long")
+			return false
+		}
+	}
+	return true
 }
 
 // Chain Block 확인
 func (bc *Blockchain) ValidChain(chain []*Block) bool {
+	defer observeValidation("chain", time.Now())
+	if bc.consensus == CONSENSUS_PROOF_OF_STAKE {
+		return bc.ValidStakeChain(chain)
+	}
+
+	state := NewState()
+	if err := state.applyBlock(chain[0], 0); err != nil {
+		log.Printf("ERROR: %v", err)
+		return false
+	}
+
 	preBlock := chain[0]
 	currentIndex := 1
 	for currentIndex < len(chain) {
@@ -356,41 +819,96 @@ func (bc *Blockchain) ValidChain(chain []*Block) bool {
 			return false
 		}
 
-		if !bc.ValidProof(b.Nonce(), b.PreviousHash(), b.Transaction(), MINING_DIFFICULTY) {
+		if !bc.ValidProof(b.Nonce(), b.PreviousHash(), b.Transaction(), bc.params.MiningDifficulty) {
+			return false
+		}
+
+		if !b.ValidLockTimes(int64(currentIndex)) || !b.ValidMemos(bc.params.MaxMemoSize) || !b.ValidScripts(int64(currentIndex)) {
+			return false
+		}
+
+		if err := state.applyBlock(b, currentIndex); err != nil {
+			log.Printf("ERROR: %v", err)
 			return false
 		}
 
 		preBlock = b
 		currentIndex += 1
 	}
+
 	return true
 }
 
+// 검증된 chain으로 바꾸고 chain에서 계산되는 상태를 다시 만든다, bc.mux를 잡고 부른다
+func (bc *Blockchain) replaceChain(chain []*Block) {
+	state, snapshot, ok := replayState(chain)
+	if !ok {
+		return
+	}
+	fork, dropped := bc.reindexAddresses(chain), len(bc.chain)
+	bc.chain = chain
+	bc.state = state
+	bc.snapshot = snapshot
+	bc.prunedHeight = 0
+	bc.dropIncluded(chain[fork:])
+	bc.prune()
+	observeChainSwitch(dropped-fork, len(chain)-fork)
+	bc.publishChain(fork, dropped-fork, EVENT_SOURCE_PEER)
+}
+
+// 이웃의 chain은 lock 없이 받아 오고, 비교하고 바꾸는 동안만 OfferChain처럼 bc.mux를 잡는다
 func (bc *Blockchain) ResolveConflicts() bool {
-	var longestChain []*Block = nil
-	maxLength := len(bc.chain)
+	var peers []string
+	var chains [][]*Block
 
 	// 근처의 노드의 Blockchain을
 	for _, n := range bc.neighbors {
+		// 정리된 노드는 오래된 Block body가 없으므로 chain을 받지 않는다
+		if status, err := bc.fetchStatus(n); err == nil && status.PrunedHeight > 0 {
+			log.Printf("action=skip_pruned_peer, peer=%s, pruned_height=%d", n, status.PrunedHeight)
+			continue
+		}
+
 		endpoint := fmt.Sprintf("http://%s/chain", n)
-		resp, _ := http.Get(endpoint)
-		if resp.StatusCode == 200 {
+		resp, err := neighborGet(bc.client(), endpoint)
+		if err == nil && resp.StatusCode == 200 {
 			var bcResp Blockchain
 			decoder := json.NewDecoder(resp.Body)
 			_ = decoder.Decode(&bcResp)
+			resp.Body.Close()
+			peers = append(peers, n)
+			chains = append(chains, bcResp.Chain())
+		}
+	}
 
-			chain := bcResp.Chain()
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	var longestChain []*Block = nil
+	maxLength := len(bc.chain)
+	for i, chain := range chains {
+		if bc.consensus == CONSENSUS_PROOF_OF_STAKE {
+			bc.CollectEvidence(chain)
+		}
 
-			if len(chain) > maxLength && bc.ValidChain(chain) {
-				maxLength = len(chain)
-				longestChain = chain
-			}
+		if len(chain) <= maxLength {
+			continue
+		}
+		if err := bc.checkReorg(chain); err != nil {
+			log.Printf("ERROR: suspicious chain from %s: %v", peers[i], err)
+			blocksTotal.Add(float64(len(chain)-len(bc.chain)), BLOCK_RESULT_REJECTED)
+			continue
+		}
+		if bc.ValidChain(chain) {
+			maxLength = len(chain)
+			longestChain = chain
+		} else {
+			blocksTotal.Add(float64(len(chain)-len(bc.chain)), BLOCK_RESULT_REJECTED)
 		}
 	}
 
 	// 제일 긴 체인이 없을경우 -> 현재 체인을 긴 체인으로 변경
 	if longestChain != nil {
-		bc.chain = longestChain
+		bc.replaceChain(longestChain)
 		log.Printf("Resolve confilicts Replaced")
 		return true
 	}
@@ -400,7 +918,117 @@ func (bc *Blockchain) ResolveConflicts() bool {
 }
 
 func NewTransaction(sender string, recipient string, value float32) *Transaction {
-	return &Transaction{sender, recipient, value}
+	return &Transaction{
+		senderBlockchainAddress:    sender,
+		recipientBlockchainAddress: recipient,
+		value:                      value,
+	}
+}
+
+func (t *Transaction) Sender() string {
+	return t.senderBlockchainAddress
+}
+
+func (t *Transaction) Recipient() string {
+	return t.recipientBlockchainAddress
+}
+
+// LOCKTIME_THRESHOLD 미만이면 Block 높이, 이상이면 unix time(second)
+func (t *Transaction) SetLockTime(lockTime int64) {
+	t.lockTime = lockTime
+}
+
+func (t *Transaction) LockTime() int64 {
+	return t.lockTime
+}
+
+// 송금에 붙이는 청구서 번호 같은 참조 정보 (Params.MaxMemoSize bytes 이하)
+func (t *Transaction) SetMemo(memo string) {
+	t.memo = memo
+}
+
+func (t *Transaction) Memo() string {
+	return t.memo
+}
+
+// contract 배포 또는 호출 내용
+func (t *Transaction) SetContract(contract *vm.Payload) {
+	t.contract = contract
+}
+
+func (t *Transaction) Contract() *vm.Payload {
+	return t.contract
+}
+
+// token 발행 또는 이동 내용
+func (t *Transaction) SetToken(token *token.Payload) {
+	t.token = token
+}
+
+func (t *Transaction) Token() *token.Payload {
+	return t.token
+}
+
+// NFT 발행 또는 이동 내용
+func (t *Transaction) SetNFT(nft *token.NFTPayload) {
+	t.nft = nft
+}
+
+func (t *Transaction) NFT() *token.NFTPayload {
+	return t.nft
+}
+
+func (t *Transaction) LockingScript() []byte {
+	return t.lockingScript
+}
+
+func (t *Transaction) UnlockingScript() []byte {
+	return t.unlockingScript
+}
+
+// 서명할 때 사용하는 hash (script 제외)
+func (t *Transaction) SigningHash() [32]byte {
+	c := *t
+	c.lockingScript = nil
+	c.unlockingScript = nil
+	m, _ := json.Marshal(&c)
+	return sha256.Sum256([]byte(m))
+}
+
+// script까지 포함한 Transaction의 hash, 내용이 같은 Transaction은 같은 값이 나온다
+func (t *Transaction) Hash() [32]byte {
+	m, _ := json.Marshal(t)
+	return sha256.Sum256([]byte(m))
+}
+
+// height 높이, timestamp 시각(unix second)의 Block에 들어갈 수 있는지 확인
+func (t *Transaction) IsFinal(height int64, timestamp int64) bool {
+	if t.lockTime == 0 {
+		return true
+	}
+	if t.lockTime < LOCKTIME_THRESHOLD {
+		return height >= t.lockTime
+	}
+	return timestamp >= t.lockTime
+}
+
+// 서명 정보를 뺀 TransactionRequest
+func (t *Transaction) Request() *TransactionRequest {
+	tr := &TransactionRequest{
+		SenderBlockchainAddress:    &t.senderBlockchainAddress,
+		RecipientBlockchainAddress: &t.recipientBlockchainAddress,
+		Value:                      &t.value,
+	}
+	if t.lockTime != 0 {
+		tr.LockTime = &t.lockTime
+	}
+	if t.memo != "" {
+		tr.Memo = &t.memo
+	}
+	tr.Contract = t.contract
+	tr.Token = t.token
+	tr.NFT = t.nft
+	return tr
 }
 
 func (t *Transaction) Print() {
@@ -408,45 +1036,158 @@ func (t *Transaction) Print() {
 	fmt.Printf(" sender_blockchain_address      %s\n", t.senderBlockchainAddress)
 	fmt.Printf(" recipient_blockchain_address   %s\n", t.recipientBlockchainAddress)
 	fmt.Printf(" value                          %.1f\n", t.value)
+	if t.lockTime != 0 {
+		fmt.Printf(" lock_time                      %d\n", t.lockTime)
+	}
+	if t.memo != "" {
+		fmt.Printf(" memo                           %s\n", t.memo)
+	}
+	if t.contract != nil {
+		fmt.Printf(" contract_gas_limit             %d\n", t.contract.GasLimit())
+	}
+	if t.token != nil {
+		fmt.Printf(" token                          %s %d\n", t.token.Symbol(), t.token.Amount())
+	}
+	if t.nft != nil {
+		fmt.Printf(" nft                            %s\n", t.nft.NFTId(t.senderBlockchainAddress))
+	}
 }
 
 func (t *Transaction) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
-		Sender    string  `json:"sender_blockchain_address"`
-		Recipient string  `json:"recipient_blockchain_address"`
-		Value     float32 `json:"value"`
+		Sender    string            `json:"sender_blockchain_address"`
+		Recipient string            `json:"recipient_blockchain_address"`
+		Value     float32           `json:"value"`
+		LockTime  int64             `json:"lock_time,omitempty"`
+		Memo      string            `json:"memo,omitempty"`
+		Contract  *vm.Payload       `json:"contract,omitempty"`
+		Token     *token.Payload    `json:"token,omitempty"`
+		NFT       *token.NFTPayload `json:"nft,omitempty"`
+		Locking   string            `json:"locking_script,omitempty"`
+		Unlocking string            `json:"unlocking_script,omitempty"`
 	}{
 		Sender:    t.senderBlockchainAddress,
 		Recipient: t.recipientBlockchainAddress,
 		Value:     t.value,
+		LockTime:  t.lockTime,
+		Memo:      t.memo,
+		Contract:  t.contract,
+		Token:     t.token,
+		NFT:       t.nft,
+		Locking:   hex.EncodeToString(t.lockingScript),
+		Unlocking: hex.EncodeToString(t.unlockingScript),
 	})
 }
 
+// 모르는 field와 빠진 field는 에러
 func (t *Transaction) UnmarshalJSON(data []byte) error {
+	if err := checkFields("transaction", data, transactionFields, transactionOptionalFields); err != nil {
+		return err
+	}
+	var locking, unlocking string
 	v := &struct {
-		Sender    string  `json:"sender_blockchain_address"`
-		Recipient string  `json:"recipient_blockchain_address"`
-		Value     float32 `json:"value"`
+		Sender    *string            `json:"sender_blockchain_address"`
+		Recipient *string            `json:"recipient_blockchain_address"`
+		Value     *float32           `json:"value"`
+		LockTime  *int64             `json:"lock_time"`
+		Memo      *string            `json:"memo"`
+		Contract  **vm.Payload       `json:"contract"`
+		Token     **token.Payload    `json:"token"`
+		NFT       **token.NFTPayload `json:"nft"`
+		Locking   *string            `json:"locking_script"`
+		Unlocking *string            `json:"unlocking_script"`
 	}{
-		Sender:    t.senderBlockchainAddress,
-		Recipient: t.recipientBlockchainAddress,
-		Value:     t.value,
+		Sender:    &t.senderBlockchainAddress,
+		Recipient: &t.recipientBlockchainAddress,
+		Value:     &t.value,
+		LockTime:  &t.lockTime,
+		Memo:      &t.memo,
+		Contract:  &t.contract,
+		Token:     &t.token,
+		NFT:       &t.nft,
+		Locking:   &locking,
+		Unlocking: &unlocking,
 	}
 	if err := json.Unmarshal(data, &v); err != nil {
 		return err
 	}
+	var err error
+	if t.lockingScript, err = decodeHex("transaction.locking_script", locking); err != nil {
+		return err
+	}
+	if t.unlockingScript, err = decodeHex("transaction.unlocking_script", unlocking); err != nil {
+		return err
+	}
 	return nil
 }
 
 type TransactionRequest struct {
-	SenderBlockchainAddress    *string  `json:"sender_blockchain_address"`
-	RecipientBlockchainAddress *string  `json:"recipient_blockchain_address"`
-	SenderPublicKey            *string  `json:"sender_public_key"`
-	Value                      *float32 `json:"value"`
-	Signature                  *string  `json:"signature"`
+	SenderBlockchainAddress    *string           `json:"sender_blockchain_address"`
+	RecipientBlockchainAddress *string           `json:"recipient_blockchain_address"`
+	SenderPublicKey            *string           `json:"sender_public_key"`
+	Value                      *float32          `json:"value"`
+	Signature                  *string           `json:"signature"`
+	LockTime                   *int64            `json:"lock_time,omitempty"`
+	Memo                       *string           `json:"memo,omitempty"`
+	Contract                   *vm.Payload       `json:"contract,omitempty"`
+	Token                      *token.Payload    `json:"token,omitempty"`
+	NFT                        *token.NFTPayload `json:"nft,omitempty"`
+
+	// 다중서명 Transaction
+	MultisigRequired   *int     `json:"multisig_required,omitempty"`
+	MultisigPublicKeys []string `json:"multisig_public_keys,omitempty"`
+	Signatures         []string `json:"signatures,omitempty"`
+
+	// script 주소에서 보내는 Transaction (hex)
+	LockingScript   *string `json:"locking_script,omitempty"`
+	UnlockingScript *string `json:"unlocking_script,omitempty"`
+}
+
+func (tr *TransactionRequest) IsMultisig() bool {
+	return tr.MultisigRequired != nil
+}
+
+// 서명 대상이 되는 Transaction 내용
+func (tr *TransactionRequest) Transaction() *Transaction {
+	t := NewTransaction(*tr.SenderBlockchainAddress, *tr.RecipientBlockchainAddress, *tr.Value)
+	if tr.LockTime != nil {
+		t.SetLockTime(*tr.LockTime)
+	}
+	if tr.Memo != nil {
+		t.SetMemo(*tr.Memo)
+	}
+	t.SetContract(tr.Contract)
+	t.SetToken(tr.Token)
+	t.SetNFT(tr.NFT)
+	return t
+}
+
+func (tr *TransactionRequest) IsScript() bool {
+	return tr.LockingScript != nil
 }
 
 func (tr *TransactionRequest) Validate() bool {
+	if tr.IsScript() {
+		if tr.SenderBlockchainAddress == nil ||
+			tr.RecipientBlockchainAddress == nil ||
+			tr.Value == nil ||
+			tr.UnlockingScript == nil {
+			return false
+		}
+		return true
+	}
+
+	if tr.IsMultisig() {
+		if tr.SenderBlockchainAddress == nil ||
+			tr.RecipientBlockchainAddress == nil ||
+			tr.Value == nil ||
+			len(tr.MultisigPublicKeys) == 0 ||
+			len(tr.Signatures) != len(tr.MultisigPublicKeys) {
+			return false
+		}
+		return true
+	}
+
 	if tr.SenderBlockchainAddress == nil ||
 		tr.RecipientBlockchainAddress == nil ||
 		tr.SenderPublicKey == nil ||
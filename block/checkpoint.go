@@ -0,0 +1,44 @@
+package block
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Params.MaxReorgDepth의 기본값
+const MAX_REORG_DEPTH = 100
+
+var (
+	ErrCheckpointMismatch = errors.New("chain conflicts with checkpoint")
+	ErrReorgTooDeep       = errors.New("reorg deeper than max reorg depth")
+)
+
+// checkpoints: Block 높이 -> 반드시 있어야 하는 Block hash(hex)
+func checkCheckpoints(checkpoints map[int]string, chain []*Block) error {
+	for height, hash := range checkpoints {
+		if height < len(chain) && fmt.Sprintf("%x", chain[height].Hash()) != hash {
+			return fmt.Errorf("%w at height %d", ErrCheckpointMismatch, height)
+		}
+	}
+	return nil
+}
+
+// 현재 chain과 갈라지는 지점에서 되돌려야 하는 Block 수
+func (bc *Blockchain) reorgDepth(chain []*Block) int {
+	fork := 0
+	for fork < len(bc.chain) && fork < len(chain) && bc.chain[fork].Hash() == chain[fork].Hash() {
+		fork += 1
+	}
+	return len(bc.chain) - fork
+}
+
+// checkpoint와 다르거나 너무 깊게 되돌리는 chain은 받지 않는다
+func (bc *Blockchain) checkReorg(chain []*Block) error {
+	if err := checkCheckpoints(bc.params.Checkpoints, chain); err != nil {
+		return err
+	}
+	if depth := bc.reorgDepth(chain); depth > bc.params.MaxReorgDepth {
+		return fmt.Errorf("%w: %d blocks", ErrReorgTooDeep, depth)
+	}
+	return nil
+}
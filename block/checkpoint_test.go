@@ -0,0 +1,62 @@
+package block
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sw90lee/blockchain_study/clock"
+)
+
+// genesis가 같고 n개의 Block을 offset초 간격으로 더 만든 chain
+func forkedChain(n int, offset time.Duration) *Blockchain {
+	c := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	bc := NewBlockchainWithClock("miner", 5000, c)
+	bc.SetParams(testParams(1))
+	for i := 0; i < n; i++ {
+		c.Advance(offset)
+		bc.AddTransaction(NewTransaction(MINING_SENDER, "alice", 1), nil, nil)
+		bc.Mining()
+	}
+	return bc
+}
+
+func TestReorgTooDeep(t *testing.T) {
+	a, b := forkedChain(2, time.Second), forkedChain(3, 2*time.Second)
+	p := a.Params()
+	p.MaxReorgDepth = 1
+	a.SetParams(p)
+	if err := a.checkReorg(b.Chain()); !errors.Is(err, ErrReorgTooDeep) {
+		t.Fatalf("expected too deep, got %v", err)
+	}
+	if a.OfferChain(b.Chain(), "b") {
+		t.Fatal("too deep reorg accepted")
+	}
+
+	p.MaxReorgDepth = 2
+	a.SetParams(p)
+	if !a.OfferChain(b.Chain(), "b") {
+		t.Fatal("reorg within max depth rejected")
+	}
+}
+
+func TestCheckpointConflict(t *testing.T) {
+	a, b := forkedChain(2, time.Second), forkedChain(3, 2*time.Second)
+	p := a.Params()
+	p.Checkpoints = map[int]string{1: fmt.Sprintf("%x", a.Chain()[1].Hash())}
+	a.SetParams(p)
+	if err := a.checkReorg(b.Chain()); !errors.Is(err, ErrCheckpointMismatch) {
+		t.Fatalf("expected checkpoint mismatch, got %v", err)
+	}
+	if a.OfferChain(b.Chain(), "b") {
+		t.Fatal("chain conflicting with checkpoint accepted")
+	}
+
+	// checkpoint가 genesis뿐이면 받는다
+	p.Checkpoints = map[int]string{0: fmt.Sprintf("%x", a.Chain()[0].Hash())}
+	a.SetParams(p)
+	if !a.OfferChain(b.Chain(), "b") {
+		t.Fatal("chain matching checkpoint rejected")
+	}
+}
@@ -0,0 +1,65 @@
+package block
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sw90lee/blockchain_study/clock"
+)
+
+// 빠르게 Block을 만드는 Params, 나머지는 기본값
+func testParams(timerSec int) Params {
+	p := DefaultParams()
+	p.MiningDifficulty = 1
+	p.MiningTimerSec = timerSec
+	p.NeighborSyncSec = timerSec
+	return p
+}
+
+func mineAt(start time.Time) *Blockchain {
+	c := clock.NewFake(start)
+	bc := NewBlockchainWithClock("miner", 5000, c)
+	bc.SetParams(testParams(1))
+	bc.AddTransaction(NewTransaction(MINING_SENDER, "alice", 1), nil, nil)
+	c.Advance(time.Second)
+	bc.Mining()
+	return bc
+}
+
+func TestFakeClockBlocksAreReproducible(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	a, b := mineAt(start), mineAt(start)
+	if a.LastBlock().Hash() != b.LastBlock().Hash() {
+		t.Fatal("same clock produced different blocks")
+	}
+	if a.LastBlock().timestamp != start.Add(time.Second).UnixNano() {
+		t.Fatalf("unexpected timestamp %d", a.LastBlock().timestamp)
+	}
+	if c := mineAt(start.Add(time.Minute)); c.LastBlock().Hash() == a.LastBlock().Hash() {
+		t.Fatal("different clock produced the same block")
+	}
+}
+
+func TestMiningTimerFollowsClock(t *testing.T) {
+	c := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	bc := NewBlockchainWithClock("miner", 5000, c)
+	bc.SetParams(testParams(20))
+	bc.StartMining()
+
+	bc.AddTransaction(NewTransaction(MINING_SENDER, "alice", 1), nil, nil)
+	c.Advance(19 * time.Second)
+	if len(bc.Chain()) != 1 {
+		t.Fatal("mined before the timer")
+	}
+	c.Advance(time.Second)
+	if len(bc.Chain()) != 2 {
+		t.Fatal("timer did not mine")
+	}
+
+	bc.StopMining()
+	bc.AddTransaction(NewTransaction(MINING_SENDER, "alice", 1), nil, nil)
+	c.Advance(time.Minute)
+	if len(bc.Chain()) != 2 || c.Pending() != 0 {
+		t.Fatal("mining did not stop")
+	}
+}
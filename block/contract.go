@@ -0,0 +1,239 @@
+package block
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/sw90lee/blockchain_study/vm"
+)
+
+const (
+	RECEIPT_STATUS_SUCCESS = "success"
+	RECEIPT_STATUS_FAILED  = "failed"
+)
+
+// Block 안의 contract Transaction 실행 결과
+type Receipt struct {
+	index           int
+	contractAddress string
+	status          string
+	gasUsed         uint64
+	fee             float32
+	feeRecipient    string
+	returnData      []byte
+	logs            []*vm.Log
+	err             string
+}
+
+func (r *Receipt) Index() int {
+	return r.index
+}
+
+func (r *Receipt) ContractAddress() string {
+	return r.contractAddress
+}
+
+func (r *Receipt) Status() string {
+	return r.status
+}
+
+func (r *Receipt) GasUsed() uint64 {
+	return r.gasUsed
+}
+
+func (r *Receipt) Fee() float32 {
+	return r.fee
+}
+
+func (r *Receipt) Logs() []*vm.Log {
+	return r.logs
+}
+
+func (r *Receipt) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Index           int       `json:"index"`
+		ContractAddress string    `json:"contract_address"`
+		Status          string    `json:"status"`
+		GasUsed         uint64    `json:"gas_used"`
+		Fee             float32   `json:"fee"`
+		FeeRecipient    string    `json:"fee_recipient"`
+		Return          string    `json:"return,omitempty"`
+		Logs            []*vm.Log `json:"logs,omitempty"`
+		Error           string    `json:"error,omitempty"`
+	}{
+		Index:           r.index,
+		ContractAddress: r.contractAddress,
+		Status:          r.status,
+		GasUsed:         r.gasUsed,
+		Fee:             r.fee,
+		FeeRecipient:    r.feeRecipient,
+		Return:          hex.EncodeToString(r.returnData),
+		Logs:            r.logs,
+		Error:           r.err,
+	})
+}
+
+func (r *Receipt) UnmarshalJSON(data []byte) error {
+	var returnData string
+	v := &struct {
+		Index           *int       `json:"index"`
+		ContractAddress *string    `json:"contract_address"`
+		Status          *string    `json:"status"`
+		GasUsed         *uint64    `json:"gas_used"`
+		Fee             *float32   `json:"fee"`
+		FeeRecipient    *string    `json:"fee_recipient"`
+		Return          *string    `json:"return"`
+		Logs            *[]*vm.Log `json:"logs"`
+		Error           *string    `json:"error"`
+	}{
+		Index:           &r.index,
+		ContractAddress: &r.contractAddress,
+		Status:          &r.status,
+		GasUsed:         &r.gasUsed,
+		Fee:             &r.fee,
+		FeeRecipient:    &r.feeRecipient,
+		Return:          &returnData,
+		Logs:            &r.logs,
+		Error:           &r.err,
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	r.returnData, _ = hex.DecodeString(returnData)
+	return nil
+}
+
+func stateRootString(root [32]byte) string {
+	if root == [32]byte{} {
+		return ""
+	}
+	return fmt.Sprintf("%x", root)
+}
+
+func (b *Block) StateRoot() [32]byte {
+	return b.stateRoot
+}
+
+func (b *Block) Receipts() []*Receipt {
+	return b.receipts
+}
+
+// Block 보상을 받는 주소가 gas 수수료도 받는다
+func (b *Block) feeRecipient() string {
+	for _, t := range b.transactions {
+		if t.senderBlockchainAddress == MINING_SENDER {
+			return t.recipientBlockchainAddress
+		}
+	}
+	return ""
+}
+
+// pool에 넣기 전에 contract Transaction 내용 확인
+func (bc *Blockchain) ValidContractTransaction(t *Transaction) bool {
+	if t.contract == nil {
+		if t.recipientBlockchainAddress == CONTRACT_DEPLOY_RECIPIENT {
+			log.Println("ERROR: contract deploy without code")
+			return false
+		}
+		return true
+	}
+
+	p := t.contract
+	if t.value != 0 {
+		log.Println("ERROR: contract transaction can not carry value")
+		return false
+	}
+	if p.GasLimit() == 0 || p.GasLimit() > MAX_GAS_LIMIT || p.GasPrice() < 0 {
+		log.Println("ERROR: invalid gas")
+		return false
+	}
+	if p.IsDeploy() {
+		if t.recipientBlockchainAddress != CONTRACT_DEPLOY_RECIPIENT ||
+			len(p.Code()) > vm.MAX_CODE_SIZE {
+			log.Println("ERROR: invalid contract deploy")
+			return false
+		}
+	} else if bc.state.contracts.Contract(t.recipientBlockchainAddress) == nil {
+		log.Printf("ERROR: no contract at %s", t.recipientBlockchainAddress)
+		return false
+	}
+
+	fee := float32(p.GasLimit()) * p.GasPrice()
+	if bc.CalculateTotalAmount(t.senderBlockchainAddress)-bc.PendingAmount(t.senderBlockchainAddress) < fee {
+		log.Println("ERROR: Not enough balance for gas")
+		return false
+	}
+	return true
+}
+
+// height 높이 Block의 contract Transaction을 state에 적용
+func executeTransactions(state *vm.State, b *Block, height int) []*Receipt {
+	var receipts []*Receipt
+	feeRecipient := b.feeRecipient()
+	for i, t := range b.transactions {
+		p := t.contract
+		if p == nil {
+			continue
+		}
+
+		r := &Receipt{index: i, feeRecipient: feeRecipient}
+		var result *vm.Result
+		if p.IsDeploy() {
+			r.contractAddress = vm.ContractAddress(t.senderBlockchainAddress, height, i)
+			result = vm.Deploy(state, r.contractAddress, p.Code(), p.GasLimit())
+		} else {
+			r.contractAddress = t.recipientBlockchainAddress
+			result = vm.Execute(state, &vm.Context{
+				Caller:   t.senderBlockchainAddress,
+				Contract: t.recipientBlockchainAddress,
+				Input:    p.Input(),
+				Height:   int64(height),
+				GasLimit: p.GasLimit(),
+			})
+		}
+
+		r.gasUsed = result.GasUsed
+		r.fee = float32(result.GasUsed) * p.GasPrice()
+		r.returnData = result.Return
+		r.logs = result.Logs
+		if result.Err != nil {
+			r.status = RECEIPT_STATUS_FAILED
+			r.err = result.Err.Error()
+		} else {
+			r.status = RECEIPT_STATUS_SUCCESS
+		}
+		receipts = append(receipts, r)
+	}
+	return receipts
+}
+
+// chain 끝에 붙일 Block을 실행해 보고 receipt와 state root를 기록, 적용할 수 없는 Block이면 error
+func (bc *Blockchain) executeBlock(b *Block) error {
+	state := bc.state.Copy()
+	receipts, err := state.execute(b, len(bc.chain))
+	if err != nil {
+		return err
+	}
+	b.receipts = receipts
+	b.stateRoot = state.Root()
+	return nil
+}
+
+// 배포된 contract
+func (bc *Blockchain) Contracts() *vm.State {
+	return bc.state.contracts
+}
+
+// state를 바꾸지 않고 contract 호출 결과만 확인
+func (bc *Blockchain) CallContract(caller string, address string, input [][]byte,
+	gasLimit uint64) *vm.Result {
+	return vm.Execute(bc.state.contracts.Copy(), &vm.Context{
+		Caller:   caller,
+		Contract: address,
+		Input:    input,
+		Height:   int64(len(bc.chain)),
+		GasLimit: gasLimit,
+	})
+}
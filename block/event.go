@@ -0,0 +1,131 @@
+package block
+
+import (
+	"fmt"
+	"log"
+)
+
+const (
+	EVENT_TRANSACTION  = "transaction"
+	EVENT_BLOCK        = "block"
+	EVENT_REORG        = "reorg"
+	EVENT_MINING_START = "mining_start"
+	EVENT_MINING_STOP  = "mining_stop"
+
+	EVENT_SOURCE_LOCAL = "local"
+	EVENT_SOURCE_PEER  = "peer"
+
+	// 구독자가 이만큼 밀리면 그 뒤의 event는 버린다
+	EVENT_BUFFER_SIZE = 64
+)
+
+// /events로 보내는 알림, Type에 따라 필요한 field만 채워진다
+type Event struct {
+	Type        string       `json:"type"`
+	Time        int64        `json:"time"`
+	Height      int          `json:"height,omitempty"`
+	Hash        string       `json:"hash,omitempty"`
+	Source      string       `json:"source,omitempty"`
+	Header      *Block       `json:"header,omitempty"`
+	Transaction *Transaction `json:"transaction,omitempty"`
+	// reorg에서 버려진 Block 수
+	Dropped   int      `json:"dropped,omitempty"`
+	Addresses []string `json:"addresses,omitempty"`
+}
+
+func newTransactionEvent(t *Transaction) *Event {
+	return &Event{
+		Type:        EVENT_TRANSACTION,
+		Hash:        fmt.Sprintf("%x", t.Hash()),
+		Transaction: t,
+		Addresses:   []string{t.senderBlockchainAddress, t.recipientBlockchainAddress},
+	}
+}
+
+func newBlockEvent(b *Block, height int, source string) *Event {
+	e := &Event{
+		Type:   EVENT_BLOCK,
+		Height: height,
+		Hash:   fmt.Sprintf("%x", b.Hash()),
+		Source: source,
+		Header: b.Header(),
+	}
+	seen := make(map[string]bool)
+	for _, t := range b.transactions {
+		for _, address := range []string{t.senderBlockchainAddress, t.recipientBlockchainAddress} {
+			if !seen[address] {
+				seen[address] = true
+				e.Addresses = append(e.Addresses, address)
+			}
+		}
+	}
+	return e
+}
+
+// address가 비어 있으면 모든 event, 아니면 그 주소가 들어간 event만
+func (e *Event) Match(types map[string]bool, address string) bool {
+	if len(types) > 0 && !types[e.Type] {
+		return false
+	}
+	if address == "" || e.Type == EVENT_REORG ||
+		e.Type == EVENT_MINING_START || e.Type == EVENT_MINING_STOP {
+		return true
+	}
+	for _, a := range e.Addresses {
+		if a == address {
+			return true
+		}
+	}
+	return false
+}
+
+func (bc *Blockchain) Subscribe() (int, <-chan *Event) {
+	bc.muxSubscribers.Lock()
+	defer bc.muxSubscribers.Unlock()
+	id := bc.nextSubscriber
+	bc.nextSubscriber++
+	ch := make(chan *Event, EVENT_BUFFER_SIZE)
+	bc.subscribers[id] = ch
+	return id, ch
+}
+
+func (bc *Blockchain) Unsubscribe(id int) {
+	bc.muxSubscribers.Lock()
+	defer bc.muxSubscribers.Unlock()
+	if ch, ok := bc.subscribers[id]; ok {
+		delete(bc.subscribers, id)
+		close(ch)
+	}
+}
+
+// 받는 쪽이 느려도 chain 처리가 멈추지 않도록 기다리지 않는다
+func (bc *Blockchain) publish(e *Event) {
+	bc.muxSubscribers.Lock()
+	defer bc.muxSubscribers.Unlock()
+	if e.Time == 0 {
+		e.Time = bc.clock.Now().UnixNano()
+	}
+	for id, ch := range bc.subscribers {
+		select {
+		case ch <- e:
+		default:
+			log.Printf("action=drop_event, subscriber=%d, type=%s", id, e.Type)
+		}
+	}
+}
+
+// chain이 바뀐 뒤 fork 높이부터 새로 붙은 Block을 알린다
+func (bc *Blockchain) publishChain(fork int, dropped int, source string) {
+	if dropped > 0 {
+		bc.publish(&Event{
+			Type:    EVENT_REORG,
+			Height:  fork,
+			Hash:    fmt.Sprintf("%x", bc.LastBlock().Hash()),
+			Source:  source,
+			Dropped: dropped,
+		})
+	}
+	for i := fork; i < len(bc.chain); i++ {
+		bc.publish(newBlockEvent(bc.chain[i], i, source))
+	}
+}
@@ -0,0 +1,127 @@
+package block
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sw90lee/blockchain_study/wallet"
+)
+
+func TestEventMatch(t *testing.T) {
+	transfer := newTransactionEvent(NewTransaction("alice", "bob", 1))
+	b := newBlockAt(GENESIS_TIME, 0, [32]byte{}, []*Transaction{
+		NewTransaction(MINING_SENDER, "carol", 1), NewTransaction("carol", "alice", 1)})
+	mined := newBlockEvent(b, 1, EVENT_SOURCE_LOCAL)
+	reorg := &Event{Type: EVENT_REORG}
+	start := &Event{Type: EVENT_MINING_START}
+	blocks := map[string]bool{EVENT_BLOCK: true}
+
+	for _, c := range []struct {
+		event   *Event
+		types   map[string]bool
+		address string
+		match   bool
+	}{
+		{transfer, nil, "", true},
+		{transfer, nil, "bob", true},
+		{transfer, nil, "carol", false},
+		{transfer, blocks, "", false},
+		{mined, blocks, "carol", true},
+		{mined, blocks, "alice", true},
+		{mined, blocks, "bob", false},
+		{mined, map[string]bool{EVENT_TRANSACTION: true, EVENT_REORG: true}, "", false},
+		// 주소가 없는 event는 주소로 거르지 않는다
+		{reorg, nil, "bob", true},
+		{reorg, blocks, "", false},
+		{start, nil, "bob", true},
+	} {
+		if c.event.Match(c.types, c.address) != c.match {
+			t.Errorf("%s %v %q: expected %v", c.event.Type, c.types, c.address, c.match)
+		}
+	}
+	if len(mined.Addresses) != 3 {
+		t.Fatalf("duplicated addresses %v", mined.Addresses)
+	}
+}
+
+func nextEvent(t *testing.T, events <-chan *Event) *Event {
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+	}
+	return nil
+}
+
+func TestTransactionAndBlockEvents(t *testing.T) {
+	bc := newTestChain()
+	alice := wallet.NewWallet()
+	fund(t, bc, alice.BlockchainAddress(), 5)
+	_, events := bc.Subscribe()
+	sendSigned(t, bc, alice, "bob", 1)
+
+	e := nextEvent(t, events)
+	if e.Type != EVENT_TRANSACTION || !e.Match(nil, "bob") || e.Time == 0 {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	e = nextEvent(t, events)
+	if e.Type != EVENT_BLOCK || e.Height != 2 || e.Source != EVENT_SOURCE_LOCAL || !e.Header.IsPruned() ||
+		e.Hash != fmt.Sprintf("%x", bc.LastBlock().Hash()) || !e.Match(nil, alice.BlockchainAddress()) {
+		t.Fatalf("unexpected event %+v", e)
+	}
+}
+
+// 다른 chain으로 바뀌면 버려진 Block 수를 알린 뒤 새로 붙은 Block을 차례로 알린다
+func TestReorgEvents(t *testing.T) {
+	a, b := forkedChain(2, time.Second), forkedChain(3, 2*time.Second)
+	_, events := a.Subscribe()
+	if !a.OfferChain(b.Chain(), "b") {
+		t.Fatal("longer chain rejected")
+	}
+
+	e := nextEvent(t, events)
+	if e.Type != EVENT_REORG || e.Height != 1 || e.Dropped != 2 || e.Source != EVENT_SOURCE_PEER ||
+		e.Hash != fmt.Sprintf("%x", tip(b)) {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	for height := 1; height <= 3; height++ {
+		e = nextEvent(t, events)
+		if e.Type != EVENT_BLOCK || e.Height != height || e.Source != EVENT_SOURCE_PEER ||
+			e.Hash != fmt.Sprintf("%x", b.Chain()[height].Hash()) {
+			t.Fatalf("unexpected event %+v", e)
+		}
+	}
+	select {
+	case e := <-events:
+		t.Fatalf("unexpected event %+v", e)
+	default:
+	}
+}
+
+// 읽지 않는 구독자가 있어도 publish는 멈추지 않고, 넘친 event는 그 구독자에게서만 버려진다
+func TestDropEventsWhenBufferFull(t *testing.T) {
+	bc := newTestChain()
+	slow, slowEvents := bc.Subscribe()
+	_, events := bc.Subscribe()
+
+	for i := 0; i < EVENT_BUFFER_SIZE+5; i++ {
+		bc.publish(&Event{Type: EVENT_TRANSACTION, Height: i})
+		if e := nextEvent(t, events); e.Height != i {
+			t.Fatalf("event %d received as %d", i, e.Height)
+		}
+	}
+
+	bc.Unsubscribe(slow)
+	received := 0
+	for e := range slowEvents {
+		if e.Height != received {
+			t.Fatalf("event %d received as %d", received, e.Height)
+		}
+		received += 1
+	}
+	if received != EVENT_BUFFER_SIZE {
+		t.Fatalf("%d events kept", received)
+	}
+}
@@ -0,0 +1,156 @@
+package block
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+)
+
+const (
+	LATEST_HEADERS_LIMIT = 100
+
+	SEARCH_TYPE_BLOCK       = "block"
+	SEARCH_TYPE_TRANSACTION = "transaction"
+	SEARCH_TYPE_ADDRESS     = "address"
+)
+
+// 높이와 hash를 함께 보여주는 Block
+type BlockResponse struct {
+	Height int    `json:"height"`
+	Hash   string `json:"hash"`
+	Block  *Block `json:"block"`
+}
+
+type ChainSummaryResponse struct {
+	Height      int     `json:"height"`
+	TipHash     string  `json:"tip_hash"`
+	Consensus   string  `json:"consensus"`
+	Difficulty  int     `json:"difficulty"`
+	TotalWork   uint64  `json:"total_work"`
+	TotalSupply float32 `json:"total_supply"`
+}
+
+// type에 따라 block, transactions, address 중 하나만 채워진다
+type SearchResponse struct {
+	Query        string                `json:"query"`
+	Type         string                `json:"type"`
+	Block        *BlockResponse        `json:"block,omitempty"`
+	Transactions []*AddressTransaction `json:"transactions,omitempty"`
+	Address      string                `json:"address,omitempty"`
+}
+
+func (bc *Blockchain) blockResponse(height int) *BlockResponse {
+	b := bc.chain[height]
+	return &BlockResponse{
+		Height: height,
+		Hash:   fmt.Sprintf("%x", b.Hash()),
+		Block:  b,
+	}
+}
+
+// explorer 조회는 mining, prune, 받은 chain과 겹치지 않도록 Chain()처럼 bc.mux를 잡는다
+func (bc *Blockchain) BlockByHeight(height int) (*BlockResponse, bool) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	return bc.blockByHeight(height)
+}
+
+func (bc *Blockchain) blockByHeight(height int) (*BlockResponse, bool) {
+	if height < 0 || height >= len(bc.chain) {
+		return nil, false
+	}
+	return bc.blockResponse(height), true
+}
+
+func (bc *Blockchain) BlockByHash(hash [32]byte) (*BlockResponse, bool) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	return bc.blockByHash(hash)
+}
+
+func (bc *Blockchain) blockByHash(hash [32]byte) (*BlockResponse, bool) {
+	for i := len(bc.chain) - 1; i >= 0; i-- {
+		if bc.chain[i].Hash() == hash {
+			return bc.blockResponse(i), true
+		}
+	}
+	return nil, false
+}
+
+// 마지막 n개 Block의 header, 최신 Block이 먼저
+func (bc *Blockchain) LatestHeaders(n int) []*BlockResponse {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	headers := []*BlockResponse{}
+	for i := len(bc.chain) - 1; i >= 0 && len(headers) < n; i-- {
+		b := bc.chain[i]
+		headers = append(headers, &BlockResponse{
+			Height: i,
+			Hash:   fmt.Sprintf("%x", b.Hash()),
+			Block:  b.Header(),
+		})
+	}
+	return headers
+}
+
+// 작업 증명은 Block마다 16^difficulty번, 지분 증명은 Block 수
+func (bc *Blockchain) Difficulty() int {
+	if bc.consensus == CONSENSUS_PROOF_OF_STAKE {
+		return 0
+	}
+	return bc.params.MiningDifficulty
+}
+
+func (bc *Blockchain) Summary() *ChainSummaryResponse {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	difficulty := bc.Difficulty()
+	work := uint64(1) << (4 * uint(difficulty))
+	return &ChainSummaryResponse{
+		Height:      len(bc.chain) - 1,
+		TipHash:     fmt.Sprintf("%x", bc.LastBlock().Hash()),
+		Consensus:   bc.consensus,
+		Difficulty:  difficulty,
+		TotalWork:   work * uint64(len(bc.chain)-1),
+		TotalSupply: -bc.state.Balance(MINING_SENDER),
+	}
+}
+
+// 숫자는 Block 높이, 64자리 hex는 Block이나 Transaction hash, 나머지는 주소
+func (bc *Blockchain) Search(q string) (*SearchResponse, bool) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	result := &SearchResponse{Query: q}
+	if height, err := strconv.Atoi(q); err == nil {
+		b, ok := bc.blockByHeight(height)
+		if !ok {
+			return nil, false
+		}
+		result.Type = SEARCH_TYPE_BLOCK
+		result.Block = b
+		return result, true
+	}
+
+	if h, err := hex.DecodeString(q); err == nil && len(h) == 32 {
+		var hash [32]byte
+		copy(hash[:], h)
+		if b, ok := bc.blockByHash(hash); ok {
+			result.Type = SEARCH_TYPE_BLOCK
+			result.Block = b
+			return result, true
+		}
+		if transactions := bc.addressIndex.Lookup(hash); len(transactions) > 0 {
+			result.Type = SEARCH_TYPE_TRANSACTION
+			result.Transactions = copyAddressTransactions(transactions)
+			return result, true
+		}
+		return nil, false
+	}
+
+	if bc.addressIndex.Has(q) || bc.state.Balance(q) != 0 {
+		result.Type = SEARCH_TYPE_ADDRESS
+		result.Address = q
+		return result, true
+	}
+	return nil, false
+}
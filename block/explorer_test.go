@@ -0,0 +1,54 @@
+package block
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sw90lee/blockchain_study/bloom"
+)
+
+// Block을 만들고 정리하는 동안에도 explorer와 filter 조회를 다른 goroutine에서 할 수 있다
+func TestExplorerReadsWhileMining(t *testing.T) {
+	bc := forkedChain(1, time.Second)
+	bc.SetPruneDepth(2)
+	f := bloom.New(1, 0.01, 0)
+	f.Add([]byte("alice"))
+	id, err := bc.LoadFilter(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 20; i++ {
+			// HTTP handler가 쓰는 AddTransactionRequest처럼 lock을 잡고 pool에 넣는다
+			bc.mux.Lock()
+			bc.AddTransaction(NewTransaction(MINING_SENDER, "alice", 1), nil, nil)
+			bc.mux.Unlock()
+			bc.Mining()
+		}
+		close(done)
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		if _, ok := bc.BlockByHeight(0); !ok {
+			t.Fatal("genesis block not found")
+		}
+		bc.BlockByHash([32]byte{})
+		bc.LatestHeaders(LATEST_HEADERS_LIMIT)
+		bc.Summary()
+		bc.Search("alice")
+		bc.Search(fmt.Sprintf("%x", NewTransaction(MINING_SENDER, "alice", 1).Hash()))
+		bc.FilteredBlocks(id, bc.PrunedHeight(), 10)
+		bc.FilteredTransactions(id)
+	}
+
+	if s := bc.Summary(); s.Height != 21 {
+		t.Fatalf("unexpected summary %+v", s)
+	}
+}
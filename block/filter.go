@@ -0,0 +1,145 @@
+package block
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sw90lee/blockchain_study/bloom"
+)
+
+const (
+	MAX_FILTERS         = 100
+	FILTER_BLOCKS_LIMIT = 100
+	// 이 동안 쓰지 않은 filter는 지운다, client는 404를 받으면 다시 올린다
+	FILTER_IDLE_SEC = 600
+)
+
+var (
+	ErrUnknownFilter = errors.New("unknown filter")
+	ErrTooManyFilter = errors.New("too many filters")
+)
+
+// filter에 걸린 Transaction만 Merkle proof와 함께 담은 Block
+type FilteredBlock struct {
+	Height int                 `json:"height"`
+	Hash   string              `json:"hash"`
+	Header *Block              `json:"header"`
+	Proofs []*TransactionProof `json:"proofs"`
+}
+
+// 등록된 filter와 마지막으로 쓴 시각
+type loadedFilter struct {
+	filter   *bloom.Filter
+	lastUsed time.Time
+}
+
+// sender, recipient 주소나 Transaction hash가 filter에 걸리는지
+func (t *Transaction) MatchFilter(f *bloom.Filter) bool {
+	h := t.Hash()
+	return f.Test([]byte(t.senderBlockchainAddress)) ||
+		f.Test([]byte(t.recipientBlockchainAddress)) ||
+		f.Test(h[:])
+}
+
+// light client의 filter를 등록하고 id를 돌려준다
+// FILTER_IDLE_SEC 동안 쓰지 않은 filter는 자리를 비운다
+func (bc *Blockchain) LoadFilter(f *bloom.Filter) (string, error) {
+	bc.muxFilters.Lock()
+	defer bc.muxFilters.Unlock()
+	now := bc.clock.Now()
+	for id, lf := range bc.filters {
+		if now.Sub(lf.lastUsed) >= FILTER_IDLE_SEC*time.Second {
+			delete(bc.filters, id)
+		}
+	}
+	if len(bc.filters) >= MAX_FILTERS {
+		return "", ErrTooManyFilter
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := fmt.Sprintf("%x", b)
+	bc.filters[id] = &loadedFilter{filter: f, lastUsed: now}
+	return id, nil
+}
+
+func (bc *Blockchain) RemoveFilter(id string) {
+	bc.muxFilters.Lock()
+	defer bc.muxFilters.Unlock()
+	delete(bc.filters, id)
+}
+
+func (bc *Blockchain) filter(id string) (*bloom.Filter, error) {
+	bc.muxFilters.Lock()
+	defer bc.muxFilters.Unlock()
+	lf, ok := bc.filters[id]
+	if !ok {
+		return nil, ErrUnknownFilter
+	}
+	now := bc.clock.Now()
+	if now.Sub(lf.lastUsed) >= FILTER_IDLE_SEC*time.Second {
+		delete(bc.filters, id)
+		return nil, ErrUnknownFilter
+	}
+	lf.lastUsed = now
+	return lf.filter, nil
+}
+
+// from 높이부터 limit개 Block에서 filter에 걸린 Transaction
+func (bc *Blockchain) FilteredBlocks(id string, from int, limit int) ([]*FilteredBlock, error) {
+	f, err := bc.filter(id)
+	if err != nil {
+		return nil, err
+	}
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	if from < bc.prunedHeight || from < 0 {
+		return nil, ErrPrunedBlock
+	}
+
+	blocks := []*FilteredBlock{}
+	for height := from; height < len(bc.chain) && len(blocks) < limit; height++ {
+		b := bc.chain[height]
+		fb := &FilteredBlock{
+			Height: height,
+			Hash:   fmt.Sprintf("%x", b.Hash()),
+			Header: b.Header(),
+			Proofs: []*TransactionProof{},
+		}
+		for i, t := range b.transactions {
+			if !t.MatchFilter(f) {
+				continue
+			}
+			fb.Proofs = append(fb.Proofs, &TransactionProof{
+				Height:      height,
+				BlockHash:   fb.Hash,
+				Transaction: t,
+				Proof:       b.MerkleProof(i),
+			})
+		}
+		blocks = append(blocks, fb)
+	}
+	return blocks, nil
+}
+
+// 아직 Block에 들어가지 않은 Transaction 중 filter에 걸린 것
+func (bc *Blockchain) FilteredTransactions(id string) ([]*Transaction, error) {
+	f, err := bc.filter(id)
+	if err != nil {
+		return nil, err
+	}
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	transactions := []*Transaction{}
+	for _, pool := range [][]*Transaction{bc.transactionPool, bc.lockedPool} {
+		for _, t := range pool {
+			if t.MatchFilter(f) {
+				transactions = append(transactions, t)
+			}
+		}
+	}
+	return transactions, nil
+}
@@ -0,0 +1,42 @@
+package block
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sw90lee/blockchain_study/bloom"
+	"github.com/sw90lee/blockchain_study/clock"
+)
+
+// 쓰지 않은 filter는 FILTER_IDLE_SEC 뒤에 자리를 비운다
+func TestFilterIdleEviction(t *testing.T) {
+	c := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	bc := NewBlockchainWithClock("miner", 5000, c)
+	ids := []string{}
+	for i := 0; i < MAX_FILTERS; i++ {
+		id, err := bc.LoadFilter(bloom.New(1, 0.01, uint32(i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if _, err := bc.LoadFilter(bloom.New(1, 0.01, 0)); !errors.Is(err, ErrTooManyFilter) {
+		t.Fatalf("expected too many filters, got %v", err)
+	}
+
+	c.Advance(FILTER_IDLE_SEC * time.Second / 2)
+	if _, err := bc.FilteredTransactions(ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	c.Advance(FILTER_IDLE_SEC * time.Second / 2)
+	if _, err := bc.LoadFilter(bloom.New(1, 0.01, 0)); err != nil {
+		t.Fatalf("idle filters not evicted: %v", err)
+	}
+	if _, err := bc.FilteredTransactions(ids[0]); err != nil {
+		t.Fatalf("used filter evicted: %v", err)
+	}
+	if _, err := bc.FilteredTransactions(ids[1]); !errors.Is(err, ErrUnknownFilter) {
+		t.Fatalf("expected unknown filter, got %v", err)
+	}
+}
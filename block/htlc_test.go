@@ -0,0 +1,171 @@
+package block
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/sw90lee/blockchain_study/script"
+	"github.com/sw90lee/blockchain_study/wallet"
+)
+
+// 보상 Transaction으로 address에 돈을 넣고 Block을 하나 만든다
+func fund(t *testing.T, bc *Blockchain, address string, value float32) {
+	bc.AddTransaction(NewTransaction(MINING_SENDER, address, value), nil, nil)
+	if !bc.Mining() {
+		t.Fatal("mining failed")
+	}
+}
+
+func sendSigned(t *testing.T, bc *Blockchain, w *wallet.Wallet, recipient string, value float32) {
+	s := wallet.NewTransaction(w.PrivateKey(), w.PublicKey(),
+		w.BlockchainAddress(), recipient, value).GenerateSignature()
+	if !bc.AddTransaction(NewTransaction(w.BlockchainAddress(), recipient, value), w.PublicKey(), s) {
+		t.Fatal("transaction rejected")
+	}
+	if !bc.Mining() {
+		t.Fatal("mining failed")
+	}
+}
+
+func spendHTLC(bc *Blockchain, w *wallet.Wallet, lockingScript []byte, value float32,
+	preimage []byte) bool {
+	htlcAddress := script.Address(lockingScript)
+	s := wallet.NewTransaction(w.PrivateKey(), w.PublicKey(),
+		htlcAddress, w.BlockchainAddress(), value).GenerateSignature()
+	var unlockingScript []byte
+	if preimage != nil {
+		unlockingScript, _ = script.HTLCRedeemScript(s, preimage)
+	} else {
+		unlockingScript, _ = script.HTLCRefundScript(s)
+	}
+	return bc.AddScriptTransaction(NewTransaction(htlcAddress, w.BlockchainAddress(), value),
+		lockingScript, unlockingScript)
+}
+
+func TestHTLCAtomicSwap(t *testing.T) {
+	alice := wallet.NewWallet()
+	bob := wallet.NewWallet()
+
+	// 서로 다른 genesis를 가진 두 노드
+	chainA := NewBlockchain("miner-a", 5000)
+	chainB := NewBlockchain("miner-b", 5001)
+	fund(t, chainA, alice.BlockchainAddress(), 10)
+	fund(t, chainB, bob.BlockchainAddress(), 10)
+
+	preimage := make([]byte, 32)
+	rand.Read(preimage)
+	hash := sha256.Sum256(preimage)
+
+	// Alice가 먼저 더 긴 timeout으로 chain A에 잠그고, Bob은 짧은 timeout으로 chain B에 잠근다
+	htlcA, err := script.HTLCScript(hash, bob.PublicKey(), alice.PublicKey(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendSigned(t, chainA, alice, script.Address(htlcA), 5)
+
+	htlcB, err := script.HTLCScript(hash, alice.PublicKey(), bob.PublicKey(), 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendSigned(t, chainB, bob, script.Address(htlcB), 4)
+
+	if spendHTLC(chainB, bob, htlcB, 4, nil) {
+		t.Fatal("refund accepted before timeout")
+	}
+	if spendHTLC(chainB, alice, htlcB, 4, []byte("wrong preimage")) {
+		t.Fatal("redeem accepted with wrong preimage")
+	}
+
+	// Alice가 chain B에서 preimage를 공개하며 가져간다
+	if !spendHTLC(chainB, alice, htlcB, 4, preimage) {
+		t.Fatal("alice could not redeem on chain B")
+	}
+	if !chainB.Mining() {
+		t.Fatal("mining failed")
+	}
+
+	// Bob은 chain B에 기록된 unlocking script에서 preimage를 알아낸다
+	var revealed []byte
+	for _, b := range chainB.Chain() {
+		for _, tx := range b.Transaction() {
+			if tx.senderBlockchainAddress == script.Address(htlcB) {
+				revealed, _ = script.HTLCPreimage(tx.UnlockingScript())
+			}
+		}
+	}
+	if revealed == nil {
+		t.Fatal("preimage not found on chain B")
+	}
+	if !spendHTLC(chainA, bob, htlcA, 5, revealed) {
+		t.Fatal("bob could not redeem on chain A")
+	}
+	if !chainA.Mining() {
+		t.Fatal("mining failed")
+	}
+
+	if got := chainA.CalculateTotalAmount(bob.BlockchainAddress()); got != 5 {
+		t.Fatalf("bob on chain A = %v, want 5", got)
+	}
+	if got := chainB.CalculateTotalAmount(alice.BlockchainAddress()); got != 4 {
+		t.Fatalf("alice on chain B = %v, want 4", got)
+	}
+	if got := chainA.CalculateTotalAmount(script.Address(htlcA)); got != 0 {
+		t.Fatalf("htlc on chain A = %v, want 0", got)
+	}
+}
+
+func TestHTLCRefundAfterTimeout(t *testing.T) {
+	alice := wallet.NewWallet()
+	bob := wallet.NewWallet()
+	bc := NewBlockchain("miner", 5000)
+	fund(t, bc, alice.BlockchainAddress(), 10)
+
+	preimage := []byte("secret")
+	htlc, _ := script.HTLCScript(sha256.Sum256(preimage), bob.PublicKey(), alice.PublicKey(), 4)
+	sendSigned(t, bc, alice, script.Address(htlc), 5)
+
+	if spendHTLC(bc, alice, htlc, 5, nil) {
+		t.Fatal("refund accepted before timeout")
+	}
+	fund(t, bc, "someone", 1)
+	if !spendHTLC(bc, alice, htlc, 5, nil) {
+		t.Fatal("refund rejected after timeout")
+	}
+	if spendHTLC(bc, bob, htlc, 5, preimage) {
+		t.Fatal("redeem accepted after refund")
+	}
+}
+
+// pool을 거치지 않고 Block에 들어간 script Transaction도 chain 검증에서 다시 실행한다
+func TestChainRejectsUnsatisfiedScript(t *testing.T) {
+	alice := wallet.NewWallet()
+	bob := wallet.NewWallet()
+	bc := newTestChain()
+	fund(t, bc, alice.BlockchainAddress(), 10)
+
+	preimage := []byte("secret")
+	htlc, _ := script.HTLCScript(sha256.Sum256(preimage), bob.PublicKey(), alice.PublicKey(), 100)
+	sendSigned(t, bc, alice, script.Address(htlc), 5)
+
+	// timeout 전의 refund
+	refund := NewTransaction(script.Address(htlc), alice.BlockchainAddress(), 5)
+	s := wallet.NewTransaction(alice.PrivateKey(), alice.PublicKey(),
+		script.Address(htlc), alice.BlockchainAddress(), 5).GenerateSignature()
+	refund.lockingScript = htlc
+	refund.unlockingScript, _ = script.HTLCRefundScript(s)
+	if bc.AddScriptTransaction(refund, refund.lockingScript, refund.unlockingScript) {
+		t.Fatal("refund accepted before timeout")
+	}
+	if !bc.ValidChain(bc.Chain()) {
+		t.Fatal("valid chain rejected")
+	}
+
+	bc.transactionPool = append(bc.transactionPool, refund)
+	if !bc.Mining() {
+		t.Fatal("mining failed")
+	}
+	if bc.ValidChain(bc.Chain()) {
+		t.Fatal("chain with unsatisfied script accepted")
+	}
+}
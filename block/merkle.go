@@ -0,0 +1,129 @@
+package block
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// Transaction 하나가 Block에 들어 있음을 보이는 Merkle tree의 형제 hash들
+type MerkleProof struct {
+	index    int
+	siblings [][32]byte
+}
+
+func (p *MerkleProof) Index() int {
+	return p.index
+}
+
+// leaf부터 올라가며 root를 다시 계산해 비교
+func (p *MerkleProof) Verify(leaf [32]byte, root [32]byte) bool {
+	h, index := leaf, p.index
+	for _, sibling := range p.siblings {
+		if index%2 == 0 {
+			h = hashPair(h, sibling)
+		} else {
+			h = hashPair(sibling, h)
+		}
+		index /= 2
+	}
+	return index == 0 && h == root
+}
+
+func (p *MerkleProof) MarshalJSON() ([]byte, error) {
+	siblings := make([]string, len(p.siblings))
+	for i, s := range p.siblings {
+		siblings[i] = fmt.Sprintf("%x", s)
+	}
+	return json.Marshal(struct {
+		Index    int      `json:"index"`
+		Siblings []string `json:"siblings"`
+	}{
+		Index:    p.index,
+		Siblings: siblings,
+	})
+}
+
+func (p *MerkleProof) UnmarshalJSON(data []byte) error {
+	var siblings []string
+	v := &struct {
+		Index    *int      `json:"index"`
+		Siblings *[]string `json:"siblings"`
+	}{
+		Index:    &p.index,
+		Siblings: &siblings,
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	p.siblings = make([][32]byte, len(siblings))
+	for i, s := range siblings {
+		h, err := hex.DecodeString(s)
+		if err != nil || len(h) != 32 {
+			return fmt.Errorf("invalid merkle sibling %q", s)
+		}
+		copy(p.siblings[i][:], h)
+	}
+	return nil
+}
+
+func hashPair(left [32]byte, right [32]byte) [32]byte {
+	return sha256.Sum256(append(left[:], right[:]...))
+}
+
+// 아래에서부터 한 층씩, 개수가 홀수면 마지막 hash를 복사해서 짝을 맞춘다
+func merkleLevels(leaves [][32]byte) [][][32]byte {
+	levels := [][][32]byte{leaves}
+	for level := leaves; len(level) > 1; {
+		next := make([][32]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 < len(level) {
+				next = append(next, hashPair(level[i], level[i+1]))
+			} else {
+				next = append(next, hashPair(level[i], level[i]))
+			}
+		}
+		levels = append(levels, next)
+		level = next
+	}
+	return levels
+}
+
+func merkleProof(levels [][][32]byte, index int) *MerkleProof {
+	p := &MerkleProof{index: index}
+	for _, level := range levels[:len(levels)-1] {
+		sibling := index ^ 1
+		if sibling >= len(level) {
+			sibling = index
+		}
+		p.siblings = append(p.siblings, level[sibling])
+		index /= 2
+	}
+	return p
+}
+
+func transactionLeaves(transactions []*Transaction) [][32]byte {
+	leaves := make([][32]byte, len(transactions))
+	for i, t := range transactions {
+		leaves[i] = t.Hash()
+	}
+	return leaves
+}
+
+// Transaction이 없으면 0
+func transactionsRoot(transactions []*Transaction) [32]byte {
+	if len(transactions) == 0 {
+		return [32]byte{}
+	}
+	levels := merkleLevels(transactionLeaves(transactions))
+	return levels[len(levels)-1][0]
+}
+
+// index 번째 Transaction의 proof, body를 버린 Block이면 nil
+func (b *Block) MerkleProof(index int) *MerkleProof {
+	if b.pruned || index < 0 || index >= len(b.transactions) {
+		return nil
+	}
+	return merkleProof(merkleLevels(transactionLeaves(b.transactions)), index)
+}
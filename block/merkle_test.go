@@ -0,0 +1,61 @@
+package block
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sw90lee/blockchain_study/wallet"
+)
+
+func signedTransaction(w *wallet.Wallet, recipient string, value float32) (*Transaction, *wallet.Transaction) {
+	return NewTransaction(w.BlockchainAddress(), recipient, value),
+		wallet.NewTransaction(w.PrivateKey(), w.PublicKey(), w.BlockchainAddress(), recipient, value)
+}
+
+// 마지막 Transaction을 한 번 더 넣어도 Merkle root와 작업 증명은 그대로이므로 중복을 거절해야 한다
+func TestDuplicateTransactionRejected(t *testing.T) {
+	bc := newTestChain()
+	alice := wallet.NewWallet()
+	fund(t, bc, alice.BlockchainAddress(), 10)
+
+	for _, recipient := range []string{"bob", "carol"} {
+		tx, wt := signedTransaction(alice, recipient, 1)
+		if !bc.AddTransaction(tx, alice.PublicKey(), wt.GenerateSignature()) {
+			t.Fatal("transaction rejected")
+		}
+	}
+	tx, wt := signedTransaction(alice, "bob", 1)
+	if bc.AddTransaction(tx, alice.PublicKey(), wt.GenerateSignature()) {
+		t.Fatal("duplicate accepted into the pool")
+	}
+	if !bc.Mining() {
+		t.Fatal("mining failed")
+	}
+
+	chain := bc.Chain()
+	last := chain[len(chain)-1]
+	if len(last.transactions) != 3 {
+		t.Fatalf("%d transactions", len(last.transactions))
+	}
+	forged := *last
+	forged.transactions = append(append([]*Transaction{}, last.transactions...), last.transactions[2])
+	if transactionsRoot(forged.transactions) != transactionsRoot(last.transactions) {
+		t.Fatal("expected the odd level padding to collide")
+	}
+	if !bc.ValidProof(forged.nonce, forged.previousHash, forged.transactions, bc.params.MiningDifficulty) {
+		t.Fatal("expected the proof of work to still hold")
+	}
+
+	state := NewState()
+	for i, b := range chain[:len(chain)-1] {
+		if err := state.applyBlock(b, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := state.Copy().execute(&forged, len(chain)-1); !errors.Is(err, ErrDuplicateTransaction) {
+		t.Fatalf("expected duplicate transaction, got %v", err)
+	}
+	if bc.ValidChain(append(chain[:len(chain)-1:len(chain)-1], &forged)) {
+		t.Fatal("chain with a duplicated transaction accepted")
+	}
+}
@@ -0,0 +1,89 @@
+package block
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/sw90lee/blockchain_study/metrics"
+)
+
+const (
+	BLOCK_RESULT_MINED    = "mined"
+	BLOCK_RESULT_ACCEPTED = "accepted"
+	BLOCK_RESULT_REJECTED = "rejected"
+)
+
+// blockchain_server의 /metrics로 내보내는 node 내부 값
+var Metrics = metrics.NewRegistry()
+
+var (
+	blocksTotal = metrics.NewCounter(Metrics, "blockchain_blocks_total",
+		"Blocks mined locally, accepted from peers or rejected", "result")
+	reorgsTotal = metrics.NewCounter(Metrics, "blockchain_reorgs_total",
+		"Chain reorganizations that dropped at least one block")
+	reorgDepth = metrics.NewHistogram(Metrics, "blockchain_reorg_depth",
+		"Blocks dropped by a reorganization", []float64{1, 2, 5, 10, 20, 50, 100})
+	hashrate = metrics.NewGauge(Metrics, "blockchain_hashrate",
+		"Hashes per second during the last proof of work")
+	neighborDuration = metrics.NewHistogram(Metrics, "blockchain_neighbor_request_duration_seconds",
+		"Duration of HTTP requests to neighbors", nil, "endpoint")
+	neighborFailures = metrics.NewCounter(Metrics, "blockchain_neighbor_request_failures_total",
+		"HTTP requests to neighbors that failed or returned a non-2xx status", "endpoint")
+	validationDuration = metrics.NewHistogram(Metrics, "blockchain_validation_duration_seconds",
+		"Time spent validating chains and headers", nil, "kind")
+)
+
+// 이웃 노드 요청의 시간과 실패를 기록
+func neighborDo(client *http.Client, req *http.Request) (*http.Response, error) {
+	endpoint := req.Method + " " + req.URL.Path
+	start := time.Now()
+	resp, err := client.Do(req)
+	neighborDuration.Observe(time.Since(start).Seconds(), endpoint)
+	if err != nil || resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		neighborFailures.Inc(endpoint)
+	}
+	return resp, err
+}
+
+func neighborGet(client *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return neighborDo(client, req)
+}
+
+// 이웃의 chain으로 바꾸면서 added개를 받고 dropped개를 버렸다
+func observeChainSwitch(dropped int, added int) {
+	blocksTotal.Add(float64(added), BLOCK_RESULT_ACCEPTED)
+	if dropped > 0 {
+		reorgsTotal.Inc()
+		reorgDepth.Observe(float64(dropped))
+	}
+}
+
+func observeValidation(kind string, start time.Time) {
+	validationDuration.Observe(time.Since(start).Seconds(), kind)
+}
+
+// pool과 lock time을 기다리는 Transaction 수
+func (bc *Blockchain) MempoolSize() int {
+	return len(bc.transactionPool) + len(bc.lockedPool)
+}
+
+// pool에 있는 Transaction의 JSON 크기 합
+func (bc *Blockchain) MempoolBytes() int {
+	size := 0
+	for _, pool := range [][]*Transaction{bc.transactionPool, bc.lockedPool} {
+		for _, t := range pool {
+			m, _ := json.Marshal(t)
+			size += len(m)
+		}
+	}
+	return size
+}
+
+func (bc *Blockchain) PeerCount() int {
+	return len(bc.neighbors)
+}
@@ -0,0 +1,96 @@
+package block
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"log"
+
+	"github.com/sw90lee/blockchain_study/utils"
+)
+
+const MULTISIG_MAX_PUBLIC_KEYS = 15
+
+func (bc *Blockchain) CreateMultisigTransaction(t *Transaction,
+	required int, publicKeys []*ecdsa.PublicKey, signatures []*utils.Signature) bool {
+	isTransacted := bc.AddMultisigTransaction(t, required, publicKeys, signatures)
+
+	if isTransacted {
+		publicKeyStrs := make([]string, len(publicKeys))
+		for i, publicKey := range publicKeys {
+			publicKeyStrs[i] = fmt.Sprintf("%064x%064x", publicKey.X.Bytes(), publicKey.Y.Bytes())
+		}
+		signatureStrs := make([]string, len(signatures))
+		for i, s := range signatures {
+			if s != nil {
+				signatureStrs[i] = s.String()
+			}
+		}
+		bt := t.Request()
+		bt.MultisigRequired = &required
+		bt.MultisigPublicKeys = publicKeyStrs
+		bt.Signatures = signatureStrs
+		bc.broadcastTransaction(bt)
+	}
+
+	return isTransacted
+}
+
+func (bc *Blockchain) AddMultisigTransaction(t *Transaction,
+	required int, publicKeys []*ecdsa.PublicKey, signatures []*utils.Signature) bool {
+	if bc.VerifyMultisigSignature(required, publicKeys, signatures, t) {
+		return bc.addToPool(t)
+	} else {
+		log.Println("ERROR: Verify Multisig Transaction")
+	}
+	return false
+}
+
+// sender가 publicKeys로 만든 m-of-n 주소이고 서로 다른 key의 서명이 required개 이상인지 확인
+func (bc *Blockchain) VerifyMultisigSignature(required int, publicKeys []*ecdsa.PublicKey,
+	signatures []*utils.Signature, t *Transaction) bool {
+	if required <= 0 || required > len(publicKeys) ||
+		len(publicKeys) > MULTISIG_MAX_PUBLIC_KEYS ||
+		len(signatures) != len(publicKeys) {
+		return false
+	}
+	if utils.MultisigAddress(required, publicKeys) != t.senderBlockchainAddress {
+		return false
+	}
+
+	h := t.SigningHash()
+	signed := 0
+	seen := make(map[string]bool)
+	for i, publicKey := range publicKeys {
+		key := fmt.Sprintf("%064x%064x", publicKey.X.Bytes(), publicKey.Y.Bytes())
+		if seen[key] {
+			return false
+		}
+		seen[key] = true
+		s := signatures[i]
+		if s != nil && ecdsa.Verify(publicKey, h[:], s.R, s.S) {
+			signed += 1
+		}
+	}
+	return signed >= required
+}
+
+func (tr *TransactionRequest) MultisigKeys() ([]*ecdsa.PublicKey, []*utils.Signature, bool) {
+	publicKeys := make([]*ecdsa.PublicKey, len(tr.MultisigPublicKeys))
+	signatures := make([]*utils.Signature, len(tr.Signatures))
+	for i, k := range tr.MultisigPublicKeys {
+		if len(k) != 128 {
+			return nil, nil, false
+		}
+		publicKeys[i] = utils.PublicKeyFromString(k)
+	}
+	for i, s := range tr.Signatures {
+		if s == "" {
+			continue
+		}
+		if len(s) != 128 {
+			return nil, nil, false
+		}
+		signatures[i] = utils.SignatureFromString(s)
+	}
+	return publicKeys, signatures, true
+}
@@ -0,0 +1,137 @@
+package block
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"testing"
+
+	"github.com/sw90lee/blockchain_study/script"
+	"github.com/sw90lee/blockchain_study/utils"
+	"github.com/sw90lee/blockchain_study/wallet"
+)
+
+func multisigKeys(wallets ...*wallet.Wallet) []*ecdsa.PublicKey {
+	publicKeys := make([]*ecdsa.PublicKey, len(wallets))
+	for i, w := range wallets {
+		publicKeys[i] = w.PublicKey()
+	}
+	return publicKeys
+}
+
+// signers 자리만 서명을 채운다
+func multisigSignatures(t *Transaction, wallets []*wallet.Wallet, signers ...int) []*utils.Signature {
+	signatures := make([]*utils.Signature, len(wallets))
+	for _, i := range signers {
+		w := wallets[i]
+		signatures[i] = wallet.NewTransaction(w.PrivateKey(), w.PublicKey(),
+			t.senderBlockchainAddress, t.recipientBlockchainAddress, t.value).GenerateSignature()
+	}
+	return signatures
+}
+
+func TestMultisigThreshold(t *testing.T) {
+	bc := newTestChain()
+	wallets := []*wallet.Wallet{wallet.NewWallet(), wallet.NewWallet(), wallet.NewWallet()}
+	publicKeys := multisigKeys(wallets...)
+	tx := NewTransaction(utils.MultisigAddress(2, publicKeys), "bob", 1)
+
+	for _, c := range []struct {
+		name    string
+		signers []int
+		valid   bool
+	}{
+		{"no signature", nil, false},
+		{"one of two", []int{1}, false},
+		{"first and last", []int{0, 2}, true},
+		{"all", []int{0, 1, 2}, true},
+	} {
+		if got := bc.VerifyMultisigSignature(2, publicKeys, multisigSignatures(tx, wallets, c.signers...), tx); got != c.valid {
+			t.Errorf("%s: expected %v", c.name, c.valid)
+		}
+	}
+
+	// 다른 key의 서명이나 다른 주소, 맞지 않는 required는 받지 않는다
+	outsider := []*wallet.Wallet{wallets[0], wallet.NewWallet(), wallets[2]}
+	if bc.VerifyMultisigSignature(2, publicKeys, multisigSignatures(tx, outsider, 0, 1), tx) {
+		t.Error("signature from an outside key counted")
+	}
+	if bc.VerifyMultisigSignature(2, publicKeys, multisigSignatures(tx, wallets, 0, 1),
+		NewTransaction(utils.MultisigAddress(1, publicKeys), "bob", 1)) {
+		t.Error("signatures accepted for a different address")
+	}
+	if bc.VerifyMultisigSignature(4, publicKeys, multisigSignatures(tx, wallets, 0, 1, 2), tx) {
+		t.Error("required larger than the number of keys accepted")
+	}
+}
+
+// 같은 key를 두 번 넣어 한 사람의 서명을 두 번 세지 못한다
+func TestMultisigDuplicateKeys(t *testing.T) {
+	bc := newTestChain()
+	alice, bob := wallet.NewWallet(), wallet.NewWallet()
+	wallets := []*wallet.Wallet{alice, alice, bob}
+	publicKeys := multisigKeys(wallets...)
+	tx := NewTransaction(utils.MultisigAddress(2, publicKeys), "carol", 1)
+	if bc.VerifyMultisigSignature(2, publicKeys, multisigSignatures(tx, wallets, 0, 1), tx) {
+		t.Fatal("duplicate key counted twice")
+	}
+	if bc.AddMultisigTransaction(tx, 2, publicKeys, multisigSignatures(tx, wallets, 0, 1, 2)) {
+		t.Fatal("address with duplicate keys accepted")
+	}
+}
+
+// wallet이 서명을 하나씩 모으고, 다 모이면 TransactionRequest로 보내 Block에 들어간다
+func TestMultisigWalletFlow(t *testing.T) {
+	bc := newTestChain()
+	wallets := []*wallet.Wallet{wallet.NewWallet(), wallet.NewWallet(), wallet.NewWallet()}
+	mt := wallet.NewMultisigTransaction(2, multisigKeys(wallets...), "bob", 1)
+
+	submit := func() bool {
+		m, _ := json.Marshal(mt)
+		var tr TransactionRequest
+		if err := json.Unmarshal(m, &tr); err != nil || !tr.Validate() || !tr.IsMultisig() {
+			t.Fatalf("invalid request %s", m)
+		}
+		publicKeys, signatures, ok := tr.MultisigKeys()
+		return ok && bc.AddMultisigTransaction(tr.Transaction(), *tr.MultisigRequired, publicKeys, signatures)
+	}
+
+	if mt.Sign(wallet.NewWallet().PrivateKey()) {
+		t.Fatal("signed with a key outside the address")
+	}
+	if !mt.Sign(wallets[2].PrivateKey()) || mt.IsComplete() || mt.SignatureCount() != 1 {
+		t.Fatal("unexpected partial signature")
+	}
+	if submit() {
+		t.Fatal("partially signed transaction accepted")
+	}
+	if !mt.Sign(wallets[0].PrivateKey()) || !mt.IsComplete() {
+		t.Fatal("transaction not complete after two signatures")
+	}
+	if !submit() {
+		t.Fatal("complete transaction rejected")
+	}
+	if !bc.Mining() || bc.state.Balance("bob") != 1 || bc.state.Balance(mt.SenderBlockchainAddress()) != -1 {
+		t.Fatal("multisig transaction not mined")
+	}
+}
+
+// 다중서명 주소와 script 주소는 버전 바이트로 구분된다
+func TestMultisigAddressVersion(t *testing.T) {
+	publicKeys := multisigKeys(wallet.NewWallet(), wallet.NewWallet())
+	locking, _ := script.MultisigScript(2, publicKeys)
+	for _, c := range []struct {
+		address string
+		version byte
+	}{
+		{utils.MultisigAddress(2, publicKeys), utils.ADDRESS_VERSION_MULTISIG},
+		{utils.ScriptAddress(locking), utils.ADDRESS_VERSION_SCRIPT},
+		{utils.BlockchainAddress(publicKeys[0]), utils.ADDRESS_VERSION_PUBLIC_KEY},
+	} {
+		if version, ok := utils.AddressVersion(c.address); !ok || version != c.version {
+			t.Errorf("%s: version %#x, want %#x", c.address, version, c.version)
+		}
+	}
+	if _, ok := utils.AddressVersion("not an address"); ok {
+		t.Error("invalid address decoded")
+	}
+}
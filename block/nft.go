@@ -0,0 +1,81 @@
+package block
+
+import (
+	"log"
+
+	"github.com/sw90lee/blockchain_study/token"
+)
+
+// Block의 NFT Transaction을 registry에 적용, 한 Block에서 같은 NFT는 한 번만 움직일 수 있다
+func applyNFTBlock(registry *token.NFTRegistry, b *Block, height int) error {
+	moved := make(map[string]bool)
+	for _, t := range b.transactions {
+		if t.nft == nil {
+			continue
+		}
+		id := t.nft.NFTId(t.senderBlockchainAddress)
+		if moved[id] {
+			return token.ErrNFTDuplicate
+		}
+		moved[id] = true
+		if err := registry.Apply(t.senderBlockchainAddress, t.recipientBlockchainAddress, t.nft, height); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NFT 소유권 index, chain이 바뀌면 다시 만들어진다
+func (bc *Blockchain) NFTRegistry() *token.NFTRegistry {
+	return bc.state.nfts
+}
+
+// pool에 있는 Transaction과 같은 NFT를 움직이거나 소유자가 아니면 거절
+func (bc *Blockchain) ValidNFTTransaction(t *Transaction) bool {
+	if t.nft == nil {
+		return true
+	}
+	if t.value != 0 || t.contract != nil || t.token != nil || t.senderBlockchainAddress == MINING_SENDER {
+		log.Println("ERROR: nft transaction can not carry value, contract or token")
+		return false
+	}
+
+	registry := bc.state.nfts.Copy()
+	id := t.nft.NFTId(t.senderBlockchainAddress)
+	for _, pool := range [][]*Transaction{bc.transactionPool, bc.lockedPool} {
+		for _, p := range pool {
+			if p.nft != nil && p.nft.NFTId(p.senderBlockchainAddress) == id {
+				log.Printf("ERROR: %v", token.ErrNFTDuplicate)
+				return false
+			}
+		}
+	}
+	if err := registry.Apply(t.senderBlockchainAddress, t.recipientBlockchainAddress, t.nft, len(bc.chain)); err != nil {
+		log.Printf("ERROR: %v", err)
+		return false
+	}
+	return true
+}
+
+// 다음 Block에 넣을 수 없게 된 NFT Transaction을 pool에서 제거
+func (bc *Blockchain) dropInvalidNFTTransactions() {
+	registry := bc.state.nfts.Copy()
+	moved := make(map[string]bool)
+	pool := make([]*Transaction, 0, len(bc.transactionPool))
+	for _, t := range bc.transactionPool {
+		if t.nft != nil {
+			id := t.nft.NFTId(t.senderBlockchainAddress)
+			if moved[id] {
+				log.Printf("action=drop_nft_transaction, id=%s, error=%v", id, token.ErrNFTDuplicate)
+				continue
+			}
+			if err := registry.Apply(t.senderBlockchainAddress, t.recipientBlockchainAddress, t.nft, len(bc.chain)); err != nil {
+				log.Printf("action=drop_nft_transaction, id=%s, error=%v", id, err)
+				continue
+			}
+			moved[id] = true
+		}
+		pool = append(pool, t)
+	}
+	bc.transactionPool = pool
+}
@@ -0,0 +1,51 @@
+package block
+
+import (
+	"testing"
+
+	"github.com/sw90lee/blockchain_study/token"
+	"github.com/sw90lee/blockchain_study/wallet"
+)
+
+func addNFT(bc *Blockchain, signer *wallet.Wallet, sender string, recipient string, p *token.NFTPayload) bool {
+	wt := wallet.NewTransaction(signer.PrivateKey(), signer.PublicKey(), sender, recipient, 0)
+	wt.SetNFT(p)
+	bt := NewTransaction(sender, recipient, 0)
+	bt.SetNFT(p)
+	return bc.AddTransaction(bt, signer.PublicKey(), wt.GenerateSignature())
+}
+
+// 소유자가 아닌 key로는 sender를 소유자로 적어도 NFT를 옮길 수 없다
+func TestNFTTransferByNonOwner(t *testing.T) {
+	bc := newTestChain()
+	alice, mallory := wallet.NewWallet(), wallet.NewWallet()
+	mint := token.NewMintPayload([32]byte{1}, "art")
+	if !addNFT(bc, alice, alice.BlockchainAddress(), alice.BlockchainAddress(), mint) {
+		t.Fatal("mint rejected")
+	}
+	if !bc.Mining() {
+		t.Fatal("mining failed")
+	}
+	id := mint.NFTId(alice.BlockchainAddress())
+
+	transfer := token.NewNFTTransferPayload(id)
+	if addNFT(bc, mallory, mallory.BlockchainAddress(), mallory.BlockchainAddress(), transfer) {
+		t.Fatal("transfer from non-owner accepted")
+	}
+	if addNFT(bc, mallory, alice.BlockchainAddress(), mallory.BlockchainAddress(), transfer) {
+		t.Fatal("transfer signed by non-owner accepted")
+	}
+	if len(bc.TransactionPool()) != 0 || bc.NFTRegistry().NFT(id).Owner() != alice.BlockchainAddress() {
+		t.Fatal("forged transfer applied")
+	}
+
+	if !addNFT(bc, alice, alice.BlockchainAddress(), mallory.BlockchainAddress(), transfer) {
+		t.Fatal("transfer by owner rejected")
+	}
+	if !bc.Mining() {
+		t.Fatal("mining failed")
+	}
+	if bc.NFTRegistry().NFT(id).Owner() != mallory.BlockchainAddress() {
+		t.Fatal("transfer not applied")
+	}
+}
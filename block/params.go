@@ -0,0 +1,56 @@
+package block
+
+import "time"
+
+// node를 다시 빌드하지 않고 바꿀 수 있는 값, 기본값은 blockchain.go의 상수
+type Params struct {
+	MiningDifficulty int
+	MiningTimerSec   int
+
+	// 이웃을 찾을 때 훑는 port와 마지막 IP 자리 범위
+	PortRangeStart  uint16
+	PortRangeEnd    uint16
+	IpRangeStart    uint8
+	IpRangeEnd      uint8
+	NeighborSyncSec int
+
+	// Transaction memo의 최대 bytes, 모든 노드가 같은 값을 써야 한다
+	MaxMemoSize int
+
+	// 이웃의 chain으로 바꿀 때 되돌릴 수 있는 최대 Block 수
+	MaxReorgDepth int
+	// Block 높이 -> 반드시 있어야 하는 Block hash(hex)
+	Checkpoints map[int]string
+}
+
+func DefaultParams() Params {
+	return Params{
+		MiningDifficulty: MINING_DIFFICULTY,
+		MiningTimerSec:   MINING_TIMER_SEC,
+		PortRangeStart:   BLOCKCHAIN_PORT_RANGE_START,
+		PortRangeEnd:     BLOCKCHAIN_PORT_RANGE_END,
+		IpRangeStart:     NEIGHBOR_IP_RANGE_START,
+		IpRangeEnd:       NEIGHBOR_IP_RANGE_END,
+		NeighborSyncSec:  BLOCKCHIN_NEIGHBOR_SYNC_TIME_SEC,
+		MaxMemoSize:      MAX_MEMO_SIZE,
+		MaxReorgDepth:    MAX_REORG_DEPTH,
+		Checkpoints:      map[int]string{},
+	}
+}
+
+// Run 전에 호출해야 한다
+func (bc *Blockchain) SetParams(p Params) {
+	bc.params = p
+}
+
+func (bc *Blockchain) Params() Params {
+	return bc.params
+}
+
+func (p Params) miningTimer() time.Duration {
+	return time.Duration(p.MiningTimerSec) * time.Second
+}
+
+func (p Params) neighborSync() time.Duration {
+	return time.Duration(p.NeighborSyncSec) * time.Second
+}
@@ -0,0 +1,45 @@
+package block
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sw90lee/blockchain_study/wallet"
+)
+
+// pool과 chain 검증 모두 설정한 memo 크기를 쓴다
+func TestMaxMemoSizeFromParams(t *testing.T) {
+	bc := newTestChain()
+	p := bc.Params()
+	p.MaxMemoSize = 4
+	bc.SetParams(p)
+
+	w := wallet.NewWallet()
+	add := func(memo string) bool {
+		wt := wallet.NewTransaction(w.PrivateKey(), w.PublicKey(), w.BlockchainAddress(), "bob", 1)
+		wt.SetMemo(memo)
+		bt := NewTransaction(w.BlockchainAddress(), "bob", 1)
+		bt.SetMemo(memo)
+		return bc.AddTransaction(bt, w.PublicKey(), wt.GenerateSignature())
+	}
+	if add("12345") {
+		t.Fatal("memo above max_memo_size accepted")
+	}
+	if !add("1234") || !bc.Mining() {
+		t.Fatal("memo within max_memo_size rejected")
+	}
+	if !bc.ValidChain(bc.Chain()) {
+		t.Fatal("own chain rejected")
+	}
+
+	p.MaxMemoSize = 3
+	bc.SetParams(p)
+	if bc.ValidChain(bc.Chain()) {
+		t.Fatal("chain with long memo accepted")
+	}
+	p.MaxMemoSize = MAX_MEMO_SIZE
+	bc.SetParams(p)
+	if add(strings.Repeat("x", MAX_MEMO_SIZE+1)) {
+		t.Fatal("memo above default accepted")
+	}
+}
@@ -0,0 +1,92 @@
+package block
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// 0이면 모든 Block의 body를 보관한다
+func (bc *Blockchain) SetPruneDepth(depth int) {
+	bc.pruneDepth = depth
+	bc.prune()
+}
+
+func (bc *Blockchain) PruneDepth() int {
+	return bc.pruneDepth
+}
+
+// 이 높이보다 낮은 Block은 header만 남아 있다
+func (bc *Blockchain) PrunedHeight() int {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	return bc.prunedHeight
+}
+
+func (b *Block) IsPruned() bool {
+	return b.pruned
+}
+
+// body를 버리고 Hash를 계산할 수 있는 header만 남긴 Block
+func (b *Block) Header() *Block {
+	if b.pruned {
+		return b
+	}
+	return &Block{
+		timestamp:         b.timestamp,
+		nonce:             b.nonce,
+		previousHash:      b.previousHash,
+		proposer:          b.proposer,
+		proposerPublicKey: b.proposerPublicKey,
+		round:             b.round,
+		signature:         b.signature,
+		stateRoot:         b.stateRoot,
+		pruned:            true,
+		merkleRoot:        b.MerkleRoot(),
+		bodyHash:          b.BodyHash(),
+	}
+}
+
+// 마지막 pruneDepth개를 제외한 Block의 body를 버린다
+func (bc *Blockchain) prune() {
+	if bc.pruneDepth <= 0 || bc.prunedHeight >= len(bc.chain)-bc.pruneDepth {
+		return
+	}
+	for bc.prunedHeight < len(bc.chain)-bc.pruneDepth {
+		bc.chain[bc.prunedHeight] = bc.chain[bc.prunedHeight].Header()
+		bc.prunedHeight += 1
+	}
+	bc.addressIndex.prune(bc.prunedHeight)
+	log.Printf("action=prune, pruned_height=%d", bc.prunedHeight)
+}
+
+// 이웃 노드가 chain을 요청하기 전에 확인하는 노드 정보
+type StatusResponse struct {
+	Height       int    `json:"height"`
+	PrunedHeight int    `json:"pruned_height"`
+	Consensus    string `json:"consensus"`
+}
+
+func (bc *Blockchain) Status() *StatusResponse {
+	return &StatusResponse{
+		Height:       len(bc.chain),
+		PrunedHeight: bc.prunedHeight,
+		Consensus:    bc.consensus,
+	}
+}
+
+func (bc *Blockchain) fetchStatus(neighbor string) (*StatusResponse, error) {
+	resp, err := neighborGet(bc.client(), fmt.Sprintf("http://%s/status", neighbor))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	var status StatusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
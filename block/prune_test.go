@@ -0,0 +1,100 @@
+package block
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func prunedChain(t *testing.T, blocks int, depth int) (*Blockchain, []*Block) {
+	bc := newTestChain()
+	for i := 0; i < blocks; i++ {
+		fund(t, bc, "alice", 1)
+	}
+	full := bc.Chain()
+	bc.SetPruneDepth(depth)
+	return bc, full
+}
+
+func TestPrune(t *testing.T) {
+	bc, full := prunedChain(t, 5, 2)
+	if bc.PrunedHeight() != 4 {
+		t.Fatalf("pruned height %d", bc.PrunedHeight())
+	}
+	for i, b := range bc.Chain() {
+		pruned := i < 4
+		if b.IsPruned() != pruned || (len(b.Transaction()) == 0) != pruned {
+			t.Fatalf("block %d: pruned %v", i, b.IsPruned())
+		}
+	}
+	if bc.state.Balance("alice") != 5 {
+		t.Fatal("state changed by prune")
+	}
+
+	// 새 Block이 쌓이면 그만큼 더 버린다
+	fund(t, bc, "alice", 1)
+	if bc.PrunedHeight() != 5 || !bc.Chain()[4].IsPruned() || bc.Chain()[5].IsPruned() {
+		t.Fatalf("pruned height %d after mining", bc.PrunedHeight())
+	}
+	if !bc.ValidHeaders(bc.Headers()) || bc.Chain()[4].Hash() != full[4].Hash() {
+		t.Fatal("pruned chain no longer links")
+	}
+}
+
+// body를 버려도 header에 남긴 Merkle root와 body hash로 같은 Hash가 나온다
+func TestPrunedBlockHash(t *testing.T) {
+	bc, full := prunedChain(t, 3, 1)
+	for i, b := range bc.Chain()[:bc.PrunedHeight()] {
+		if b.Hash() != full[i].Hash() || b.MerkleRoot() != full[i].MerkleRoot() || b.BodyHash() != full[i].BodyHash() {
+			t.Fatalf("block %d: hash changed by prune", i)
+		}
+		m, _ := json.Marshal(b)
+		var decoded Block
+		if err := json.Unmarshal(m, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !decoded.IsPruned() || decoded.Hash() != full[i].Hash() {
+			t.Fatalf("block %d: hash changed by JSON", i)
+		}
+	}
+}
+
+func TestBlocksFromPrunedHeight(t *testing.T) {
+	bc, _ := prunedChain(t, 4, 2)
+	height := bc.PrunedHeight()
+	if _, ok := bc.BlocksFrom(height - 1); ok {
+		t.Fatal("blocks below the pruned height served")
+	}
+	blocks, ok := bc.BlocksFrom(height)
+	if !ok || len(blocks) != 2 || blocks[0].IsPruned() {
+		t.Fatalf("unexpected blocks %d", len(blocks))
+	}
+
+	neighbor := serveNeighbor(t, bc, nil)
+	for from, code := range map[int]int{height - 1: http.StatusGone, height: http.StatusOK} {
+		resp, err := http.Get(fmt.Sprintf("http://%s/blocks?from=%d", neighbor, from))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != code {
+			t.Fatalf("from %d: status %d", from, resp.StatusCode)
+		}
+	}
+}
+
+// 이웃은 /status로 어디까지 body를 버렸는지 알 수 있다
+func TestStatusAdvertisesPrunedHeight(t *testing.T) {
+	bc, _ := prunedChain(t, 4, 2)
+	if s := bc.Status(); s.Height != 5 || s.PrunedHeight != 3 || s.Consensus != CONSENSUS_PROOF_OF_WORK {
+		t.Fatalf("unexpected status %+v", s)
+	}
+	s, err := newTestChain().fetchStatus(serveNeighbor(t, bc, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Height != 5 || s.PrunedHeight != 3 {
+		t.Fatalf("unexpected status %+v", s)
+	}
+}
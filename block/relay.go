@@ -0,0 +1,83 @@
+package block
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/sw90lee/blockchain_study/utils"
+)
+
+// HTTP 이웃 말고 다른 통로로 새 Transaction과 Block을 알린다, p2p.Server가 구현한다
+// Blockchain의 lock을 잡은 채로 부르므로 기다리지 않고 돌아와야 한다
+type Relay interface {
+	RelayTransaction(tr *TransactionRequest)
+	RelayBlock(b *Block, height int)
+}
+
+func (bc *Blockchain) SetRelay(r Relay) {
+	bc.relay = r
+}
+
+// 서명 방식(단일, 다중서명, script)에 맞춰 검증하고 pool에 넣는다, p2p reader goroutine에서도 부르므로 lock을 잡는다
+func (bc *Blockchain) AddTransactionRequest(tr *TransactionRequest) bool {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	if !tr.Validate() {
+		return false
+	}
+	if tr.IsScript() {
+		lockingScript, unlockingScript, ok := tr.Scripts()
+		return ok && bc.AddScriptTransaction(tr.Transaction(), lockingScript, unlockingScript)
+	}
+	if tr.IsMultisig() {
+		publicKeys, signatures, ok := tr.MultisigKeys()
+		return ok && bc.AddMultisigTransaction(tr.Transaction(), *tr.MultisigRequired, publicKeys, signatures)
+	}
+	publicKey := utils.PublicKeyFromString(*tr.SenderPublicKey)
+	signature := utils.SignatureFromString(*tr.Signature)
+	return bc.AddTransaction(tr.Transaction(), publicKey, signature)
+}
+
+// 이웃에게 받은 chain이 지금보다 길고 유효하면 바꾸고, 새 Block에 들어간 Transaction은 pool에서 뺀다
+func (bc *Blockchain) OfferChain(chain []*Block, peer string) bool {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	if len(chain) <= len(bc.chain) {
+		return false
+	}
+	if err := bc.checkReorg(chain); err != nil {
+		log.Printf("ERROR: suspicious chain from %s: %v", peer, err)
+		blocksTotal.Add(float64(len(chain)-len(bc.chain)), BLOCK_RESULT_REJECTED)
+		return false
+	}
+	if !bc.ValidChain(chain) {
+		log.Printf("ERROR: invalid chain from %s", peer)
+		blocksTotal.Add(float64(len(chain)-len(bc.chain)), BLOCK_RESULT_REJECTED)
+		return false
+	}
+	bc.replaceChain(chain)
+	log.Printf("action=accept_chain, peer=%s, height=%d", peer, len(chain)-1)
+	return true
+}
+
+// 새로 받은 Block에 들어간 Transaction을 두 pool에서 뺀다
+func (bc *Blockchain) dropIncluded(blocks []*Block) {
+	included := make(map[string]bool)
+	for _, b := range blocks {
+		for _, t := range b.transactions {
+			included[fmt.Sprintf("%x", t.Hash())] = true
+		}
+	}
+	bc.transactionPool = excludeTransactions(bc.transactionPool, included)
+	bc.lockedPool = excludeTransactions(bc.lockedPool, included)
+}
+
+func excludeTransactions(pool []*Transaction, included map[string]bool) []*Transaction {
+	remaining := make([]*Transaction, 0, len(pool))
+	for _, t := range pool {
+		if !included[fmt.Sprintf("%x", t.Hash())] {
+			remaining = append(remaining, t)
+		}
+	}
+	return remaining
+}
@@ -0,0 +1,43 @@
+package block
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sw90lee/blockchain_study/clock"
+	"github.com/sw90lee/blockchain_study/wallet"
+)
+
+// 아직 잠긴 Transaction도 이웃이 Block에 넣었으면 lockedPool에서 뺀다
+func TestOfferChainDropsLockedTransaction(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ca, cb := clock.NewFake(start), clock.NewFake(start)
+	a, b := NewBlockchainWithClock("a", 5000, ca), NewBlockchainWithClock("b", 5000, cb)
+	for _, bc := range []*Blockchain{a, b} {
+		p := DefaultParams()
+		p.MiningDifficulty = 1
+		bc.SetParams(p)
+	}
+
+	w := wallet.NewWallet()
+	lockTime := start.Add(time.Minute).Unix()
+	wt := wallet.NewTransaction(w.PrivateKey(), w.PublicKey(), w.BlockchainAddress(), "bob", 1)
+	wt.SetLockTime(lockTime)
+	bt := NewTransaction(w.BlockchainAddress(), "bob", 1)
+	bt.SetLockTime(lockTime)
+	s := wt.GenerateSignature()
+
+	if !a.AddTransaction(bt, w.PublicKey(), s) || len(a.LockedTransactionPool()) != 1 {
+		t.Fatal("transaction not locked")
+	}
+	cb.Advance(2 * time.Minute)
+	if !b.AddTransaction(bt, w.PublicKey(), s) || !b.Mining() {
+		t.Fatal("mining failed")
+	}
+	if !a.OfferChain(b.Chain(), "b") {
+		t.Fatal("longer chain rejected")
+	}
+	if len(a.LockedTransactionPool()) != 0 || len(a.TransactionPool()) != 0 {
+		t.Fatal("included transaction left in pool")
+	}
+}
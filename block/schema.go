@@ -0,0 +1,95 @@
+package block
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Block과 chain JSON에 들어가는 형식 번호
+// Transaction JSON은 서명과 Merkle root의 대상이라 번호를 넣지 않고, 담고 있는 Block의 번호를 따른다
+// 형식을 바꾸면 번호를 올리고 testdata의 golden 파일을 다시 만든다
+const SCHEMA_VERSION = 1
+
+var (
+	ErrUnknownField       = errors.New("unknown field")
+	ErrMissingField       = errors.New("missing field")
+	ErrUnsupportedVersion = errors.New("unsupported schema version")
+	ErrInvalidHash        = errors.New("invalid hash")
+)
+
+var (
+	transactionFields         = []string{"sender_blockchain_address", "recipient_blockchain_address", "value"}
+	transactionOptionalFields = []string{"lock_time", "memo", "contract", "token", "nft",
+		"locking_script", "unlocking_script"}
+
+	blockFields         = []string{"version", "timestamp", "nonce", "previous_hash", "transactions"}
+	blockOptionalFields = []string{"proposer", "proposer_public_key", "round", "signature", "evidence",
+		"state_root", "receipts", "pruned", "merkle_root", "body_hash"}
+
+	chainFields = []string{"version", "chain"}
+)
+
+// data가 JSON object이고 required를 모두 가지며 required, optional 밖의 key가 없는지 확인
+func checkFields(kind string, data []byte, required []string, optional []string) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("%s: %v", kind, err)
+	}
+	allowed := make(map[string]bool)
+	for _, name := range required {
+		if _, ok := fields[name]; !ok {
+			return fmt.Errorf("%w: %s.%s", ErrMissingField, kind, name)
+		}
+		allowed[name] = true
+	}
+	for _, name := range optional {
+		allowed[name] = true
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !allowed[name] {
+			return fmt.Errorf("%w: %s.%s", ErrUnknownField, kind, name)
+		}
+	}
+	return nil
+}
+
+func checkVersion(kind string, version int) error {
+	if version != SCHEMA_VERSION {
+		return fmt.Errorf("%w: %s version %d", ErrUnsupportedVersion, kind, version)
+	}
+	return nil
+}
+
+// 64자리 hex, optional이면 빈 문자열은 0
+func decodeHash(field string, s string, optional bool) ([32]byte, error) {
+	var h [32]byte
+	if s == "" && optional {
+		return h, nil
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(h) {
+		return h, fmt.Errorf("%w: %s", ErrInvalidHash, field)
+	}
+	copy(h[:], b)
+	return h, nil
+}
+
+// 빈 문자열은 nil
+func decodeHex(field string, s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", field, err)
+	}
+	return b, nil
+}
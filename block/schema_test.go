@@ -0,0 +1,152 @@
+package block
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sw90lee/blockchain_study/clock"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// 가짜 시계로 만든 두 Block짜리 chain, 실행할 때마다 같은 hash가 나온다
+func goldenChain(t *testing.T) *Blockchain {
+	c := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	bc := NewBlockchainWithClock("miner", 5000, c)
+	bc.SetParams(testParams(1))
+	c.Advance(time.Second)
+	fund(t, bc, "alice", 10)
+
+	memo := NewTransaction(MINING_SENDER, "bob", 2.5)
+	memo.SetMemo("invoice 42")
+	memo.SetLockTime(2)
+	bc.AddTransaction(memo, nil, nil)
+	c.Advance(time.Second)
+	if !bc.Mining() {
+		t.Fatal("mining failed")
+	}
+	return bc
+}
+
+func goldenTransaction() *Transaction {
+	tx := NewTransaction("alice", "bob", 1.25)
+	tx.SetLockTime(LOCKTIME_THRESHOLD + 60)
+	tx.SetMemo("rent")
+	tx.lockingScript = []byte{0x76, 0xa9, 0x14}
+	tx.unlockingScript = []byte{0x51}
+	return tx
+}
+
+// golden 파일과 같은지 확인하고, -update면 파일을 새로 쓴다
+func checkGolden(t *testing.T, name string, v interface{}) []byte {
+	m, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := ioutil.WriteFile(path, append(m, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	golden, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bytes.TrimSpace(golden), m) {
+		t.Fatalf("%s changed, bump SCHEMA_VERSION if this is intended:\n%s", name, m)
+	}
+	return golden
+}
+
+func TestGoldenChain(t *testing.T) {
+	bc := goldenChain(t)
+	golden := checkGolden(t, "chain_v1.json", bc)
+
+	var decoded Blockchain
+	if err := json.Unmarshal(golden, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.chain) != len(bc.chain) {
+		t.Fatalf("decoded %d blocks", len(decoded.chain))
+	}
+	for i, b := range decoded.chain {
+		if b.Hash() != bc.chain[i].Hash() {
+			t.Fatalf("block %d hash changed", i)
+		}
+	}
+	if !bc.ValidChain(decoded.chain) {
+		t.Fatal("decoded chain is invalid")
+	}
+	m, _ := json.MarshalIndent(&decoded, "", "  ")
+	if !bytes.Equal(bytes.TrimSpace(golden), m) {
+		t.Fatal("chain does not round-trip")
+	}
+}
+
+func TestGoldenHeader(t *testing.T) {
+	header := goldenChain(t).chain[2].Header()
+	golden := checkGolden(t, "header_v1.json", header)
+
+	var decoded Block
+	if err := json.Unmarshal(golden, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.pruned || decoded.Hash() != header.Hash() {
+		t.Fatal("header does not round-trip")
+	}
+}
+
+func TestGoldenTransaction(t *testing.T) {
+	tx := goldenTransaction()
+	golden := checkGolden(t, "transaction_v1.json", tx)
+
+	var decoded Transaction
+	if err := json.Unmarshal(golden, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Hash() != tx.Hash() || decoded.SigningHash() != tx.SigningHash() {
+		t.Fatal("transaction does not round-trip")
+	}
+}
+
+// prefix 뒤에 오는 값의 앞 두 글자를 지운다
+func trimValue(data string, prefix string) string {
+	i := strings.Index(data, prefix) + len(prefix)
+	return data[:i] + data[i+2:]
+}
+
+func TestStrictDecoding(t *testing.T) {
+	chain, _ := ioutil.ReadFile(filepath.Join("testdata", "chain_v1.json"))
+	header, _ := ioutil.ReadFile(filepath.Join("testdata", "header_v1.json"))
+	transaction, _ := ioutil.ReadFile(filepath.Join("testdata", "transaction_v1.json"))
+
+	for _, c := range []struct {
+		name   string
+		data   string
+		v      interface{}
+		target error
+	}{
+		{"old chain key", strings.Replace(string(chain), `"chain"`, `"chains"`, 1), &Blockchain{}, ErrMissingField},
+		{"chain version", strings.Replace(string(chain), `"version": 1`, `"version": 2`, 1), &Blockchain{}, ErrUnsupportedVersion},
+		{"block version", strings.Replace(string(header), `"version": 1`, `"version": 0`, 1), &Block{}, ErrUnsupportedVersion},
+		{"unknown block field", strings.Replace(string(header), `"nonce"`, `"extra": 1, "nonce"`, 1), &Block{}, ErrUnknownField},
+		{"missing previous hash", strings.Replace(string(header), `"previous_hash"`, `"signature"`, 1), &Block{}, ErrMissingField},
+		{"short hash", trimValue(string(header), `"merkle_root": "`), &Block{}, ErrInvalidHash},
+		{"long hash", strings.Replace(string(header), `"merkle_root": "`, `"merkle_root": "00`, 1), &Block{}, ErrInvalidHash},
+		{"unknown transaction field", strings.Replace(string(transaction), `"memo"`, `"note"`, 1), &Transaction{}, ErrUnknownField},
+		{"missing value", strings.Replace(string(transaction), `"value"`, `"lock_time"`, 1), &Transaction{}, ErrMissingField},
+	} {
+		err := json.Unmarshal([]byte(c.data), c.v)
+		if !errors.Is(err, c.target) {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.target)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package block
+
+import (
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/sw90lee/blockchain_study/script"
+	"github.com/sw90lee/blockchain_study/utils"
+)
+
+func (bc *Blockchain) CreateScriptTransaction(t *Transaction,
+	lockingScript []byte, unlockingScript []byte) bool {
+	isTransacted := bc.AddScriptTransaction(t, lockingScript, unlockingScript)
+
+	if isTransacted {
+		lockingScriptStr := hex.EncodeToString(lockingScript)
+		unlockingScriptStr := hex.EncodeToString(unlockingScript)
+		bt := t.Request()
+		bt.LockingScript = &lockingScriptStr
+		bt.UnlockingScript = &unlockingScriptStr
+		bc.broadcastTransaction(bt)
+	}
+
+	return isTransacted
+}
+
+func (bc *Blockchain) AddScriptTransaction(t *Transaction,
+	lockingScript []byte, unlockingScript []byte) bool {
+	// 같은 script 주소를 여러 조건으로 중복해서 꺼내지 못하도록 잔액 확인
+	if bc.CalculateTotalAmount(t.senderBlockchainAddress)-bc.PendingAmount(t.senderBlockchainAddress) < t.value {
+		log.Println("ERROR: Not enough balance in a script address")
+		return false
+	}
+
+	if bc.VerifyTransactionScript(lockingScript, unlockingScript, t) {
+		t.lockingScript = lockingScript
+		t.unlockingScript = unlockingScript
+		return bc.addToPool(t)
+	}
+	return false
+}
+
+// sender가 locking script의 주소이고 unlocking script로 조건을 만족하는지 확인
+func (bc *Blockchain) VerifyTransactionScript(lockingScript []byte, unlockingScript []byte,
+	t *Transaction) bool {
+	return verifyScript(lockingScript, unlockingScript, t, int64(len(bc.chain)), bc.clock.Now().Unix())
+}
+
+// height 높이, now 시각(unix second)의 Block에 들어간다고 보고 script를 실행
+func verifyScript(lockingScript []byte, unlockingScript []byte, t *Transaction, height int64, now int64) bool {
+	if utils.ScriptAddress(lockingScript) != t.senderBlockchainAddress {
+		log.Println("ERROR: locking script does not match sender")
+		return false
+	}
+
+	ctx := &script.Context{
+		SigHash: t.SigningHash(),
+		Height:  height,
+		Time:    now,
+	}
+	if err := script.Execute(unlockingScript, lockingScript, ctx); err != nil {
+		log.Printf("ERROR: %v", err)
+		return false
+	}
+	return true
+}
+
+// 다른 노드가 만든 Block의 script Transaction도 Block의 높이와 시각으로 다시 실행한다
+func (b *Block) ValidScripts(height int64) bool {
+	now := b.timestamp / int64(time.Second)
+	for _, t := range b.transactions {
+		if t.lockingScript == nil && t.unlockingScript == nil {
+			continue
+		}
+		if !verifyScript(t.lockingScript, t.unlockingScript, t, height, now) {
+			log.Printf("ERROR: invalid script transaction at block %d", height)
+			return false
+		}
+	}
+	return true
+}
+
+// 아직 Block에 들어가지 않은 Transaction으로 address에서 나갈 금액
+func (bc *Blockchain) PendingAmount(blockchainAddress string) float32 {
+	var pending float32 = 0.0
+	for _, pool := range [][]*Transaction{bc.transactionPool, bc.lockedPool} {
+		for _, t := range pool {
+			if t.senderBlockchainAddress == blockchainAddress {
+				pending += t.value
+				if t.contract != nil {
+					pending += float32(t.contract.GasLimit()) * t.contract.GasPrice()
+				}
+			}
+		}
+	}
+	return pending
+}
+
+func (tr *TransactionRequest) Scripts() ([]byte, []byte, bool) {
+	lockingScript, err := hex.DecodeString(*tr.LockingScript)
+	if err != nil {
+		return nil, nil, false
+	}
+	unlockingScript, err := hex.DecodeString(*tr.UnlockingScript)
+	if err != nil {
+		return nil, nil, false
+	}
+	return lockingScript, unlockingScript, true
+}
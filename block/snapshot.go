@@ -0,0 +1,233 @@
+package block
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// SNAPSHOT_INTERVAL 높이 Block까지 적용한 상태
+// header의 state root로 확인하므로 새 노드는 전체 chain을 재생하지 않아도 된다
+type Snapshot struct {
+	height    int
+	blockHash [32]byte
+	state     *State
+}
+
+func NewSnapshot(height int, blockHash [32]byte, state *State) *Snapshot {
+	return &Snapshot{height: height, blockHash: blockHash, state: state}
+}
+
+func (s *Snapshot) Height() int {
+	return s.height
+}
+
+func (s *Snapshot) BlockHash() [32]byte {
+	return s.blockHash
+}
+
+func (s *Snapshot) State() *State {
+	return s.state
+}
+
+func (s *Snapshot) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Height    int    `json:"height"`
+		BlockHash string `json:"block_hash"`
+		StateRoot string `json:"state_root"`
+		State     *State `json:"state"`
+	}{
+		Height:    s.height,
+		BlockHash: fmt.Sprintf("%x", s.blockHash),
+		StateRoot: fmt.Sprintf("%x", s.state.Root()),
+		State:     s.state,
+	})
+}
+
+func (s *Snapshot) UnmarshalJSON(data []byte) error {
+	var blockHash string
+	s.state = NewState()
+	v := &struct {
+		Height    *int    `json:"height"`
+		BlockHash *string `json:"block_hash"`
+		State     *State  `json:"state"`
+	}{
+		Height:    &s.height,
+		BlockHash: &blockHash,
+		State:     s.state,
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	bh, _ := hex.DecodeString(blockHash)
+	copy(s.blockHash[:], bh)
+	return nil
+}
+
+func (bc *Blockchain) SetFastSync(fastSync bool) {
+	bc.fastSync = fastSync
+}
+
+// 가장 최근 snapshot, 아직 SNAPSHOT_INTERVAL에 닿지 않았으면 nil
+func (bc *Blockchain) Snapshot() *Snapshot {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	return bc.snapshot
+}
+
+// 모든 Block의 header
+func (bc *Blockchain) Headers() []*Block {
+	return bc.HeadersFrom(0)
+}
+
+// from 높이부터의 header
+func (bc *Blockchain) HeadersFrom(from int) []*Block {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	if from < 0 {
+		from = 0
+	}
+	headers := []*Block{}
+	for i := from; i < len(bc.chain); i++ {
+		headers = append(headers, bc.chain[i].Header())
+	}
+	return headers
+}
+
+// from 높이부터의 Block, body를 버린 높이면 false
+func (bc *Blockchain) BlocksFrom(from int) ([]*Block, bool) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	if from < bc.prunedHeight || from < 0 {
+		return nil, false
+	}
+	if from > len(bc.chain) {
+		from = len(bc.chain)
+	}
+	return append([]*Block{}, bc.chain[from:]...), true
+}
+
+func (bc *Blockchain) ValidHeaders(headers []*Block) bool {
+	defer observeValidation("headers", time.Now())
+	return ValidHeaderChain(bc.consensus, bc.params.MiningDifficulty, headers)
+}
+
+// body 없이 header만으로 연결, 작업 증명, proposer 서명을 확인
+// 첫 header는 이미 확인된 것으로 본다
+func ValidHeaderChain(consensus string, difficulty int, headers []*Block) bool {
+	for i := 1; i < len(headers); i++ {
+		preBlock, b := headers[i-1], headers[i]
+		if b.previousHash != preBlock.Hash() {
+			return false
+		}
+		if consensus == CONSENSUS_PROOF_OF_STAKE {
+			if b.round != slotRound(preBlock, b.timestamp) || !b.VerifySignature() {
+				log.Printf("ERROR: invalid header at block %d", i)
+				return false
+			}
+		} else if !validProofHash(b.nonce, b.previousHash, b.MerkleRoot(), difficulty) {
+			return false
+		}
+	}
+	return true
+}
+
+// 이웃 노드의 snapshot을 받아 그 높이부터 검증을 시작
+func (bc *Blockchain) FastSync() bool {
+	for _, n := range bc.neighbors {
+		if bc.fastSyncFrom(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshot, header, Block은 lock 없이 받아 오고, 확인하고 바꾸는 동안만 bc.mux를 잡는다
+func (bc *Blockchain) fastSyncFrom(neighbor string) bool {
+	var snapshot Snapshot
+	if err := bc.fetchJSON(fmt.Sprintf("http://%s/snapshot", neighbor), &snapshot); err != nil {
+		log.Printf("action=skip_fast_sync, peer=%s, error=%v", neighbor, err)
+		return false
+	}
+	var headers []*Block
+	if err := bc.fetchJSON(fmt.Sprintf("http://%s/headers", neighbor), &headers); err != nil {
+		log.Printf("ERROR: %v", err)
+		return false
+	}
+	h := snapshot.height
+	if h <= 0 || h >= len(headers) {
+		return false
+	}
+	var blocks []*Block
+	if err := bc.fetchJSON(fmt.Sprintf("http://%s/blocks?from=%d", neighbor, h+1), &blocks); err != nil {
+		log.Printf("ERROR: %v", err)
+		return false
+	}
+
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	if len(headers) <= len(bc.chain) {
+		return false
+	}
+	if err := bc.checkReorg(headers); err != nil {
+		log.Printf("ERROR: suspicious chain from %s: %v", neighbor, err)
+		return false
+	}
+	if !bc.ValidHeaders(headers) {
+		log.Printf("ERROR: invalid headers from %s", neighbor)
+		return false
+	}
+	if headers[h].Hash() != snapshot.blockHash || headers[h].stateRoot != snapshot.state.Root() {
+		log.Printf("ERROR: snapshot from %s does not match header %d", neighbor, h)
+		return false
+	}
+
+	// headers를 받은 뒤에 추가된 Block은 버린다
+	if len(blocks) < len(headers)-h-1 {
+		return false
+	}
+	blocks = blocks[:len(headers)-h-1]
+
+	state := snapshot.state.Copy()
+	for i, b := range blocks {
+		height := h + 1 + i
+		if b.Hash() != headers[height].Hash() {
+			log.Printf("ERROR: block %d does not match header", height)
+			return false
+		}
+		if !b.ValidLockTimes(int64(height)) || !b.ValidMemos(bc.params.MaxMemoSize) || !b.ValidScripts(int64(height)) {
+			return false
+		}
+		if err := state.applyBlock(b, height); err != nil {
+			log.Printf("ERROR: %v", err)
+			return false
+		}
+	}
+
+	chain := append(headers[:h+1], blocks...)
+	fork, dropped := bc.reindexAddresses(chain), len(bc.chain)
+	bc.chain = chain
+	bc.state = state
+	bc.snapshot = NewSnapshot(h, snapshot.blockHash, snapshot.state)
+	bc.prunedHeight = h + 1
+	bc.dropIncluded(chain[fork:])
+	bc.prune()
+	observeChainSwitch(dropped-fork, len(chain)-fork)
+	bc.publishChain(fork, dropped-fork, EVENT_SOURCE_PEER)
+	log.Printf("action=fast_sync, peer=%s, snapshot_height=%d, height=%d", neighbor, h, len(bc.chain))
+	return true
+}
+
+func (bc *Blockchain) fetchJSON(url string, v interface{}) error {
+	resp, err := neighborGet(bc.client(), url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,119 @@
+package block
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sw90lee/blockchain_study/clock"
+)
+
+// 이웃 노드의 /status, /chain, /snapshot, /headers, /blocks
+// snapshot이 nil이 아니면 source의 snapshot 대신 보낸다
+func serveNeighbor(t *testing.T, source *Blockchain, snapshot func() *Snapshot) string {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var v interface{}
+		switch r.URL.Path {
+		case "/status":
+			v = source.Status()
+		case "/chain":
+			v = source
+		case "/snapshot":
+			if snapshot != nil {
+				v = snapshot()
+			} else {
+				v = source.Snapshot()
+			}
+		case "/headers":
+			v = source.Headers()
+		case "/blocks":
+			from, _ := strconv.Atoi(r.URL.Query().Get("from"))
+			blocks, ok := source.BlocksFrom(from)
+			if !ok {
+				w.WriteHeader(http.StatusGone)
+				return
+			}
+			v = blocks
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		m, _ := json.Marshal(v)
+		io.WriteString(w, string(m[:]))
+	}))
+	t.Cleanup(s.Close)
+	return strings.TrimPrefix(s.URL, "http://")
+}
+
+// SNAPSHOT_INTERVAL을 넘도록 Block을 만든 chain
+func snapshotChain(t *testing.T) *Blockchain {
+	c := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	bc := NewBlockchainWithClock("miner", 5000, c)
+	bc.SetParams(testParams(1))
+	for i := 0; i < SNAPSHOT_INTERVAL+2; i++ {
+		c.Advance(time.Second)
+		fund(t, bc, "alice", 1)
+	}
+	if bc.Snapshot() == nil || bc.Snapshot().Height() != SNAPSHOT_INTERVAL {
+		t.Fatal("no snapshot")
+	}
+	return bc
+}
+
+func newFollower(neighbor string) *Blockchain {
+	bc := NewBlockchainWithClock("follower", 5001, clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+	bc.SetParams(testParams(1))
+	bc.UseNeighbors([]string{neighbor})
+	bc.SetFastSync(true)
+	return bc
+}
+
+func TestFastSync(t *testing.T) {
+	source := snapshotChain(t)
+	bc := newFollower(serveNeighbor(t, source, nil))
+	if !bc.FastSync() {
+		t.Fatal("fast sync failed")
+	}
+	if tip(bc) != tip(source) || bc.state.Root() != source.state.Root() {
+		t.Fatal("state differs from the neighbor")
+	}
+	if bc.PrunedHeight() != SNAPSHOT_INTERVAL+1 || !bc.chain[1].pruned || bc.chain[SNAPSHOT_INTERVAL+1].pruned {
+		t.Fatalf("unexpected pruned height %d", bc.PrunedHeight())
+	}
+	if bc.state.Balance("alice") != SNAPSHOT_INTERVAL+2 {
+		t.Fatalf("balance %f", bc.state.Balance("alice"))
+	}
+}
+
+// header의 state root와 맞지 않는 snapshot은 버리고 전체 chain을 받는다
+func TestFastSyncFallsBackOnTamperedSnapshot(t *testing.T) {
+	source := snapshotChain(t)
+	neighbor := serveNeighbor(t, source, func() *Snapshot {
+		s := source.Snapshot()
+		state := s.State().Copy()
+		state.balances["mallory"] = 1000
+		return NewSnapshot(s.Height(), s.BlockHash(), state)
+	})
+	bc := newFollower(neighbor)
+	if bc.FastSync() {
+		t.Fatal("tampered snapshot accepted")
+	}
+	if len(bc.Chain()) != 1 {
+		t.Fatal("chain changed by a rejected snapshot")
+	}
+
+	bc.Run()
+	if tip(bc) != tip(source) || bc.PrunedHeight() != 0 || bc.state.Balance("mallory") != 0 {
+		t.Fatal("did not fall back to full sync")
+	}
+}
+
+func tip(bc *Blockchain) [32]byte {
+	chain := bc.Chain()
+	return chain[len(chain)-1].Hash()
+}
@@ -0,0 +1,327 @@
+package block
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/sw90lee/blockchain_study/utils"
+)
+
+// 같은 slot(previous hash, round)에 서로 다른 두 Block을 서명한 증거
+type Evidence struct {
+	first  *Block
+	second *Block
+}
+
+func NewEvidence(first *Block, second *Block) *Evidence {
+	return &Evidence{first, second}
+}
+
+func (e *Evidence) Offender() string {
+	return e.first.proposer
+}
+
+func (e *Evidence) key() string {
+	if e.first == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s:%x:%d", e.first.proposer, e.first.previousHash, e.first.round)
+}
+
+func (e *Evidence) Valid() bool {
+	a, b := e.first, e.second
+	if a == nil || b == nil {
+		return false
+	}
+	return a.proposer != "" &&
+		a.proposer == b.proposer &&
+		a.previousHash == b.previousHash &&
+		a.round == b.round &&
+		a.Hash() != b.Hash() &&
+		a.VerifySignature() && b.VerifySignature()
+}
+
+func (e *Evidence) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		First  *Block `json:"first"`
+		Second *Block `json:"second"`
+	}{
+		First:  e.first,
+		Second: e.second,
+	})
+}
+
+func (e *Evidence) UnmarshalJSON(data []byte) error {
+	v := &struct {
+		First  **Block `json:"first"`
+		Second **Block `json:"second"`
+	}{
+		First:  &e.first,
+		Second: &e.second,
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return nil
+}
+
+// 서명을 제외한 Block의 Hash
+func (b *Block) signingHash() [32]byte {
+	c := *b
+	c.signature = ""
+	return c.Hash()
+}
+
+func (b *Block) Sign(privateKey *ecdsa.PrivateKey) {
+	publicKey := &privateKey.PublicKey
+	b.proposer = utils.BlockchainAddress(publicKey)
+	b.proposerPublicKey = fmt.Sprintf("%064x%064x", publicKey.X.Bytes(), publicKey.Y.Bytes())
+	h := b.signingHash()
+	r, s, _ := ecdsa.Sign(rand.Reader, privateKey, h[:])
+	b.signature = (&utils.Signature{R: r, S: s}).String()
+}
+
+func (b *Block) VerifySignature() bool {
+	if len(b.proposerPublicKey) != 128 || len(b.signature) != 128 {
+		return false
+	}
+	publicKey := utils.PublicKeyFromString(b.proposerPublicKey)
+	if utils.BlockchainAddress(publicKey) != b.proposer {
+		return false
+	}
+	s := utils.SignatureFromString(b.signature)
+	h := b.signingHash()
+	return ecdsa.Verify(publicKey, h[:], s.R, s.S)
+}
+
+func (bc *Blockchain) SetConsensus(consensus string) {
+	bc.consensus = consensus
+}
+
+func (bc *Blockchain) Consensus() string {
+	return bc.consensus
+}
+
+// Proof of Stake 모드에서 Block 서명에 사용할 key
+func (bc *Blockchain) SetValidatorKey(privateKey *ecdsa.PrivateKey) {
+	bc.validatorKey = privateKey
+}
+
+// 각 주소가 lock 해둔 stake
+func (bc *Blockchain) Stakes() map[string]float32 {
+	return bc.state.Stakes()
+}
+
+func (bc *Blockchain) StakeOf(blockchainAddress string) float32 {
+	return bc.state.stakes[blockchainAddress]
+}
+
+// 이전 Block 이후 round 번째 slot의 proposer
+func (bc *Blockchain) NextProposer(round int) string {
+	stakes := bc.state.Stakes()
+	previousHash := bc.LastBlock().Hash()
+	applyMissedSlots(stakes, previousHash, round)
+	return selectProposer(stakes, previousHash, round)
+}
+
+// round 이전 slot의 proposer들은 Block을 만들지 못했으므로 penalty
+func applyMissedSlots(stakes map[string]float32, previousHash [32]byte, round int) {
+	for r := 0; r < round; r++ {
+		missed := selectProposer(stakes, previousHash, r)
+		if missed != "" {
+			stakes[missed] -= stakes[missed] * MISSED_SLOT_PENALTY
+		}
+	}
+}
+
+func applyStakeBlock(stakes map[string]float32, slashed map[string]bool, b *Block) {
+	for _, e := range b.evidence {
+		if slashed[e.key()] {
+			continue
+		}
+		slashed[e.key()] = true
+		offender := e.Offender()
+		stakes[offender] -= stakes[offender] * DOUBLE_SIGN_PENALTY
+	}
+	for _, t := range b.transactions {
+		if t.recipientBlockchainAddress == STAKING_RECIPIENT {
+			stakes[t.senderBlockchainAddress] += t.value
+		}
+	}
+}
+
+// previous hash와 round로 seed를 만들어 stake 비율대로 proposer 선택
+func selectProposer(stakes map[string]float32, previousHash [32]byte, round int) string {
+	addresses := make([]string, 0, len(stakes))
+	for address, stake := range stakes {
+		if stake > 0 {
+			addresses = append(addresses, address)
+		}
+	}
+	if len(addresses) == 0 {
+		return ""
+	}
+	// 모든 노드에서 같은 합이 나오도록 정렬한 뒤에 더한다
+	sort.Strings(addresses)
+	var total float64
+	for _, address := range addresses {
+		total += float64(stakes[address])
+	}
+
+	seed := make([]byte, 40)
+	copy(seed, previousHash[:])
+	binary.BigEndian.PutUint64(seed[32:], uint64(round))
+	h := sha256.Sum256(seed)
+	target := float64(binary.BigEndian.Uint64(h[:8])) / math.MaxUint64 * total
+
+	var cumulative float64
+	for _, address := range addresses {
+		cumulative += float64(stakes[address])
+		if target < cumulative {
+			return address
+		}
+	}
+	return addresses[len(addresses)-1]
+}
+
+// 이전 Block 이후 경과한 시간으로 round 계산
+func slotRound(previous *Block, timestamp int64) int {
+	elapsed := timestamp - previous.timestamp
+	if elapsed < 0 {
+		return -1
+	}
+	return int(elapsed / int64(time.Second*STAKE_SLOT_SEC))
+}
+
+// 현재 slot의 proposer이면 Block을 서명해서 chain에 추가
+func (bc *Blockchain) Staking() bool {
+	if bc.validatorKey == nil {
+		log.Println("ERROR: validator key is not set")
+		return false
+	}
+
+	previous := bc.LastBlock()
+	previousHash := previous.Hash()
+	b := newBlockAt(bc.clock.Now(), 0, previousHash, nil)
+	b.round = slotRound(previous, b.timestamp)
+
+	// stake가 하나도 없으면 누구든 Block을 만들 수 있다
+	proposer := bc.NextProposer(b.round)
+	if proposer != "" && proposer != bc.blockchainAddress {
+		return false
+	}
+
+	pending := len(bc.transactionPool)
+	bc.AddTransaction(NewTransaction(MINING_SENDER, bc.blockchainAddress, MINING_REWARD), nil, nil)
+	b.transactions = bc.transactionPool
+	b.evidence = bc.evidencePool
+	err := bc.executeBlock(b)
+	if err == nil {
+		b.Sign(bc.validatorKey)
+		err = bc.appendBlock(b)
+	}
+	if err != nil {
+		log.Printf("ERROR: %v", err)
+		bc.transactionPool = bc.transactionPool[:pending]
+		bc.dropFailingTransactions()
+		return false
+	}
+	bc.evidencePool = nil
+	log.Printf("action=staking, status=success, round=%d", b.round)
+	return true
+}
+
+// 확정된 잔액에서 pool에 있는 지출을 빼고 남은 만큼만 stake할 수 있다
+func (bc *Blockchain) ValidStakeTransaction(t *Transaction) bool {
+	if t.recipientBlockchainAddress != STAKING_RECIPIENT {
+		return true
+	}
+	if t.value <= 0 {
+		log.Println("ERROR: stake must be positive")
+		return false
+	}
+	if bc.state.Balance(t.senderBlockchainAddress)-bc.PendingAmount(t.senderBlockchainAddress) < t.value {
+		log.Printf("ERROR: %v", ErrStakeExceedsBalance)
+		return false
+	}
+	return true
+}
+
+// 잔액을 넘는 stake는 State.execute가 거절한다
+func (bc *Blockchain) ValidStakeChain(chain []*Block) bool {
+	state := NewState()
+	if err := state.applyBlock(chain[0], 0); err != nil {
+		log.Printf("ERROR: %v", err)
+		return false
+	}
+
+	for i := 1; i < len(chain); i++ {
+		preBlock, b := chain[i-1], chain[i]
+		previousHash := preBlock.Hash()
+		if b.previousHash != previousHash {
+			return false
+		}
+		if b.round != slotRound(preBlock, b.timestamp) {
+			log.Printf("ERROR: invalid round %d", b.round)
+			return false
+		}
+
+		stakes := state.Stakes()
+		applyMissedSlots(stakes, previousHash, b.round)
+		proposer := selectProposer(stakes, previousHash, b.round)
+		if proposer != "" && proposer != b.proposer {
+			log.Printf("ERROR: unexpected proposer %s", b.proposer)
+			return false
+		}
+		if !b.ValidLockTimes(int64(i)) || !b.ValidMemos(bc.params.MaxMemoSize) || !b.ValidScripts(int64(i)) {
+			return false
+		}
+		if !b.VerifySignature() {
+			log.Println("ERROR: Verify Block Signature")
+			return false
+		}
+		for _, e := range b.evidence {
+			if !e.Valid() {
+				log.Println("ERROR: invalid double sign evidence")
+				return false
+			}
+		}
+
+		if err := state.applyBlock(b, i); err != nil {
+			log.Printf("ERROR: %v", err)
+			return false
+		}
+	}
+	return true
+}
+
+// 이웃 노드의 chain에서 같은 slot에 다른 Block을 서명한 proposer 탐지
+func (bc *Blockchain) CollectEvidence(chain []*Block) {
+	known := make(map[string]bool)
+	for _, e := range bc.evidencePool {
+		known[e.key()] = true
+	}
+	for _, b := range bc.chain {
+		for _, e := range b.evidence {
+			known[e.key()] = true
+		}
+	}
+
+	for i := 1; i < len(chain) && i < len(bc.chain); i++ {
+		e := NewEvidence(bc.chain[i], chain[i])
+		if known[e.key()] || !e.Valid() {
+			continue
+		}
+		known[e.key()] = true
+		bc.evidencePool = append(bc.evidencePool, e)
+		log.Printf("action=evidence, offender=%s", e.Offender())
+	}
+}
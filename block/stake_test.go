@@ -0,0 +1,196 @@
+package block
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sw90lee/blockchain_study/clock"
+	"github.com/sw90lee/blockchain_study/wallet"
+)
+
+func newStakeChain(validator *wallet.Wallet) (*Blockchain, *clock.Fake) {
+	c := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	bc := NewBlockchainWithClock(validator.BlockchainAddress(), 5000, c)
+	bc.SetConsensus(CONSENSUS_PROOF_OF_STAKE)
+	bc.SetValidatorKey(validator.PrivateKey())
+	return bc, c
+}
+
+func stake(bc *Blockchain, w *wallet.Wallet, value float32) bool {
+	s := wallet.NewTransaction(w.PrivateKey(), w.PublicKey(),
+		w.BlockchainAddress(), STAKING_RECIPIENT, value).GenerateSignature()
+	return bc.AddTransaction(NewTransaction(w.BlockchainAddress(), STAKING_RECIPIENT, value), w.PublicKey(), s)
+}
+
+func TestStakeLimitedToBalance(t *testing.T) {
+	validator := wallet.NewWallet()
+	bc, c := newStakeChain(validator)
+	if stake(bc, validator, 1) {
+		t.Fatal("stake without balance accepted")
+	}
+	c.Advance(STAKE_SLOT_SEC * time.Second)
+	if !bc.Staking() {
+		t.Fatal("staking failed")
+	}
+
+	// 보상만큼 잔액이 생겼고 pool에 있는 stake도 잔액에서 뺀다
+	if !stake(bc, validator, MINING_REWARD/2) {
+		t.Fatal("stake within balance rejected")
+	}
+	if stake(bc, validator, MINING_REWARD/2+0.5) {
+		t.Fatal("stake above remaining balance accepted")
+	}
+	c.Advance(STAKE_SLOT_SEC * time.Second)
+	if !bc.Staking() {
+		t.Fatal("staking failed")
+	}
+	if s := bc.StakeOf(validator.BlockchainAddress()); s != MINING_REWARD/2 {
+		t.Fatalf("unexpected stake %v", s)
+	}
+	if !bc.ValidChain(bc.Chain()) {
+		t.Fatal("own chain rejected")
+	}
+}
+
+// pool을 거치지 않은 Block의 stake도 chain 검증에서 거절
+func TestStakeChainRejectsUnfundedStake(t *testing.T) {
+	validator, mallory := wallet.NewWallet(), wallet.NewWallet()
+	bc, c := newStakeChain(validator)
+	c.Advance(STAKE_SLOT_SEC * time.Second)
+
+	genesis := bc.LastBlock()
+	b := newBlockAt(c.Now(), 0, genesis.Hash(),
+		[]*Transaction{NewTransaction(mallory.BlockchainAddress(), STAKING_RECIPIENT, 1000)})
+	b.round = slotRound(genesis, b.timestamp)
+	b.Sign(mallory.PrivateKey())
+
+	if _, err := NewState().execute(b, 1); !errors.Is(err, ErrStakeExceedsBalance) {
+		t.Fatalf("expected stake error, got %v", err)
+	}
+	if bc.ValidStakeChain([]*Block{genesis, b}) {
+		t.Fatal("unfunded stake accepted")
+	}
+}
+
+func TestSelectProposer(t *testing.T) {
+	previousHash := [32]byte{1}
+	stakes := map[string]float32{"alice": 300, "bob": 100, "carol": 0}
+	counts := make(map[string]int)
+	for round := 0; round < 2000; round++ {
+		proposer := selectProposer(stakes, previousHash, round)
+		if proposer != selectProposer(stakes, previousHash, round) {
+			t.Fatal("selection is not deterministic")
+		}
+		counts[proposer] += 1
+	}
+	if counts["carol"] != 0 {
+		t.Fatal("address without stake selected")
+	}
+	// stake 비율 3:1
+	if counts["alice"] < 1350 || counts["alice"] > 1650 {
+		t.Fatalf("unexpected distribution %v", counts)
+	}
+	if selectProposer(map[string]float32{}, previousHash, 0) != "" {
+		t.Fatal("proposer selected without stakes")
+	}
+}
+
+func TestMissedSlotPenalty(t *testing.T) {
+	previousHash := [32]byte{2}
+	stakes := map[string]float32{"alice": 100, "bob": 100}
+	missed := selectProposer(stakes, previousHash, 0)
+
+	penalized := map[string]float32{"alice": 100, "bob": 100}
+	applyMissedSlots(penalized, previousHash, 1)
+	if penalized[missed] != 100*(1-MISSED_SLOT_PENALTY) {
+		t.Fatalf("unexpected stakes %v", penalized)
+	}
+
+	applyMissedSlots(stakes, previousHash, 0)
+	if stakes["alice"] != 100 || stakes["bob"] != 100 {
+		t.Fatal("penalty without missed slot")
+	}
+}
+
+func TestDoubleSignSlashing(t *testing.T) {
+	offender := wallet.NewWallet()
+	address := offender.BlockchainAddress()
+	previous := [32]byte{3}
+	first := newBlockAt(time.Unix(100, 0), 0, previous, nil)
+	second := newBlockAt(time.Unix(101, 0), 0, previous, nil)
+	first.Sign(offender.PrivateKey())
+	second.Sign(offender.PrivateKey())
+
+	e := NewEvidence(first, second)
+	if !e.Valid() || e.Offender() != address {
+		t.Fatal("evidence rejected")
+	}
+	second.round = 1
+	second.Sign(offender.PrivateKey())
+	if NewEvidence(first, second).Valid() {
+		t.Fatal("blocks in different slots accepted as evidence")
+	}
+
+	stakes := map[string]float32{address: 100, "bob": 100}
+	slashed := make(map[string]bool)
+	b := &Block{evidence: []*Evidence{e}}
+	applyStakeBlock(stakes, slashed, b)
+	if stakes[address] != 100*(1-DOUBLE_SIGN_PENALTY) || stakes["bob"] != 100 {
+		t.Fatalf("unexpected stakes %v", stakes)
+	}
+
+	// 같은 증거는 한 번만 적용
+	stakes[address] = 50
+	applyStakeBlock(stakes, slashed, b)
+	if stakes[address] != 50 {
+		t.Fatal("evidence applied twice")
+	}
+}
+
+// 적용할 수 없는 Transaction이 있으면 Block을 붙이지 않고 보상도 pool에 남기지 않는다
+// 실패한 Transaction만 pool에서 빠지므로 다음 Block은 만들 수 있다
+func TestMiningSkipsInvalidBlock(t *testing.T) {
+	bc := newTestChain()
+	mallory := wallet.NewWallet()
+	good := NewTransaction(MINING_SENDER, "bob", 1)
+	bc.transactionPool = append(bc.transactionPool,
+		NewTransaction(mallory.BlockchainAddress(), STAKING_RECIPIENT, 1000), good)
+	root := bc.state.Root()
+	if bc.Mining() {
+		t.Fatal("invalid block mined")
+	}
+	if len(bc.Chain()) != 1 || bc.state.Root() != root {
+		t.Fatal("failed block changed the chain")
+	}
+	if pool := bc.TransactionPool(); len(pool) != 1 || pool[0] != good {
+		t.Fatalf("unexpected pool %v", pool)
+	}
+
+	if !bc.Mining() {
+		t.Fatal("mining stopped after a bad transaction")
+	}
+	if len(bc.Chain()) != 2 || bc.state.Balance("bob") != 1 {
+		t.Fatal("next block not mined")
+	}
+}
+
+// Proof of Stake에서도 실패한 Transaction을 빼고 다음 slot에 Block을 만든다
+func TestStakingSkipsInvalidBlock(t *testing.T) {
+	validator, mallory := wallet.NewWallet(), wallet.NewWallet()
+	bc, c := newStakeChain(validator)
+	bc.transactionPool = append(bc.transactionPool,
+		NewTransaction(mallory.BlockchainAddress(), STAKING_RECIPIENT, 1000))
+	c.Advance(STAKE_SLOT_SEC * time.Second)
+	if bc.Mining() {
+		t.Fatal("invalid block proposed")
+	}
+	if len(bc.Chain()) != 1 || len(bc.TransactionPool()) != 0 {
+		t.Fatal("failing transaction kept in the pool")
+	}
+
+	c.Advance(STAKE_SLOT_SEC * time.Second)
+	if !bc.Mining() || len(bc.Chain()) != 2 {
+		t.Fatal("validator stopped proposing after a bad transaction")
+	}
+}
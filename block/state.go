@@ -0,0 +1,231 @@
+package block
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/sw90lee/blockchain_study/token"
+	"github.com/sw90lee/blockchain_study/vm"
+)
+
+var (
+	ErrPrunedBlock         = errors.New("block body has been pruned")
+	ErrStakeExceedsBalance = errors.New("stake exceeds balance")
+	// 홀수 층은 마지막 hash를 복사하므로 [a,b,c]와 [a,b,c,c]의 Merkle root가 같다
+	ErrDuplicateTransaction = errors.New("duplicate transaction")
+)
+
+// chain의 Block을 앞에서부터 적용한 결과
+type State struct {
+	balances  map[string]float32
+	tokens    *token.Ledger
+	nfts      *token.NFTRegistry
+	contracts *vm.State
+	stakes    map[string]float32
+	slashed   map[string]bool
+}
+
+func NewState() *State {
+	return &State{
+		balances:  make(map[string]float32),
+		tokens:    token.NewLedger(),
+		nfts:      token.NewNFTRegistry(),
+		contracts: vm.NewState(),
+		stakes:    make(map[string]float32),
+		slashed:   make(map[string]bool),
+	}
+}
+
+func (s *State) Copy() *State {
+	c := &State{
+		balances:  make(map[string]float32, len(s.balances)),
+		tokens:    s.tokens.Copy(),
+		nfts:      s.nfts.Copy(),
+		contracts: s.contracts.Copy(),
+		stakes:    s.Stakes(),
+		slashed:   make(map[string]bool, len(s.slashed)),
+	}
+	for address, amount := range s.balances {
+		c.balances[address] = amount
+	}
+	for key := range s.slashed {
+		c.slashed[key] = true
+	}
+	return c
+}
+
+func (s *State) Balance(blockchainAddress string) float32 {
+	return s.balances[blockchainAddress]
+}
+
+func (s *State) Stakes() map[string]float32 {
+	stakes := make(map[string]float32, len(s.stakes))
+	for address, stake := range s.stakes {
+		stakes[address] = stake
+	}
+	return stakes
+}
+
+func (s *State) Tokens() *token.Ledger {
+	return s.tokens
+}
+
+func (s *State) NFTs() *token.NFTRegistry {
+	return s.nfts
+}
+
+func (s *State) Contracts() *vm.State {
+	return s.contracts
+}
+
+// 잔액, token, NFT, contract, stake 전체의 hash
+func (s *State) Root() [32]byte {
+	m, _ := json.Marshal(s)
+	return sha256.Sum256(m)
+}
+
+// height 높이의 Block을 적용, 오류가 나면 s는 일부만 바뀌었으므로 버려야 한다
+func (s *State) applyBlock(b *Block, height int) error {
+	receipts, err := s.execute(b, height)
+	if err != nil {
+		return err
+	}
+	got, _ := json.Marshal(receipts)
+	want, _ := json.Marshal(b.receipts)
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("invalid receipts at block %d", height)
+	}
+	if s.Root() != b.stateRoot {
+		return fmt.Errorf("invalid state root at block %d", height)
+	}
+	return nil
+}
+
+// Block의 Transaction을 적용하고 contract 실행 결과를 돌려준다
+func (s *State) execute(b *Block, height int) ([]*Receipt, error) {
+	if b.pruned {
+		return nil, ErrPrunedBlock
+	}
+	seen := make(map[[32]byte]bool, len(b.transactions))
+	for _, t := range b.transactions {
+		h := t.Hash()
+		if seen[h] {
+			return nil, fmt.Errorf("%w at block %d: %x", ErrDuplicateTransaction, height, h)
+		}
+		seen[h] = true
+	}
+
+	receipts := executeTransactions(s.contracts, b, height)
+	if err := applyNFTBlock(s.nfts, b, height); err != nil {
+		return nil, fmt.Errorf("invalid nft transaction at block %d: %w", height, err)
+	}
+
+	for _, t := range b.transactions {
+		if t.recipientBlockchainAddress == STAKING_RECIPIENT && s.balances[t.senderBlockchainAddress] < t.value {
+			return nil, fmt.Errorf("%w at block %d: %s", ErrStakeExceedsBalance, height, t.senderBlockchainAddress)
+		}
+		s.balances[t.recipientBlockchainAddress] += t.value
+		s.balances[t.senderBlockchainAddress] -= t.value
+		if t.token != nil {
+			// 적용할 수 없는 token Transaction은 무시
+			s.tokens.Apply(t.senderBlockchainAddress, t.recipientBlockchainAddress, t.token)
+		}
+	}
+	for _, r := range receipts {
+		s.balances[b.transactions[r.index].senderBlockchainAddress] -= r.fee
+		s.balances[r.feeRecipient] += r.fee
+	}
+
+	if height > 0 {
+		applyMissedSlots(s.stakes, b.previousHash, b.round)
+	}
+	applyStakeBlock(s.stakes, s.slashed, b)
+	return receipts, nil
+}
+
+// chain을 처음부터 다시 적용
+func ReplayState(chain []*Block) (*State, bool) {
+	s, _, ok := replayState(chain)
+	return s, ok
+}
+
+// 마지막 SNAPSHOT_INTERVAL 높이의 snapshot도 함께 만든다
+func replayState(chain []*Block) (*State, *Snapshot, bool) {
+	s := NewState()
+	var snapshot *Snapshot
+	for i, b := range chain {
+		if err := s.applyBlock(b, i); err != nil {
+			log.Printf("ERROR: %v", err)
+			return nil, nil, false
+		}
+		if i > 0 && i%SNAPSHOT_INTERVAL == 0 {
+			snapshot = NewSnapshot(i, b.Hash(), s.Copy())
+		}
+	}
+	return s, snapshot, true
+}
+
+func (s *State) MarshalJSON() ([]byte, error) {
+	slashed := make([]string, 0, len(s.slashed))
+	for key := range s.slashed {
+		slashed = append(slashed, key)
+	}
+	sort.Strings(slashed)
+	return json.Marshal(struct {
+		Balances  map[string]float32 `json:"balances"`
+		Tokens    *token.Ledger      `json:"tokens"`
+		NFTs      *token.NFTRegistry `json:"nfts"`
+		Contracts *vm.State          `json:"contracts"`
+		Stakes    map[string]float32 `json:"stakes"`
+		Slashed   []string           `json:"slashed"`
+	}{
+		Balances:  s.balances,
+		Tokens:    s.tokens,
+		NFTs:      s.nfts,
+		Contracts: s.contracts,
+		Stakes:    s.stakes,
+		Slashed:   slashed,
+	})
+}
+
+func (s *State) UnmarshalJSON(data []byte) error {
+	*s = *NewState()
+	var slashed []string
+	v := &struct {
+		Balances  *map[string]float32 `json:"balances"`
+		Tokens    *token.Ledger       `json:"tokens"`
+		NFTs      *token.NFTRegistry  `json:"nfts"`
+		Contracts *vm.State           `json:"contracts"`
+		Stakes    *map[string]float32 `json:"stakes"`
+		Slashed   *[]string           `json:"slashed"`
+	}{
+		Balances:  &s.balances,
+		Tokens:    s.tokens,
+		NFTs:      s.nfts,
+		Contracts: s.contracts,
+		Stakes:    &s.stakes,
+		Slashed:   &slashed,
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if s.balances == nil {
+		s.balances = make(map[string]float32)
+	}
+	if s.stakes == nil {
+		s.stakes = make(map[string]float32)
+	}
+	for _, key := range slashed {
+		s.slashed[key] = true
+	}
+	return nil
+}
+
+func (bc *Blockchain) State() *State {
+	return bc.state
+}
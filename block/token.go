@@ -0,0 +1,79 @@
+package block
+
+import (
+	"log"
+	"sort"
+
+	"github.com/sw90lee/blockchain_study/token"
+)
+
+// chain의 token Transaction을 순서대로 적용한 결과
+func (bc *Blockchain) TokenLedger() *token.Ledger {
+	return bc.state.tokens
+}
+
+func (bc *Blockchain) CalculateTokenAmount(blockchainAddress string, symbol string) uint64 {
+	return bc.TokenLedger().BalanceOf(symbol, blockchainAddress)
+}
+
+// pool에 있는 Transaction까지 적용했을 때 token Transaction이 유효한지 확인
+func (bc *Blockchain) ValidTokenTransaction(t *Transaction) bool {
+	if t.token == nil {
+		return true
+	}
+	if t.value != 0 || t.contract != nil || t.senderBlockchainAddress == MINING_SENDER {
+		log.Println("ERROR: token transaction can not carry value or contract")
+		return false
+	}
+
+	ledger := bc.TokenLedger().Copy()
+	for _, pool := range [][]*Transaction{bc.transactionPool, bc.lockedPool} {
+		for _, p := range pool {
+			if p.token != nil {
+				ledger.Apply(p.senderBlockchainAddress, p.recipientBlockchainAddress, p.token)
+			}
+		}
+	}
+	if err := ledger.Apply(t.senderBlockchainAddress, t.recipientBlockchainAddress, t.token); err != nil {
+		log.Printf("ERROR: %v", err)
+		return false
+	}
+	return true
+}
+
+type TokenAmountResponse struct {
+	Token    string `json:"token"`
+	Decimals uint8  `json:"decimals"`
+	Balance  uint64 `json:"balance"`
+	Amount   string `json:"amount"`
+}
+
+type TokenResponse struct {
+	Symbol   string `json:"symbol"`
+	Decimals uint8  `json:"decimals"`
+	Issuer   string `json:"issuer"`
+	Supply   string `json:"supply"`
+	Balance  string `json:"balance,omitempty"`
+}
+
+// 발행된 token 목록, blockchainAddress가 있으면 잔액도 함께
+func (bc *Blockchain) TokenList(blockchainAddress string) []*TokenResponse {
+	ledger := bc.TokenLedger()
+	tokens := make([]*TokenResponse, 0)
+	for symbol, t := range ledger.Tokens() {
+		tr := &TokenResponse{
+			Symbol:   symbol,
+			Decimals: t.Decimals(),
+			Issuer:   t.Issuer(),
+			Supply:   token.FormatAmount(t.Supply(), t.Decimals()),
+		}
+		if blockchainAddress != "" {
+			tr.Balance = token.FormatAmount(ledger.BalanceOf(symbol, blockchainAddress), t.Decimals())
+		}
+		tokens = append(tokens, tr)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].Symbol < tokens[j].Symbol
+	})
+	return tokens
+}
@@ -0,0 +1,73 @@
+package block
+
+import (
+	"testing"
+
+	"github.com/sw90lee/blockchain_study/token"
+	"github.com/sw90lee/blockchain_study/wallet"
+)
+
+func newTestChain() *Blockchain {
+	bc := NewBlockchain("miner", 5000)
+	p := DefaultParams()
+	p.MiningDifficulty = 1
+	bc.SetParams(p)
+	return bc
+}
+
+// signer의 key로 서명한 token Transaction, sender는 signer의 주소가 아닐 수도 있다
+func addToken(bc *Blockchain, signer *wallet.Wallet, sender string, recipient string, p *token.Payload) bool {
+	wt := wallet.NewTransaction(signer.PrivateKey(), signer.PublicKey(), sender, recipient, 0)
+	wt.SetToken(p)
+	bt := NewTransaction(sender, recipient, 0)
+	bt.SetToken(p)
+	return bc.AddTransaction(bt, signer.PublicKey(), wt.GenerateSignature())
+}
+
+func TestTokenIssueAndTransfer(t *testing.T) {
+	bc := newTestChain()
+	alice, bob := wallet.NewWallet(), wallet.NewWallet()
+	if !addToken(bc, alice, alice.BlockchainAddress(), alice.BlockchainAddress(), token.NewIssuePayload("GOLD", 2, 1000)) {
+		t.Fatal("issue rejected")
+	}
+	if !bc.Mining() {
+		t.Fatal("mining failed")
+	}
+	if !addToken(bc, alice, alice.BlockchainAddress(), bob.BlockchainAddress(), token.NewTransferPayload("GOLD", 300)) {
+		t.Fatal("transfer rejected")
+	}
+	if !bc.Mining() {
+		t.Fatal("mining failed")
+	}
+	if a, b := bc.CalculateTokenAmount(alice.BlockchainAddress(), "GOLD"), bc.CalculateTokenAmount(bob.BlockchainAddress(), "GOLD"); a != 700 || b != 300 {
+		t.Fatalf("unexpected balances %d %d", a, b)
+	}
+	if addToken(bc, bob, bob.BlockchainAddress(), bob.BlockchainAddress(), token.NewIssuePayload("GOLD", 2, 1)) {
+		t.Fatal("issue by non-issuer accepted")
+	}
+}
+
+// 자기 key로 서명하면서 issuer나 sender를 다른 주소로 적은 Transaction은 거절
+func TestTokenForgedSender(t *testing.T) {
+	bc := newTestChain()
+	alice, mallory := wallet.NewWallet(), wallet.NewWallet()
+	if !addToken(bc, alice, alice.BlockchainAddress(), alice.BlockchainAddress(), token.NewIssuePayload("GOLD", 0, 1000)) {
+		t.Fatal("issue rejected")
+	}
+	if !bc.Mining() {
+		t.Fatal("mining failed")
+	}
+
+	if addToken(bc, mallory, alice.BlockchainAddress(), mallory.BlockchainAddress(), token.NewIssuePayload("GOLD", 0, 1000)) {
+		t.Fatal("issue signed by another key accepted")
+	}
+	if addToken(bc, mallory, alice.BlockchainAddress(), mallory.BlockchainAddress(), token.NewTransferPayload("GOLD", 1000)) {
+		t.Fatal("transfer signed by another key accepted")
+	}
+	if len(bc.TransactionPool()) != 0 {
+		t.Fatal("forged transaction in pool")
+	}
+	if bc.CalculateTokenAmount(mallory.BlockchainAddress(), "GOLD") != 0 {
+		t.Fatal("forged transfer applied")
+	}
+}
@@ -1,29 +1,47 @@
 package main
 
 import (
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/sw90lee/blockchain_study/block"
+	"github.com/sw90lee/blockchain_study/bloom"
+	"github.com/sw90lee/blockchain_study/config"
+	"github.com/sw90lee/blockchain_study/metrics"
+	"github.com/sw90lee/blockchain_study/p2p"
+	"github.com/sw90lee/blockchain_study/token"
 	"github.com/sw90lee/blockchain_study/utils"
+	"github.com/sw90lee/blockchain_study/vm"
 	"github.com/sw90lee/blockchain_study/wallet"
+	"github.com/sw90lee/blockchain_study/webhook"
 )
 
+// 연결이 끊긴 것을 알아채도록 보내는 주석 줄 간격
+const EVENTS_PING_SEC = 15
+
 var cache map[string]*block.Blockchain = make(map[string]*block.Blockchain)
 
 type BlockchainServer struct {
-	port uint16
+	config   *config.Node
+	webhooks *webhook.Manager
+	// p2p_port가 0이면 nil
+	p2p *p2p.Server
 }
 
-func NewBlockchainServer(port uint16) *BlockchainServer {
-	return &BlockchainServer{port}
+func NewBlockchainServer(config *config.Node) *BlockchainServer {
+	return &BlockchainServer{config: config}
 }
 
 func (bcs *BlockchainServer) Port() uint16 {
-	return bcs.port
+	return bcs.config.Port
 }
 
 func (bcs *BlockchainServer) GetBlockchain() *block.Blockchain {
@@ -31,6 +49,11 @@ func (bcs *BlockchainServer) GetBlockchain() *block.Blockchain {
 	if !ok {
 		minersWallet := wallet.NewWallet()
 		bc = block.NewBlockchain(minersWallet.BlockchainAddress(), bcs.Port())
+		bc.SetParams(bcs.config.Params())
+		bc.SetConsensus(bcs.config.Consensus)
+		bc.SetValidatorKey(minersWallet.PrivateKey())
+		bc.SetPruneDepth(bcs.config.Prune)
+		bc.SetFastSync(bcs.config.FastSync)
 		cache["blockchain"] = bc
 		log.Printf("private_key %v", minersWallet.PrivateKeyStr())
 		log.Printf("publick_key %v", minersWallet.PublicKeyStr())
@@ -59,11 +82,13 @@ func (bcs *BlockchainServer) Transactions(w http.ResponseWriter, req *http.Reque
 		bc := bcs.GetBlockchain()
 		transactions := bc.TransactionPool()
 		m, _ := json.Marshal(struct {
-			Transactions []*block.Transaction `json:"transactions"`
-			Length       int                  `json:"length"`
+			Transactions       []*block.Transaction `json:"transactions"`
+			Length             int                  `json:"length"`
+			LockedTransactions []*block.Transaction `json:"locked_transactions"`
 		}{
-			Transactions: transactions,
-			Length:       len(transactions),
+			Transactions:       transactions,
+			Length:             len(transactions),
+			LockedTransactions: bc.LockedTransactionPool(),
 		})
 		io.WriteString(w, string(m[:]))
 
@@ -81,11 +106,20 @@ func (bcs *BlockchainServer) Transactions(w http.ResponseWriter, req *http.Reque
 			io.WriteString(w, string(utils.JsonStatus("fail")))
 			return
 		}
-		publicKey := utils.PublicKeyFromString(*t.SenderPublicKey)
-		signature := utils.SignatureFromString(*t.Signature)
 		bc := bcs.GetBlockchain()
-		isCreated := bc.CreateTransaction(*t.SenderBlockchainAddress,
-			*t.RecipientBlockchainAddress, *t.Value, publicKey, signature)
+		var isCreated bool
+		if t.IsScript() {
+			lockingScript, unlockingScript, ok := t.Scripts()
+			isCreated = ok && bc.CreateScriptTransaction(t.Transaction(), lockingScript, unlockingScript)
+		} else if t.IsMultisig() {
+			publicKeys, signatures, ok := t.MultisigKeys()
+			isCreated = ok && bc.CreateMultisigTransaction(t.Transaction(),
+				*t.MultisigRequired, publicKeys, signatures)
+		} else {
+			publicKey := utils.PublicKeyFromString(*t.SenderPublicKey)
+			signature := utils.SignatureFromString(*t.Signature)
+			isCreated = bc.CreateTransaction(t.Transaction(), publicKey, signature)
+		}
 
 		w.Header().Add("Content-Type", "application/json")
 		var m []byte
@@ -111,11 +145,20 @@ func (bcs *BlockchainServer) Transactions(w http.ResponseWriter, req *http.Reque
 			io.WriteString(w, string(utils.JsonStatus("fail")))
 			return
 		}
-		publicKey := utils.PublicKeyFromString(*t.SenderPublicKey)
-		signature := utils.SignatureFromString(*t.Signature)
 		bc := bcs.GetBlockchain()
-		isUpdated := bc.AddTransaction(*t.SenderBlockchainAddress,
-			*t.RecipientBlockchainAddress, *t.Value, publicKey, signature)
+		var isUpdated bool
+		if t.IsScript() {
+			lockingScript, unlockingScript, ok := t.Scripts()
+			isUpdated = ok && bc.AddScriptTransaction(t.Transaction(), lockingScript, unlockingScript)
+		} else if t.IsMultisig() {
+			publicKeys, signatures, ok := t.MultisigKeys()
+			isUpdated = ok && bc.AddMultisigTransaction(t.Transaction(),
+				*t.MultisigRequired, publicKeys, signatures)
+		} else {
+			publicKey := utils.PublicKeyFromString(*t.SenderPublicKey)
+			signature := utils.SignatureFromString(*t.Signature)
+			isUpdated = bc.AddTransaction(t.Transaction(), publicKey, signature)
+		}
 
 		w.Header().Add("Content-Type", "application/json")
 		var m []byte
@@ -173,10 +216,83 @@ func (bcs *BlockchainServer) StartMining(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+func (bcs *BlockchainServer) StopMining(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		bcs.GetBlockchain().StopMining()
+		m := utils.JsonStatus("success")
+
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m))
+	default:
+		log.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// server-sent events, /events?type=block,transaction&address=
+func (bcs *BlockchainServer) Events(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		q := r.URL.Query()
+		types := make(map[string]bool)
+		for _, t := range strings.Split(q.Get("type"), ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				types[t] = true
+			}
+		}
+		address := q.Get("address")
+
+		bc := bcs.GetBlockchain()
+		id, events := bc.Subscribe()
+		defer bc.Unsubscribe(id)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		ping := time.NewTicker(EVENTS_PING_SEC * time.Second)
+		defer ping.Stop()
+		for {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+				if !e.Match(types, address) {
+					continue
+				}
+				m, _ := json.Marshal(e)
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, m)
+				flusher.Flush()
+			case <-ping.C:
+				io.WriteString(w, ": ping\n\n")
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	default:
+		log.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
 func (bcs *BlockchainServer) Amount(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
 		blockchainAddress := r.URL.Query().Get("blockchain_address")
+		if symbol := r.URL.Query().Get("token"); symbol != "" {
+			bcs.tokenAmount(w, blockchainAddress, symbol)
+			return
+		}
 		amount := bcs.GetBlockchain().CalculateTotalAmount(blockchainAddress)
 
 		ar := &block.AmountResponse{amount}
@@ -191,14 +307,602 @@ func (bcs *BlockchainServer) Amount(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (bcs *BlockchainServer) tokenAmount(w http.ResponseWriter, blockchainAddress string, symbol string) {
+	ledger := bcs.GetBlockchain().TokenLedger()
+	t := ledger.Token(symbol)
+	if t == nil {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, string(utils.JsonStatus("unknown token")))
+		return
+	}
+	balance := ledger.BalanceOf(symbol, blockchainAddress)
+	m, _ := json.Marshal(&block.TokenAmountResponse{
+		Token:    symbol,
+		Decimals: t.Decimals(),
+		Balance:  balance,
+		Amount:   token.FormatAmount(balance, t.Decimals()),
+	})
+
+	w.Header().Add("Content-Type", "application/json")
+	io.WriteString(w, string(m[:]))
+}
+
+func (bcs *BlockchainServer) Tokens(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		blockchainAddress := r.URL.Query().Get("blockchain_address")
+		m, _ := json.Marshal(struct {
+			Tokens []*block.TokenResponse `json:"tokens"`
+		}{
+			Tokens: bcs.GetBlockchain().TokenList(blockchainAddress),
+		})
+
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// id로 NFT 하나, owner로 소유한 NFT 목록
+func (bcs *BlockchainServer) NFTs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		registry := bcs.GetBlockchain().NFTRegistry()
+		var m []byte
+		if id := r.URL.Query().Get("id"); id != "" {
+			n := registry.NFT(id)
+			if n == nil {
+				w.WriteHeader(http.StatusNotFound)
+				io.WriteString(w, string(utils.JsonStatus("not found")))
+				return
+			}
+			m, _ = json.Marshal(n)
+		} else {
+			nfts := registry.OwnedBy(r.URL.Query().Get("owner"))
+			sort.Slice(nfts, func(i, j int) bool {
+				return nfts[i].Id() < nfts[j].Id()
+			})
+			m, _ = json.Marshal(struct {
+				NFTs []*token.NFT `json:"nfts"`
+			}{
+				NFTs: nfts,
+			})
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// 정리(prune)된 높이를 알려서 이웃이 없는 Block을 요청하지 않게 한다
+func (bcs *BlockchainServer) Status(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		m, _ := json.Marshal(bcs.GetBlockchain().Status())
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// 새 노드가 받아서 header의 state root와 비교할 최근 상태
+func (bcs *BlockchainServer) Snapshot(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		snapshot := bcs.GetBlockchain().Snapshot()
+		if snapshot == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		m, _ := json.Marshal(snapshot)
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (bcs *BlockchainServer) Headers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		from := 0
+		if v := r.URL.Query().Get("from"); v != "" {
+			var err error
+			if from, err = strconv.Atoi(v); err != nil {
+				log.Println("ERROR: invalid from")
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		m, _ := json.Marshal(bcs.GetBlockchain().HeadersFrom(from))
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// 최신 Block부터 n개의 header
+func (bcs *BlockchainServer) LatestHeaders(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		n := 10
+		if v := r.URL.Query().Get("n"); v != "" {
+			var err error
+			if n, err = strconv.Atoi(v); err != nil || n <= 0 || n > block.LATEST_HEADERS_LIMIT {
+				log.Println("ERROR: invalid n")
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		m, _ := json.Marshal(bcs.GetBlockchain().LatestHeaders(n))
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// /block?height= 또는 /block?hash=
+func (bcs *BlockchainServer) Block(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		bc := bcs.GetBlockchain()
+		q := r.URL.Query()
+		var b *block.BlockResponse
+		var ok bool
+		if v := q.Get("hash"); v != "" {
+			h, err := hex.DecodeString(v)
+			if err != nil || len(h) != 32 {
+				log.Println("ERROR: invalid hash")
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			var hash [32]byte
+			copy(hash[:], h)
+			b, ok = bc.BlockByHash(hash)
+		} else {
+			height, err := strconv.Atoi(q.Get("height"))
+			if err != nil {
+				log.Println("ERROR: invalid height")
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			b, ok = bc.BlockByHeight(height)
+		}
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		m, _ := json.Marshal(b)
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (bcs *BlockchainServer) Summary(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		m, _ := json.Marshal(bcs.GetBlockchain().Summary())
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// q를 Block, Transaction, 주소 중 하나로 찾는다
+func (bcs *BlockchainServer) Search(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		q := strings.TrimSpace(r.URL.Query().Get("q"))
+		if q == "" {
+			log.Println("ERROR: missing q")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		result, ok := bcs.GetBlockchain().Search(q)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		m, _ := json.Marshal(result)
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// from 높이부터의 Block, 이미 body를 버린 높이면 410
+func (bcs *BlockchainServer) Blocks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		from, err := strconv.Atoi(r.URL.Query().Get("from"))
+		if err != nil {
+			log.Println("ERROR: invalid from")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		blocks, ok := bcs.GetBlockchain().BlocksFrom(from)
+		if !ok {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		m, _ := json.Marshal(blocks)
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// /addresses/{address}/transactions?from_height=&to_height=&limit=
+// /addresses/{address}/proofs는 같은 범위의 Transaction을 Merkle proof와 함께 돌려준다
+func (bcs *BlockchainServer) Addresses(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		path := strings.TrimPrefix(r.URL.Path, "/addresses/")
+		i := strings.LastIndex(path, "/")
+		if i <= 0 || strings.Contains(path[:i], "/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		address, resource := path[:i], path[i+1:]
+		if resource != "transactions" && resource != "proofs" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		q := r.URL.Query()
+		fromHeight, toHeight, limit := 0, -1, block.ADDRESS_TRANSACTIONS_LIMIT
+		var err error
+		if v := q.Get("from_height"); v != "" {
+			if fromHeight, err = strconv.Atoi(v); err != nil {
+				log.Println("ERROR: invalid from_height")
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		if v := q.Get("to_height"); v != "" {
+			if toHeight, err = strconv.Atoi(v); err != nil {
+				log.Println("ERROR: invalid to_height")
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		if v := q.Get("limit"); v != "" {
+			if limit, err = strconv.Atoi(v); err != nil || limit <= 0 || limit > block.ADDRESS_TRANSACTIONS_LIMIT {
+				log.Println("ERROR: invalid limit")
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+
+		bc := bcs.GetBlockchain()
+		var m []byte
+		if resource == "proofs" {
+			proofs, next := bc.AddressProofs(address, fromHeight, toHeight, limit)
+			m, _ = json.Marshal(&block.AddressProofsResponse{
+				Address:    address,
+				Proofs:     proofs,
+				NextHeight: next,
+			})
+		} else {
+			transactions, next := bc.AddressTransactions(address, fromHeight, toHeight, limit)
+			m, _ = json.Marshal(&block.AddressTransactionsResponse{
+				Address:      address,
+				Transactions: transactions,
+				NextHeight:   next,
+			})
+		}
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// light client의 Bloom filter 등록(POST)과 해제(DELETE ?id=)
+func (bcs *BlockchainServer) Filter(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		var f bloom.Filter
+		decoder := json.NewDecoder(r.Body)
+		if err := decoder.Decode(&f); err != nil {
+			log.Printf("ERROR: %v", err)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		id, err := bcs.GetBlockchain().LoadFilter(&f)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		m, _ := json.Marshal(struct {
+			Id string `json:"id"`
+		}{
+			Id: id,
+		})
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, string(m[:]))
+	case http.MethodDelete:
+		bcs.GetBlockchain().RemoveFilter(r.URL.Query().Get("id"))
+		io.WriteString(w, string(utils.JsonStatus("success")))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// /filter/blocks?id=&from=&limit=
+func (bcs *BlockchainServer) FilteredBlocks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		from, err := strconv.Atoi(q.Get("from"))
+		if err != nil {
+			log.Println("ERROR: invalid from")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		limit := block.FILTER_BLOCKS_LIMIT
+		if v := q.Get("limit"); v != "" {
+			if limit, err = strconv.Atoi(v); err != nil || limit <= 0 || limit > block.FILTER_BLOCKS_LIMIT {
+				log.Println("ERROR: invalid limit")
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		blocks, err := bcs.GetBlockchain().FilteredBlocks(q.Get("id"), from, limit)
+		if !writeFilterError(w, err) {
+			return
+		}
+		m, _ := json.Marshal(blocks)
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// /filter/transactions?id=
+func (bcs *BlockchainServer) FilteredTransactions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		transactions, err := bcs.GetBlockchain().FilteredTransactions(r.URL.Query().Get("id"))
+		if !writeFilterError(w, err) {
+			return
+		}
+		m, _ := json.Marshal(struct {
+			Transactions []*block.Transaction `json:"transactions"`
+		}{
+			Transactions: transactions,
+		})
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// 오류가 없으면 true
+func writeFilterError(w http.ResponseWriter, err error) bool {
+	switch err {
+	case nil:
+		return true
+	case block.ErrUnknownFilter:
+		w.WriteHeader(http.StatusNotFound)
+	case block.ErrPrunedBlock:
+		w.WriteHeader(http.StatusGone)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	log.Printf("ERROR: %v", err)
+	return false
+}
+
+// 구독 등록(POST), 목록(GET), 해제(DELETE ?id=)
+func (bcs *BlockchainServer) Webhooks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		var req struct {
+			Url           *string   `json:"url"`
+			Addresses     *[]string `json:"addresses"`
+			Confirmations *int      `json:"confirmations"`
+		}
+		decoder := json.NewDecoder(r.Body)
+		if err := decoder.Decode(&req); err != nil || req.Url == nil || req.Addresses == nil {
+			log.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		confirmations := 1
+		if req.Confirmations != nil {
+			confirmations = *req.Confirmations
+		}
+		s, err := bcs.webhooks.Subscribe(*req.Url, *req.Addresses, confirmations)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		// secret은 등록할 때 한 번만 알려준다
+		m, _ := json.Marshal(s)
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, string(m[:]))
+	case http.MethodGet:
+		m, _ := json.Marshal(bcs.webhooks.Subscriptions())
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m[:]))
+	case http.MethodDelete:
+		if !bcs.webhooks.Unsubscribe(r.URL.Query().Get("id")) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, string(utils.JsonStatus("success")))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (bcs *BlockchainServer) Validators(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		bc := bcs.GetBlockchain()
+		stakes := bc.Stakes()
+		m, _ := json.Marshal(struct {
+			Consensus    string             `json:"consensus"`
+			Stakes       map[string]float32 `json:"stakes"`
+			NextProposer string             `json:"next_proposer"`
+		}{
+			Consensus:    bc.Consensus(),
+			Stakes:       stakes,
+			NextProposer: bc.NextProposer(0),
+		})
+
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// address가 없으면 배포된 contract 주소 목록
+func (bcs *BlockchainServer) Contracts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		contracts := bcs.GetBlockchain().Contracts()
+		address := r.URL.Query().Get("address")
+		var m []byte
+		if address == "" {
+			addresses := make([]string, 0)
+			for a := range contracts.Contracts() {
+				addresses = append(addresses, a)
+			}
+			sort.Strings(addresses)
+			m, _ = json.Marshal(struct {
+				Contracts []string `json:"contracts"`
+			}{
+				Contracts: addresses,
+			})
+		} else {
+			c := contracts.Contract(address)
+			if c == nil {
+				w.WriteHeader(http.StatusNotFound)
+				io.WriteString(w, string(utils.JsonStatus("not found")))
+				return
+			}
+			storage := make(map[string]string)
+			for k, v := range c.Storage() {
+				storage[hex.EncodeToString([]byte(k))] = hex.EncodeToString(v)
+			}
+			assembly, _ := vm.Disassemble(c.Code())
+			m, _ = json.Marshal(struct {
+				Address  string            `json:"address"`
+				Code     string            `json:"code"`
+				Assembly string            `json:"assembly"`
+				Storage  map[string]string `json:"storage"`
+			}{
+				Address:  address,
+				Code:     hex.EncodeToString(c.Code()),
+				Assembly: assembly,
+				Storage:  storage,
+			})
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// state를 바꾸지 않는 contract 호출 (input은 콤마로 구분한 hex)
+func (bcs *BlockchainServer) ContractCall(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		var input [][]byte
+		if q.Get("input") != "" {
+			for _, h := range strings.Split(q.Get("input"), ",") {
+				b, err := hex.DecodeString(h)
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					io.WriteString(w, string(utils.JsonStatus("fail")))
+					return
+				}
+				input = append(input, b)
+			}
+		}
+
+		result := bcs.GetBlockchain().CallContract(q.Get("caller"), q.Get("address"),
+			input, block.MAX_GAS_LIMIT)
+		errStr := ""
+		if result.Err != nil {
+			errStr = result.Err.Error()
+		}
+		m, _ := json.Marshal(struct {
+			GasUsed uint64    `json:"gas_used"`
+			Return  string    `json:"return"`
+			Logs    []*vm.Log `json:"logs"`
+			Error   string    `json:"error,omitempty"`
+		}{
+			GasUsed: result.GasUsed,
+			Return:  hex.EncodeToString(result.Return),
+			Logs:    result.Logs,
+			Error:   errStr,
+		})
+
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
 func (bcs *BlockchainServer) Consensus(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodPut:
 		bc := bcs.GetBlockchain()
-		replaced := bc.ResolveConflicts()
+		var ok bool
+		if bcs.p2p != nil {
+			// p2p 모드에서는 HTTP 이웃이 없으므로 peer에게 header를 요청하고 결과는 기다리지 않는다
+			ok = bcs.p2p.Sync() > 0
+		} else {
+			ok = bc.ResolveConflicts()
+		}
 
 		w.Header().Add("Conetent-Type", "application/json")
-		if replaced {
+		if ok {
 			io.WriteString(w, string(utils.JsonStatus("success")))
 		} else {
 			io.WriteString(w, string(utils.JsonStatus("failed")))
@@ -209,14 +913,146 @@ func (bcs *BlockchainServer) Consensus(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// 실행 중인 설정과 각 값을 정한 곳(default, file, env, flag)
+func (bcs *BlockchainServer) AdminConfig(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		m, _ := json.Marshal(bcs.config.Effective())
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// handshake를 마친 TCP peer 목록
+func (bcs *BlockchainServer) P2PPeers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		peers := []*p2p.PeerInfo{}
+		if bcs.p2p != nil {
+			peers = bcs.p2p.Peers()
+		}
+		m, _ := json.Marshal(peers)
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// 알고 있는 peer 주소 목록(GET), 추가(POST {"addr"}), 삭제(DELETE ?addr=)
+func (bcs *BlockchainServer) AdminPeers(w http.ResponseWriter, r *http.Request) {
+	if bcs.p2p == nil {
+		log.Println("ERROR: p2p is disabled")
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		m, _ := json.Marshal(bcs.p2p.KnownPeers())
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m[:]))
+	case http.MethodPost:
+		var req struct {
+			Addr *string `json:"addr"`
+		}
+		decoder := json.NewDecoder(r.Body)
+		if err := decoder.Decode(&req); err != nil || req.Addr == nil {
+			log.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		if err := bcs.p2p.AddPeer(*req.Addr); err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, string(utils.JsonStatus("success")))
+	case http.MethodDelete:
+		if !bcs.p2p.RemovePeer(r.URL.Query().Get("addr")) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, string(utils.JsonStatus("success")))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// p2p를 쓰면 주소 범위를 훑어 HTTP 이웃을 찾지 않고 seed와 peer 주소 교환으로 peer를 찾는다
+// p2p 모드에서는 HTTP 이웃 대신 연결된 peer 수
+func (bcs *BlockchainServer) peerCount() int {
+	if bcs.p2p != nil {
+		return bcs.p2p.PeerCount()
+	}
+	return bcs.GetBlockchain().PeerCount()
+}
+
+func (bcs *BlockchainServer) startP2P(bc *block.Blockchain) {
+	book, err := p2p.NewAddrBook(bcs.config.PeersFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	bcs.p2p = p2p.NewServer(bc, bcs.config.ChainId)
+	bcs.p2p.SetAddrBook(book)
+	if err := bcs.p2p.Listen("0.0.0.0:" + strconv.Itoa(int(bcs.config.P2PPort))); err != nil {
+		log.Fatal(err)
+	}
+	// chain은 연결된 peer에게서 받으므로 HTTP 이웃을 훑지 않는다
+	bc.UseNeighbors(nil)
+	bcs.p2p.Discover(bcs.config.SeedList())
+}
+
 func (bcs *BlockchainServer) Run() {
-	bcs.GetBlockchain().Run()
+	bc := bcs.GetBlockchain()
+	webhooks, err := webhook.NewManager(bc, bcs.config.Webhooks)
+	if err != nil {
+		log.Fatal(err)
+	}
+	bcs.webhooks = webhooks
+	bcs.webhooks.Run()
+	if bcs.config.P2PPort != 0 {
+		bcs.startP2P(bc)
+	}
+	bc.Run()
 
 	http.HandleFunc("/", bcs.GetChain)
 	http.HandleFunc("/transactions", bcs.Transactions)
 	http.HandleFunc("/mine", bcs.Mine)
 	http.HandleFunc("/mine/start", bcs.StartMining)
+	http.HandleFunc("/mine/stop", bcs.StopMining)
+	http.HandleFunc("/events", bcs.Events)
+	http.HandleFunc("/webhooks", bcs.Webhooks)
+	http.HandleFunc("/metrics", metrics.Handler(block.Metrics, nodeMetrics(bc, bcs.peerCount)))
+	http.HandleFunc("/admin/config", bcs.AdminConfig)
+	http.HandleFunc("/p2p/peers", bcs.P2PPeers)
+	http.HandleFunc("/admin/peers", bcs.AdminPeers)
 	http.HandleFunc("/amount", bcs.Amount)
+	http.HandleFunc("/tokens", bcs.Tokens)
+	http.HandleFunc("/nfts", bcs.NFTs)
 	http.HandleFunc("/consensus", bcs.Consensus)
+	http.HandleFunc("/validators", bcs.Validators)
+	http.HandleFunc("/status", bcs.Status)
+	http.HandleFunc("/snapshot", bcs.Snapshot)
+	http.HandleFunc("/headers", bcs.Headers)
+	http.HandleFunc("/blocks", bcs.Blocks)
+	http.HandleFunc("/block", bcs.Block)
+	http.HandleFunc("/headers/latest", bcs.LatestHeaders)
+	http.HandleFunc("/summary", bcs.Summary)
+	http.HandleFunc("/search", bcs.Search)
+	http.HandleFunc("/addresses/", bcs.Addresses)
+	http.HandleFunc("/filter", bcs.Filter)
+	http.HandleFunc("/filter/blocks", bcs.FilteredBlocks)
+	http.HandleFunc("/filter/transactions", bcs.FilteredTransactions)
+
+	http.HandleFunc("/contracts", bcs.Contracts)
+	http.HandleFunc("/contracts/call", bcs.ContractCall)
 	log.Fatal(http.ListenAndServe("0.0.0.0:"+strconv.Itoa(int(bcs.Port())), nil))
 }
@@ -1,8 +1,10 @@
 package main
 
 import (
-	"flag"
 	"log"
+	"os"
+
+	"github.com/sw90lee/blockchain_study/config"
 )
 
 func init() {
@@ -10,8 +12,11 @@ func init() {
 }
 
 func main() {
-	port := flag.Uint("port", 5000, "TCP Port Number for Blockchain Server")
-	flag.Parse()
-	app := NewBlockchainServer(uint16(*port))
+	cfg, err := config.LoadNode(os.Args[1:])
+	if err != nil {
+		log.Fatalf("ERROR: invalid config: %v", err)
+	}
+	log.Printf("action=config, %s", cfg)
+	app := NewBlockchainServer(cfg)
 	app.Run()
 }
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/sw90lee/blockchain_study/block"
+	"github.com/sw90lee/blockchain_study/metrics"
+)
+
+// /metrics를 요청할 때 Blockchain에서 읽는 값
+func nodeMetrics(bc *block.Blockchain, peerCount func() int) *metrics.Registry {
+	r := metrics.NewRegistry()
+	metrics.NewGaugeFunc(r, "blockchain_height", "Height of the last block", func() float64 {
+		return float64(len(bc.Chain()) - 1)
+	})
+	metrics.NewGaugeFunc(r, "blockchain_mempool_transactions",
+		"Pending and time-locked transactions", func() float64 {
+			return float64(bc.MempoolSize())
+		})
+	metrics.NewGaugeFunc(r, "blockchain_mempool_bytes",
+		"JSON size of pending and time-locked transactions", func() float64 {
+			return float64(bc.MempoolBytes())
+		})
+	metrics.NewGaugeFunc(r, "blockchain_peers", "Known neighbor nodes or connected p2p peers", func() float64 {
+		return float64(peerCount())
+	})
+	return r
+}
@@ -0,0 +1,120 @@
+// Package bloom 은 light client가 node에 올려두는 Bloom filter를 구현한다.
+//
+// client는 자기 주소 외에 일부러 더 많은 항목이 걸리도록 오탐률을 정해서
+// node가 어떤 주소가 client의 것인지 정확히 알 수 없게 한다.
+package bloom
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math"
+)
+
+const (
+	MAX_FILTER_SIZE    = 36000
+	MAX_HASH_FUNCTIONS = 50
+
+	// hash 함수마다 seed를 벌리는 값
+	SEED_MULTIPLIER = 0xFBA4C795
+)
+
+var ErrInvalidFilter = errors.New("invalid bloom filter")
+
+type Filter struct {
+	bits   []byte
+	hashes uint32
+	tweak  uint32
+}
+
+// elements개를 넣었을 때 오탐률이 fpRate가 되는 크기로 만든다
+// tweak은 client마다 다르게 주어 같은 주소라도 다른 bit가 켜지게 한다
+func New(elements int, fpRate float64, tweak uint32) *Filter {
+	if elements < 1 {
+		elements = 1
+	}
+	size := int(-1 / (math.Ln2 * math.Ln2) * float64(elements) * math.Log(fpRate) / 8)
+	if size < 1 {
+		size = 1
+	}
+	if size > MAX_FILTER_SIZE {
+		size = MAX_FILTER_SIZE
+	}
+	hashes := int(float64(size*8) / float64(elements) * math.Ln2)
+	if hashes < 1 {
+		hashes = 1
+	}
+	if hashes > MAX_HASH_FUNCTIONS {
+		hashes = MAX_HASH_FUNCTIONS
+	}
+	return &Filter{bits: make([]byte, size), hashes: uint32(hashes), tweak: tweak}
+}
+
+func (f *Filter) index(i uint32, data []byte) uint32 {
+	seed := make([]byte, 4)
+	binary.BigEndian.PutUint32(seed, i*SEED_MULTIPLIER+f.tweak)
+	h := sha256.Sum256(append(seed, data...))
+	return binary.BigEndian.Uint32(h[:4]) % uint32(len(f.bits)*8)
+}
+
+func (f *Filter) Add(data []byte) {
+	for i := uint32(0); i < f.hashes; i++ {
+		n := f.index(i, data)
+		f.bits[n/8] |= 1 << (n % 8)
+	}
+}
+
+// false면 확실히 없고, true면 있거나 오탐
+func (f *Filter) Test(data []byte) bool {
+	for i := uint32(0); i < f.hashes; i++ {
+		n := f.index(i, data)
+		if f.bits[n/8]&(1<<(n%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *Filter) Valid() bool {
+	return len(f.bits) > 0 && len(f.bits) <= MAX_FILTER_SIZE &&
+		f.hashes > 0 && f.hashes <= MAX_HASH_FUNCTIONS
+}
+
+func (f *Filter) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Bits   string `json:"bits"`
+		Hashes uint32 `json:"hashes"`
+		Tweak  uint32 `json:"tweak"`
+	}{
+		Bits:   hex.EncodeToString(f.bits),
+		Hashes: f.hashes,
+		Tweak:  f.tweak,
+	})
+}
+
+func (f *Filter) UnmarshalJSON(data []byte) error {
+	var bits string
+	v := &struct {
+		Bits   *string `json:"bits"`
+		Hashes *uint32 `json:"hashes"`
+		Tweak  *uint32 `json:"tweak"`
+	}{
+		Bits:   &bits,
+		Hashes: &f.hashes,
+		Tweak:  &f.tweak,
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	b, err := hex.DecodeString(bits)
+	if err != nil {
+		return err
+	}
+	f.bits = b
+	if !f.Valid() {
+		return ErrInvalidFilter
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package bloom
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestFalsePositiveRate(t *testing.T) {
+	for _, fpRate := range []float64{0.1, 0.01} {
+		f := New(100, fpRate, 7)
+		for i := 0; i < 100; i++ {
+			f.Add([]byte(fmt.Sprintf("in-%d", i)))
+		}
+		for i := 0; i < 100; i++ {
+			if !f.Test([]byte(fmt.Sprintf("in-%d", i))) {
+				t.Fatalf("added element %d not found", i)
+			}
+		}
+		// 넣지 않은 값 중 걸리는 비율이 목표의 두 배를 넘지 않는다
+		matched := 0
+		for i := 0; i < 10000; i++ {
+			if f.Test([]byte(fmt.Sprintf("out-%d", i))) {
+				matched++
+			}
+		}
+		if rate := float64(matched) / 10000; rate > 2*fpRate {
+			t.Errorf("fpRate %v: measured %v", fpRate, rate)
+		}
+	}
+}
+
+func TestSizeLimits(t *testing.T) {
+	if f := New(1000000, 0.0001, 0); len(f.bits) != MAX_FILTER_SIZE || f.hashes > MAX_HASH_FUNCTIONS || !f.Valid() {
+		t.Fatalf("unexpected filter %d bytes %d hashes", len(f.bits), f.hashes)
+	}
+	if f := New(0, 0.9, 0); len(f.bits) < 1 || f.hashes < 1 || !f.Valid() {
+		t.Fatalf("unexpected filter %d bytes %d hashes", len(f.bits), f.hashes)
+	}
+	// 같은 값이라도 tweak이 다르면 다른 bit가 켜진다
+	a, b := New(10, 0.01, 1), New(10, 0.01, 2)
+	a.Add([]byte("alice"))
+	b.Add([]byte("alice"))
+	if string(a.bits) == string(b.bits) {
+		t.Fatal("tweak ignored")
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	f := New(10, 0.01, 3)
+	f.Add([]byte("alice"))
+	m, _ := json.Marshal(f)
+	var decoded Filter
+	if err := json.Unmarshal(m, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Test([]byte("alice")) || decoded.tweak != 3 {
+		t.Fatal("round trip changed filter")
+	}
+
+	for _, data := range []string{
+		`{"bits": "", "hashes": 1, "tweak": 0}`,
+		`{"bits": "zz", "hashes": 1, "tweak": 0}`,
+		`{"bits": "ff", "hashes": 0, "tweak": 0}`,
+		fmt.Sprintf(`{"bits": "ff", "hashes": %d, "tweak": 0}`, MAX_HASH_FUNCTIONS+1),
+		fmt.Sprintf(`{"bits": "%0*d", "hashes": 1, "tweak": 0}`, 2*(MAX_FILTER_SIZE+1), 0),
+		`{"bits": "ff", "hashes": "1"}`,
+	} {
+		var f Filter
+		if err := json.Unmarshal([]byte(data), &f); err == nil {
+			t.Errorf("%.60s accepted", data)
+		}
+	}
+}
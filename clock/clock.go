@@ -0,0 +1,27 @@
+// Package clock 은 Blockchain이 쓰는 시각과 timer를 바꿔 끼울 수 있게 한다.
+// 실행할 때는 System을, test와 시뮬레이터에서는 손으로 돌리는 Fake를 쓴다.
+package clock
+
+import "time"
+
+type Clock interface {
+	Now() time.Time
+	// d가 지나면 f를 다른 goroutine에서 부른다 (Fake는 Advance 안에서 부른다)
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+type Timer interface {
+	Stop() bool
+}
+
+type systemClock struct{}
+
+var System Clock = systemClock{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
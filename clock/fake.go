@@ -0,0 +1,110 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Advance를 부를 때만 흐르는 시계
+type Fake struct {
+	now    time.Time
+	timers []*fakeTimer
+	mux    sync.Mutex
+}
+
+type fakeTimer struct {
+	clock   *Fake
+	when    time.Time
+	f       func()
+	stopped bool
+}
+
+func NewFake(start time.Time) *Fake {
+	return &Fake{now: start}
+}
+
+func (c *Fake) Now() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.now
+}
+
+func (c *Fake) AfterFunc(d time.Duration, f func()) Timer {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	t := &fakeTimer{clock: c, when: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// 시각을 d만큼 옮기면서 그 사이에 끝나는 timer를 시각 순서대로 부른다
+// timer가 부른 함수가 다시 AfterFunc를 걸면 그것도 범위 안이면 부른다
+// timer 안에서 다시 Advance를 불러 시각이 end를 넘었으면 되돌리지 않는다
+func (c *Fake) Advance(d time.Duration) {
+	c.mux.Lock()
+	end := c.now.Add(d)
+	c.mux.Unlock()
+	for {
+		c.mux.Lock()
+		t := c.next(end)
+		if t == nil {
+			if c.now.Before(end) {
+				c.now = end
+			}
+			c.mux.Unlock()
+			return
+		}
+		if c.now.Before(t.when) {
+			c.now = t.when
+		}
+		c.mux.Unlock()
+		t.f()
+	}
+}
+
+// end까지 끝나는 가장 이른 timer를 목록에서 꺼낸다, 같은 시각이면 먼저 건 것
+func (c *Fake) next(end time.Time) *fakeTimer {
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].when.Before(c.timers[j].when)
+	})
+	for len(c.timers) > 0 {
+		t := c.timers[0]
+		if t.when.After(end) {
+			return nil
+		}
+		c.timers = c.timers[1:]
+		if !t.stopped {
+			return t
+		}
+	}
+	return nil
+}
+
+// 아직 끝나지 않은 timer 수
+func (c *Fake) Pending() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	n := 0
+	for _, t := range c.timers {
+		if !t.stopped {
+			n++
+		}
+	}
+	return n
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mux.Lock()
+	defer t.clock.mux.Unlock()
+	if t.stopped {
+		return false
+	}
+	for _, pending := range t.clock.timers {
+		if pending == t {
+			t.stopped = true
+			return true
+		}
+	}
+	return false
+}
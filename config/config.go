@@ -0,0 +1,198 @@
+// Package config 는 두 server가 함께 쓰는 실행 설정을 읽는다.
+//
+// 값은 기본값, 설정 파일(JSON), 환경 변수, flag 순서로 덮어쓴다.
+// 설정 파일은 -config flag나 <PREFIX>CONFIG 환경 변수로 정하고,
+// 각 항목의 환경 변수 이름은 <PREFIX>와 항목 이름을 대문자로 붙인 것이다.
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	SOURCE_DEFAULT = "default"
+	SOURCE_FILE    = "file"
+	SOURCE_ENV     = "env"
+	SOURCE_FLAG    = "flag"
+)
+
+// 설정 항목 하나, ptr은 *string, *int, *bool, *uint16, *uint8 중 하나
+type field struct {
+	name  string
+	usage string
+	ptr   interface{}
+}
+
+func (f *field) Set(s string) error {
+	switch p := f.ptr.(type) {
+	case *string:
+		*p = s
+	case *bool:
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		*p = v
+	case *int:
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		*p = v
+	case *uint16:
+		v, err := strconv.ParseUint(s, 10, 16)
+		if err != nil {
+			return err
+		}
+		*p = uint16(v)
+	case *uint8:
+		v, err := strconv.ParseUint(s, 10, 8)
+		if err != nil {
+			return err
+		}
+		*p = uint8(v)
+	default:
+		return fmt.Errorf("unsupported type %T", f.ptr)
+	}
+	return nil
+}
+
+func (f *field) String() string {
+	if f == nil || f.ptr == nil {
+		return ""
+	}
+	switch p := f.ptr.(type) {
+	case *string:
+		return *p
+	case *bool:
+		return strconv.FormatBool(*p)
+	case *int:
+		return strconv.Itoa(*p)
+	case *uint16:
+		return strconv.FormatUint(uint64(*p), 10)
+	case *uint8:
+		return strconv.FormatUint(uint64(*p), 10)
+	}
+	return ""
+}
+
+// -fastsync처럼 값 없이 쓸 수 있게 한다
+func (f *field) IsBoolFlag() bool {
+	_, ok := f.ptr.(*bool)
+	return ok
+}
+
+// 설정 struct 하나를 채우는 loader
+// target은 json tag가 field 이름과 같은 struct의 pointer
+type loader struct {
+	prefix  string
+	target  interface{}
+	fields  []*field
+	file    string
+	sources map[string]string
+}
+
+func newLoader(prefix string, target interface{}) *loader {
+	return &loader{prefix: prefix, target: target, sources: make(map[string]string)}
+}
+
+func (l *loader) add(name string, ptr interface{}, usage string) {
+	l.fields = append(l.fields, &field{name: name, usage: usage, ptr: ptr})
+	l.sources[name] = SOURCE_DEFAULT
+}
+
+func (l *loader) envName(name string) string {
+	return l.prefix + strings.ToUpper(name)
+}
+
+func (l *loader) load(name string, args []string) error {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv(l.envName("config")), "JSON config file")
+	for _, f := range l.fields {
+		fs.Var(f, f.name, fmt.Sprintf("%s (env %s)", f.usage, l.envName(f.name)))
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// 파일과 환경 변수를 읽은 뒤 다시 덮어쓰도록 flag 값을 따로 둔다
+	flags := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name != "config" {
+			flags[f.Name] = f.Value.String()
+		}
+	})
+
+	l.file = *configFile
+	if l.file != "" {
+		if err := l.loadFile(); err != nil {
+			return fmt.Errorf("config file %s: %v", l.file, err)
+		}
+	}
+	for _, f := range l.fields {
+		if v, ok := os.LookupEnv(l.envName(f.name)); ok {
+			if err := f.Set(v); err != nil {
+				return fmt.Errorf("%s: %v", l.envName(f.name), err)
+			}
+			l.sources[f.name] = SOURCE_ENV
+		}
+	}
+	for _, f := range l.fields {
+		if v, ok := flags[f.name]; ok {
+			f.Set(v)
+			l.sources[f.name] = SOURCE_FLAG
+		}
+	}
+	return nil
+}
+
+// 모르는 항목은 오타일 가능성이 높으므로 에러로 본다
+func (l *loader) loadFile() error {
+	data, err := ioutil.ReadFile(l.file)
+	if err != nil {
+		return err
+	}
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+	for key := range keys {
+		if _, ok := l.sources[key]; !ok {
+			return fmt.Errorf("unknown setting %q", key)
+		}
+		l.sources[key] = SOURCE_FILE
+	}
+	return json.Unmarshal(data, l.target)
+}
+
+// /admin/config 응답
+type EffectiveResponse struct {
+	File    string            `json:"file,omitempty"`
+	Config  interface{}       `json:"config"`
+	Sources map[string]string `json:"sources"`
+}
+
+func (l *loader) effective() *EffectiveResponse {
+	sources := make(map[string]string, len(l.sources))
+	for name, source := range l.sources {
+		sources[name] = source
+	}
+	return &EffectiveResponse{File: l.file, Config: l.target, Sources: sources}
+}
+
+// 항목 이름 순서대로 "name=value" 목록, 시작할 때 log로 남긴다
+func (l *loader) summary() string {
+	pairs := make([]string, 0, len(l.fields))
+	for _, f := range l.fields {
+		pairs = append(pairs, fmt.Sprintf("%s=%s(%s)", f.name, f.String(), l.sources[f.name]))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNodePrecedence(t *testing.T) {
+	path := writeFile(t, `{"port": 5001, "mining_difficulty": 2, "mining_timer_sec": 5}`)
+	t.Setenv("BLOCKCHAIN_MINING_DIFFICULTY", "4")
+	t.Setenv("BLOCKCHAIN_PORT", "5002")
+
+	n, err := LoadNode([]string{"-config", path, "-port", "5003", "-fastsync"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Port != 5003 || n.MiningDifficulty != 4 || n.MiningTimerSec != 5 || !n.FastSync {
+		t.Fatalf("unexpected config %+v", n)
+	}
+	sources := n.Effective().Sources
+	for name, source := range map[string]string{
+		"port":              SOURCE_FLAG,
+		"mining_difficulty": SOURCE_ENV,
+		"mining_timer_sec":  SOURCE_FILE,
+		"consensus":         SOURCE_DEFAULT,
+	} {
+		if sources[name] != source {
+			t.Errorf("%s from %s, want %s", name, sources[name], source)
+		}
+	}
+	if p := n.Params(); p.MiningDifficulty != 4 || p.MiningTimerSec != 5 {
+		t.Fatalf("unexpected params %+v", p)
+	}
+}
+
+func TestNodeValidation(t *testing.T) {
+	for _, args := range [][]string{
+		{"-consensus", "poa"},
+		{"-mining_difficulty", "0"},
+		{"-mining_timer_sec", "0"},
+		{"-max_memo_size", "-1"},
+		{"-max_reorg_depth", "0"},
+		{"-checkpoints", "10"},
+		{"-checkpoints", "-1:" + strings.Repeat("ab", 32)},
+		{"-checkpoints", "10:abcd"},
+		{"-checkpoints", "10:" + strings.Repeat("ab", 32) + ",10:" + strings.Repeat("cd", 32)},
+		{"-port_range_start", "6000", "-port_range_end", "5000"},
+		{"-port_range_end", "65535"},
+		{"-ip_range_end", "255"},
+		{"-port_range_start", "5000", "-port_range_end", "5100", "-ip_range_end", "10"},
+		{"-config", writeFile(t, `{"mining_dificulty": 2}`)},
+		{"-p2p_port", "5000"},
+		{"-chain_id", ""},
+		{"-seeds", "127.0.0.1:6000,localhost"},
+		{"-seeds", "127.0.0.1:0"},
+	} {
+		if _, err := LoadNode(args); err == nil {
+			t.Errorf("%v accepted", args)
+		}
+	}
+}
+
+func TestNodeCheckpoints(t *testing.T) {
+	hash := strings.Repeat("AB", 32)
+	n, err := LoadNode([]string{"-checkpoints", "10:" + hash + ", 20:" + strings.Repeat("cd", 32), "-max_reorg_depth", "5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := n.Params()
+	if p.MaxReorgDepth != 5 || len(p.Checkpoints) != 2 || p.Checkpoints[10] != strings.ToLower(hash) {
+		t.Fatalf("unexpected params %+v", p)
+	}
+}
+
+func TestWalletGateway(t *testing.T) {
+	t.Setenv("WALLET_GATEWAY", "ftp://127.0.0.1:5000")
+	if _, err := LoadWallet(nil); err == nil {
+		t.Fatal("invalid gateway accepted")
+	}
+	wc, err := LoadWallet([]string{"-gateway", "http://127.0.0.1:5001"})
+	if err != nil || wc.Gateway != "http://127.0.0.1:5001" {
+		t.Fatalf("unexpected config %+v %v", wc, err)
+	}
+}
@@ -0,0 +1,229 @@
+package config
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sw90lee/blockchain_study/block"
+	"github.com/sw90lee/blockchain_study/p2p"
+)
+
+const (
+	NODE_ENV_PREFIX  = "BLOCKCHAIN_"
+	DEFAULT_CHAIN_ID = "blockchain-study"
+
+	// 16^difficulty번 정도 hash를 계산하므로 이보다 크면 Block이 거의 나오지 않는다
+	MAX_MINING_DIFFICULTY = 6
+	// 이웃을 찾을 때 범위의 host:port마다 연결을 시도한다
+	MAX_NEIGHBOR_CANDIDATES = 256
+)
+
+// blockchain_server 설정
+type Node struct {
+	Port      uint16 `json:"port"`
+	Consensus string `json:"consensus"`
+	Prune     int    `json:"prune"`
+	FastSync  bool   `json:"fastsync"`
+	// 비어 있으면 webhook 구독을 저장하지 않는다
+	Webhooks string `json:"webhooks"`
+
+	// 0이면 TCP p2p protocol을 쓰지 않는다
+	P2PPort uint16 `json:"p2p_port"`
+	// handshake에서 다른 값을 보낸 peer는 받지 않는다
+	ChainId string `json:"chain_id"`
+	// 처음 연결할 host:port 목록, 쉼표로 구분
+	Seeds string `json:"seeds"`
+	// 알게 된 peer 주소를 보관하는 파일, 비어 있으면 저장하지 않는다
+	PeersFile string `json:"peers_file"`
+
+	MiningDifficulty int    `json:"mining_difficulty"`
+	MiningTimerSec   int    `json:"mining_timer_sec"`
+	PortRangeStart   uint16 `json:"port_range_start"`
+	PortRangeEnd     uint16 `json:"port_range_end"`
+	IpRangeStart     uint8  `json:"ip_range_start"`
+	IpRangeEnd       uint8  `json:"ip_range_end"`
+	NeighborSyncSec  int    `json:"neighbor_sync_sec"`
+	MaxMemoSize      int    `json:"max_memo_size"`
+	MaxReorgDepth    int    `json:"max_reorg_depth"`
+	// height:hash 목록, 쉼표로 구분
+	Checkpoints string `json:"checkpoints"`
+
+	loader *loader
+}
+
+func DefaultNode() *Node {
+	p := block.DefaultParams()
+	return &Node{
+		Port:             5000,
+		Consensus:        block.CONSENSUS_MODE,
+		Webhooks:         "webhooks.json",
+		ChainId:          DEFAULT_CHAIN_ID,
+		PeersFile:        "peers.json",
+		MiningDifficulty: p.MiningDifficulty,
+		MiningTimerSec:   p.MiningTimerSec,
+		PortRangeStart:   p.PortRangeStart,
+		PortRangeEnd:     p.PortRangeEnd,
+		IpRangeStart:     p.IpRangeStart,
+		IpRangeEnd:       p.IpRangeEnd,
+		NeighborSyncSec:  p.NeighborSyncSec,
+		MaxMemoSize:      p.MaxMemoSize,
+		MaxReorgDepth:    p.MaxReorgDepth,
+	}
+}
+
+// args는 program 이름을 뺀 명령줄 인자
+func LoadNode(args []string) (*Node, error) {
+	n := DefaultNode()
+	l := newLoader(NODE_ENV_PREFIX, n)
+	l.add("port", &n.Port, "TCP Port Number for Blockchain Server")
+	l.add("consensus", &n.Consensus, "Consensus mode (pow or pos)")
+	l.add("prune", &n.Prune, "Keep block bodies only for the last N blocks (0 keeps all)")
+	l.add("fastsync", &n.FastSync, "Start from a neighbor's state snapshot instead of replaying the whole chain")
+	l.add("webhooks", &n.Webhooks, "File to keep webhook subscriptions in (empty keeps them in memory)")
+	l.add("p2p_port", &n.P2PPort, "TCP Port Number for the peer-to-peer protocol (0 disables it)")
+	l.add("chain_id", &n.ChainId, "Chain id peers must present in the handshake")
+	l.add("seeds", &n.Seeds, "Comma separated host:port list of peers to discover the network from")
+	l.add("peers_file", &n.PeersFile, "File to keep discovered peer addresses in (empty keeps them in memory)")
+	l.add("mining_difficulty", &n.MiningDifficulty, "Leading zero hex digits required in a block hash")
+	l.add("mining_timer_sec", &n.MiningTimerSec, "Seconds between automatic mining rounds")
+	l.add("port_range_start", &n.PortRangeStart, "First port scanned for neighbors")
+	l.add("port_range_end", &n.PortRangeEnd, "Last port scanned for neighbors")
+	l.add("ip_range_start", &n.IpRangeStart, "First offset added to the last IP octet when scanning for neighbors")
+	l.add("ip_range_end", &n.IpRangeEnd, "Last offset added to the last IP octet when scanning for neighbors")
+	l.add("neighbor_sync_sec", &n.NeighborSyncSec, "Seconds between neighbor scans")
+	l.add("max_memo_size", &n.MaxMemoSize, "Maximum bytes in a transaction memo, must match the rest of the network")
+	l.add("max_reorg_depth", &n.MaxReorgDepth, "Maximum blocks rolled back when switching to a neighbor's chain")
+	l.add("checkpoints", &n.Checkpoints, "Comma separated height:hash list of blocks every accepted chain must contain")
+	if err := l.load("blockchain_server", args); err != nil {
+		return nil, err
+	}
+	n.loader = l
+	if err := n.Validate(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (n *Node) Validate() error {
+	if n.Port == 0 {
+		return errors.New("port must be set")
+	}
+	if n.Consensus != block.CONSENSUS_PROOF_OF_WORK && n.Consensus != block.CONSENSUS_PROOF_OF_STAKE {
+		return fmt.Errorf("consensus must be %s or %s", block.CONSENSUS_PROOF_OF_WORK, block.CONSENSUS_PROOF_OF_STAKE)
+	}
+	if n.Prune < 0 {
+		return errors.New("prune must not be negative")
+	}
+	if n.P2PPort != 0 && n.P2PPort == n.Port {
+		return errors.New("p2p_port must differ from port")
+	}
+	if n.ChainId == "" {
+		return errors.New("chain_id must be set")
+	}
+	for _, seed := range n.SeedList() {
+		if err := p2p.ValidAddress(seed); err != nil {
+			return fmt.Errorf("seeds: %v", err)
+		}
+	}
+	if n.MiningDifficulty < 1 || n.MiningDifficulty > MAX_MINING_DIFFICULTY {
+		return fmt.Errorf("mining_difficulty must be between 1 and %d", MAX_MINING_DIFFICULTY)
+	}
+	if n.MiningTimerSec < 1 {
+		return errors.New("mining_timer_sec must be positive")
+	}
+	if n.NeighborSyncSec < 1 {
+		return errors.New("neighbor_sync_sec must be positive")
+	}
+	if n.MaxMemoSize < 0 {
+		return errors.New("max_memo_size must not be negative")
+	}
+	if n.MaxReorgDepth < 1 {
+		return errors.New("max_reorg_depth must be positive")
+	}
+	if _, err := n.CheckpointMap(); err != nil {
+		return fmt.Errorf("checkpoints: %v", err)
+	}
+	// utils.FindNeighbors는 end를 포함해서 1씩 늘리므로 최댓값이면 끝나지 않는다
+	if n.PortRangeStart == 0 || n.PortRangeStart > n.PortRangeEnd || n.PortRangeEnd == 65535 {
+		return errors.New("port_range_start..port_range_end must be an increasing range within 1..65534")
+	}
+	if n.IpRangeStart > n.IpRangeEnd || n.IpRangeEnd == 255 {
+		return errors.New("ip_range_start..ip_range_end must be an increasing range within 0..254")
+	}
+	candidates := (int(n.PortRangeEnd) - int(n.PortRangeStart) + 1) * (int(n.IpRangeEnd) - int(n.IpRangeStart) + 1)
+	if candidates > MAX_NEIGHBOR_CANDIDATES {
+		return fmt.Errorf("neighbor ranges cover %d addresses, at most %d allowed", candidates, MAX_NEIGHBOR_CANDIDATES)
+	}
+	return nil
+}
+
+func (n *Node) Params() block.Params {
+	checkpoints, _ := n.CheckpointMap()
+	return block.Params{
+		MiningDifficulty: n.MiningDifficulty,
+		MiningTimerSec:   n.MiningTimerSec,
+		PortRangeStart:   n.PortRangeStart,
+		PortRangeEnd:     n.PortRangeEnd,
+		IpRangeStart:     n.IpRangeStart,
+		IpRangeEnd:       n.IpRangeEnd,
+		NeighborSyncSec:  n.NeighborSyncSec,
+		MaxMemoSize:      n.MaxMemoSize,
+		MaxReorgDepth:    n.MaxReorgDepth,
+		Checkpoints:      checkpoints,
+	}
+}
+
+// Checkpoints를 Block 높이 -> hash(hex)로 바꾼다
+func (n *Node) CheckpointMap() (map[int]string, error) {
+	checkpoints := make(map[int]string)
+	for _, entry := range strings.Split(n.Checkpoints, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%q is not height:hash", entry)
+		}
+		height, err := strconv.Atoi(parts[0])
+		if err != nil || height < 0 {
+			return nil, fmt.Errorf("invalid height in %q", entry)
+		}
+		hash := strings.ToLower(parts[1])
+		if b, err := hex.DecodeString(hash); err != nil || len(b) != 32 {
+			return nil, fmt.Errorf("invalid hash in %q", entry)
+		}
+		if _, ok := checkpoints[height]; ok {
+			return nil, fmt.Errorf("duplicate checkpoint at height %d", height)
+		}
+		checkpoints[height] = hash
+	}
+	return checkpoints, nil
+}
+
+func (n *Node) SeedList() []string {
+	seeds := []string{}
+	for _, seed := range strings.Split(n.Seeds, ",") {
+		if seed = strings.TrimSpace(seed); seed != "" {
+			seeds = append(seeds, seed)
+		}
+	}
+	return seeds
+}
+
+// 각 항목의 값과 어디서 정해졌는지
+func (n *Node) Effective() *EffectiveResponse {
+	if n.loader == nil {
+		return &EffectiveResponse{Config: n, Sources: map[string]string{}}
+	}
+	return n.loader.effective()
+}
+
+func (n *Node) String() string {
+	if n.loader == nil {
+		return fmt.Sprintf("%+v", *n)
+	}
+	return n.loader.summary()
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+const WALLET_ENV_PREFIX = "WALLET_"
+
+// wallet_server 설정
+type Wallet struct {
+	Port    uint16 `json:"port"`
+	Gateway string `json:"gateway"`
+
+	loader *loader
+}
+
+func DefaultWallet() *Wallet {
+	return &Wallet{
+		Port:    8080,
+		Gateway: "http://127.0.0.1:5000",
+	}
+}
+
+func LoadWallet(args []string) (*Wallet, error) {
+	wc := DefaultWallet()
+	l := newLoader(WALLET_ENV_PREFIX, wc)
+	l.add("port", &wc.Port, "TCP port Wallet Server")
+	l.add("gateway", &wc.Gateway, "BlockChain gateway")
+	if err := l.load("wallet_server", args); err != nil {
+		return nil, err
+	}
+	wc.loader = l
+	if err := wc.Validate(); err != nil {
+		return nil, err
+	}
+	return wc, nil
+}
+
+func (wc *Wallet) Validate() error {
+	if wc.Port == 0 {
+		return errors.New("port must be set")
+	}
+	u, err := url.Parse(wc.Gateway)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("gateway must be an http(s) URL, got %q", wc.Gateway)
+	}
+	return nil
+}
+
+func (wc *Wallet) Effective() *EffectiveResponse {
+	if wc.loader == nil {
+		return &EffectiveResponse{Config: wc, Sources: map[string]string{}}
+	}
+	return wc.loader.effective()
+}
+
+func (wc *Wallet) String() string {
+	if wc.loader == nil {
+		return fmt.Sprintf("port=%d, gateway=%s", wc.Port, wc.Gateway)
+	}
+	return wc.loader.summary()
+}
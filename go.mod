@@ -3,6 +3,6 @@ module github.com/sw90lee/blockchain_study
 go 1.17
 
 require (
-	github.com/btcsuite/btcutil v1.0.2 // indirect
-	golang.org/x/crypto v0.0.0-20220824171710-5757bc0c5503 // indirect
+	github.com/btcsuite/btcutil v1.0.2
+	golang.org/x/crypto v0.0.0-20220824171710-5757bc0c5503
 )
@@ -0,0 +1,277 @@
+// Package metrics 는 Prometheus text 형식(0.0.4)으로 내보내는 간단한 counter,
+// gauge, histogram을 구현한다. label은 metric을 만들 때 이름을 정하고
+// 값을 기록할 때 같은 순서로 label 값을 넘긴다.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 요청 시간(second)에 쓰는 기본 bucket
+var DEFAULT_BUCKETS = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type metric interface {
+	write(w io.Writer)
+}
+
+type Registry struct {
+	metrics []metric
+	mux     sync.Mutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(m metric) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+func (r *Registry) Export(w io.Writer) {
+	r.mux.Lock()
+	metrics := append([]metric{}, r.metrics...)
+	r.mux.Unlock()
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// 여러 registry를 순서대로 /metrics 응답으로 쓴다
+func Handler(registries ...*Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		for _, r := range registries {
+			r.Export(w)
+		}
+	}
+}
+
+// label 값마다 따로 쌓이는 값
+type vector struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+	values map[string]float64
+	keys   map[string][]string
+	mux    sync.Mutex
+}
+
+func newVector(name string, help string, kind string, labels []string) vector {
+	return vector{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		values: make(map[string]float64),
+		keys:   make(map[string][]string),
+	}
+}
+
+func (v *vector) key(labelValues []string) string {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values", v.name, len(v.labels)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	if _, ok := v.keys[key]; !ok {
+		v.keys[key] = append([]string{}, labelValues...)
+	}
+	return key
+}
+
+func (v *vector) write(w io.Writer) {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	writeHeader(w, v.name, v.help, v.kind)
+	if len(v.labels) == 0 && len(v.values) == 0 {
+		fmt.Fprintf(w, "%s 0\n", v.name)
+		return
+	}
+	for _, key := range sortedKeys(v.values) {
+		fmt.Fprintf(w, "%s%s %s\n", v.name, formatLabels(v.labels, v.keys[key], "", ""),
+			formatValue(v.values[key]))
+	}
+}
+
+// 늘어나기만 하는 값
+type Counter struct {
+	vector
+}
+
+func NewCounter(r *Registry, name string, help string, labels ...string) *Counter {
+	c := &Counter{newVector(name, help, "counter", labels)}
+	r.register(c)
+	return c
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.values[c.key(labelValues)] += delta
+}
+
+type Gauge struct {
+	vector
+}
+
+func NewGauge(r *Registry, name string, help string, labels ...string) *Gauge {
+	g := &Gauge{newVector(name, help, "gauge", labels)}
+	r.register(g)
+	return g
+}
+
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	g.values[g.key(labelValues)] = value
+}
+
+// 내보낼 때마다 fn을 불러 값을 읽는 gauge
+type GaugeFunc struct {
+	name string
+	help string
+	fn   func() float64
+}
+
+func NewGaugeFunc(r *Registry, name string, help string, fn func() float64) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, fn: fn}
+	r.register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.name, formatValue(g.fn()))
+}
+
+type histogramValue struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+type Histogram struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	values  map[string]*histogramValue
+	keys    map[string][]string
+	mux     sync.Mutex
+}
+
+// buckets가 nil이면 DEFAULT_BUCKETS
+func NewHistogram(r *Registry, name string, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DEFAULT_BUCKETS
+	}
+	h := &Histogram{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: buckets,
+		values:  make(map[string]*histogramValue),
+		keys:    make(map[string][]string),
+	}
+	r.register(h)
+	return h
+}
+
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	if len(labelValues) != len(h.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values", h.name, len(h.labels)))
+	}
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	key := strings.Join(labelValues, "\xff")
+	v, ok := h.values[key]
+	if !ok {
+		v = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = v
+		h.keys[key] = append([]string{}, labelValues...)
+	}
+	for i, upper := range h.buckets {
+		if value <= upper {
+			v.counts[i]++
+		}
+	}
+	v.count++
+	v.sum += value
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	writeHeader(w, h.name, h.help, "histogram")
+	keys := make([]string, 0, len(h.values))
+	for key := range h.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		v, labelValues := h.values[key], h.keys[key]
+		for i, upper := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name,
+				formatLabels(h.labels, labelValues, "le", formatValue(upper)), v.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, labelValues, "le", "+Inf"), v.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, labelValues, "", ""), formatValue(v.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, labelValues, "", ""), v.count)
+	}
+}
+
+func writeHeader(w io.Writer, name string, help string, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, strings.NewReplacer("\\", `\\`, "\n", `\n`).Replace(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+// extraName이 있으면 histogram의 le처럼 마지막에 붙인다
+func formatLabels(names []string, values []string, extraName string, extraValue string) string {
+	var pairs []string
+	for i, name := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, escapeLabel(values[i])))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extraName, extraValue))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer("\\", `\\`, "\"", `\"`, "\n", `\n`).Replace(s)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
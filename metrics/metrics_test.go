@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func export(r *Registry) string {
+	var buf bytes.Buffer
+	r.Export(&buf)
+	return buf.String()
+}
+
+func TestCounter(t *testing.T) {
+	r := NewRegistry()
+	c := NewCounter(r, "blocks_total", "Blocks by result.\nSecond line \\ backslash", "result")
+	plain := NewCounter(r, "requests_total", "Requests.")
+	if got := export(r); got != strings.Join([]string{
+		`# HELP blocks_total Blocks by result.\nSecond line \\ backslash`,
+		"# TYPE blocks_total counter",
+		"# HELP requests_total Requests.",
+		"# TYPE requests_total counter",
+		"requests_total 0",
+		""}, "\n") {
+		t.Fatalf("unexpected output before increments\n%s", got)
+	}
+
+	c.Inc("mined")
+	c.Inc("mined")
+	c.Add(2.5, "rejected")
+	plain.Inc()
+	want := strings.Join([]string{
+		`# HELP blocks_total Blocks by result.\nSecond line \\ backslash`,
+		"# TYPE blocks_total counter",
+		`blocks_total{result="mined"} 2`,
+		`blocks_total{result="rejected"} 2.5`,
+		"# HELP requests_total Requests.",
+		"# TYPE requests_total counter",
+		"requests_total 1",
+		""}, "\n")
+	if got := export(r); got != want {
+		t.Fatalf("expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	r := NewRegistry()
+	g := NewGauge(r, "peer_info", "Peers.", "address", "agent")
+	g.Set(1, "127.0.0.1:5000", "say \"hi\"\\\nbye")
+	want := `peer_info{address="127.0.0.1:5000",agent="say \"hi\"\\\nbye"} 1` + "\n"
+	if got := export(r); !strings.HasSuffix(got, want) {
+		t.Fatalf("expected suffix %q, got %q", want, got)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := NewHistogram(r, "request_seconds", "Request time.", []float64{0.1, 1}, "path")
+	for _, v := range []float64{0.05, 0.1, 0.5, 3} {
+		h.Observe(v, "/chain")
+	}
+	h.Observe(0.2, "/blocks")
+	want := strings.Join([]string{
+		"# HELP request_seconds Request time.",
+		"# TYPE request_seconds histogram",
+		`request_seconds_bucket{path="/blocks",le="0.1"} 0`,
+		`request_seconds_bucket{path="/blocks",le="1"} 1`,
+		`request_seconds_bucket{path="/blocks",le="+Inf"} 1`,
+		`request_seconds_sum{path="/blocks"} 0.2`,
+		`request_seconds_count{path="/blocks"} 1`,
+		`request_seconds_bucket{path="/chain",le="0.1"} 2`,
+		`request_seconds_bucket{path="/chain",le="1"} 3`,
+		`request_seconds_bucket{path="/chain",le="+Inf"} 4`,
+		`request_seconds_sum{path="/chain"} 3.65`,
+		`request_seconds_count{path="/chain"} 4`,
+		""}, "\n")
+	if got := export(r); got != want {
+		t.Fatalf("expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	a, b := NewRegistry(), NewRegistry()
+	NewGaugeFunc(a, "height", "Chain height.", func() float64 { return 7 })
+	NewCounter(b, "errors_total", "Errors.").Inc()
+
+	w := httptest.NewRecorder()
+	Handler(a, b)(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(w.Body)
+	if w.Header().Get("Content-Type") != "text/plain; version=0.0.4" {
+		t.Fatalf("content type %q", w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(string(body), "height 7\n# HELP errors_total Errors.\n# TYPE errors_total counter\nerrors_total 1\n") {
+		t.Fatalf("unexpected body\n%s", body)
+	}
+}
@@ -0,0 +1,218 @@
+package p2p
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	ADDR_SOURCE_SEED   = "seed"
+	ADDR_SOURCE_PEER   = "peer"
+	ADDR_SOURCE_MANUAL = "manual"
+
+	// 이보다 많으면 가장 오래 보지 못한 peer 주소부터 버린다
+	MAX_ADDRESSES = 1000
+	// 이만큼 연달아 연결하지 못하면 다른 peer에게 들은 주소는 버린다
+	MAX_FAILURES = 5
+)
+
+var ErrInvalidAddress = errors.New("invalid peer address")
+
+// 연결할 수 있는 peer 주소 하나
+type PeerAddress struct {
+	Addr string `json:"addr"`
+	// 마지막으로 handshake에 성공한 시각(unix), 한 번도 없으면 0
+	LastSeen int64  `json:"last_seen"`
+	Source   string `json:"source"`
+	Failures int    `json:"failures"`
+}
+
+// seed, 다른 peer, 운영자에게 받은 peer 주소를 파일에 보관한다
+type AddrBook struct {
+	path      string
+	addresses map[string]*PeerAddress
+	mux       sync.Mutex
+}
+
+// path가 비어 있으면 주소를 저장하지 않는다
+func NewAddrBook(path string) (*AddrBook, error) {
+	b := &AddrBook{path: path, addresses: make(map[string]*PeerAddress)}
+	if err := b.load(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// host:port 형식이고 port가 0이 아니어야 한다
+func ValidAddress(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+	}
+	if host == "" || port == "0" || port == "" {
+		return fmt.Errorf("%w: %s", ErrInvalidAddress, addr)
+	}
+	return nil
+}
+
+// 처음 보는 주소면 true, seed와 manual은 peer에게 들은 주소보다 우선한다
+func (b *AddrBook) Add(addr string, source string) (bool, error) {
+	if err := ValidAddress(addr); err != nil {
+		return false, err
+	}
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if a, ok := b.addresses[addr]; ok {
+		if source != ADDR_SOURCE_PEER && a.Source != source {
+			a.Source = source
+			b.saveOrLog()
+		}
+		return false, nil
+	}
+	b.addresses[addr] = &PeerAddress{Addr: addr, Source: source}
+	b.evict()
+	b.saveOrLog()
+	return true, nil
+}
+
+func (b *AddrBook) Remove(addr string) bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if _, ok := b.addresses[addr]; !ok {
+		return false
+	}
+	delete(b.addresses, addr)
+	b.saveOrLog()
+	return true
+}
+
+// handshake에 성공한 주소, 없으면 peer 출처로 넣는다
+func (b *AddrBook) Seen(addr string, t time.Time) {
+	if ValidAddress(addr) != nil {
+		return
+	}
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	a, ok := b.addresses[addr]
+	if !ok {
+		a = &PeerAddress{Addr: addr, Source: ADDR_SOURCE_PEER}
+		b.addresses[addr] = a
+		b.evict()
+	}
+	a.LastSeen = t.Unix()
+	a.Failures = 0
+	b.saveOrLog()
+}
+
+// 연결에 실패한 주소, 너무 많이 실패한 peer 출처 주소는 버린다
+func (b *AddrBook) Failed(addr string) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	a, ok := b.addresses[addr]
+	if !ok {
+		return
+	}
+	a.Failures += 1
+	if a.Source == ADDR_SOURCE_PEER && a.Failures >= MAX_FAILURES {
+		delete(b.addresses, addr)
+	}
+	b.saveOrLog()
+}
+
+// 최근에 본 순서, 같으면 주소 순서
+func (b *AddrBook) Addresses() []*PeerAddress {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	addresses := make([]*PeerAddress, 0, len(b.addresses))
+	for _, a := range b.addresses {
+		c := *a
+		addresses = append(addresses, &c)
+	}
+	sortAddresses(addresses)
+	return addresses
+}
+
+func sortAddresses(addresses []*PeerAddress) {
+	sort.Slice(addresses, func(i, j int) bool {
+		if addresses[i].LastSeen != addresses[j].LastSeen {
+			return addresses[i].LastSeen > addresses[j].LastSeen
+		}
+		return addresses[i].Addr < addresses[j].Addr
+	})
+}
+
+// seed와 manual은 남기고 가장 오래 보지 못한 peer 출처 주소를 버린다
+func (b *AddrBook) evict() {
+	for len(b.addresses) > MAX_ADDRESSES {
+		var oldest *PeerAddress
+		for _, a := range b.addresses {
+			if a.Source != ADDR_SOURCE_PEER {
+				continue
+			}
+			if oldest == nil || a.LastSeen < oldest.LastSeen ||
+				(a.LastSeen == oldest.LastSeen && a.Addr > oldest.Addr) {
+				oldest = a
+			}
+		}
+		if oldest == nil {
+			return
+		}
+		delete(b.addresses, oldest.Addr)
+	}
+}
+
+func (b *AddrBook) load() error {
+	if b.path == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(b.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var addresses []*PeerAddress
+	if err := json.Unmarshal(data, &addresses); err != nil {
+		return err
+	}
+	for _, a := range addresses {
+		if err := ValidAddress(a.Addr); err != nil {
+			return err
+		}
+		b.addresses[a.Addr] = a
+	}
+	return nil
+}
+
+// 중간에 멈춰도 파일이 깨지지 않도록 임시 파일에 쓰고 바꾼다
+func (b *AddrBook) save() error {
+	if b.path == "" {
+		return nil
+	}
+	addresses := make([]*PeerAddress, 0, len(b.addresses))
+	for _, a := range b.addresses {
+		addresses = append(addresses, a)
+	}
+	sortAddresses(addresses)
+	data, _ := json.MarshalIndent(addresses, "", "  ")
+	tmp := b.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, b.path)
+}
+
+func (b *AddrBook) saveOrLog() {
+	if err := b.save(); err != nil {
+		log.Printf("ERROR: %v", err)
+	}
+}
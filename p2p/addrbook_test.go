@@ -0,0 +1,56 @@
+package p2p
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAddrBookPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peers.json")
+	book, err := NewAddrBook(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := book.Add("seed.example:5001", ADDR_SOURCE_SEED); err != nil {
+		t.Fatal(err)
+	}
+	book.Seen("10.0.0.2:5001", time.Unix(100, 0))
+	if _, err := book.Add("localhost", ADDR_SOURCE_MANUAL); !errors.Is(err, ErrInvalidAddress) {
+		t.Fatalf("expected invalid address, got %v", err)
+	}
+
+	book, err = NewAddrBook(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addresses := book.Addresses()
+	if len(addresses) != 2 {
+		t.Fatalf("unexpected addresses %+v", addresses)
+	}
+	if a := addresses[0]; a.Addr != "10.0.0.2:5001" || a.LastSeen != 100 || a.Source != ADDR_SOURCE_PEER {
+		t.Fatalf("unexpected address %+v", a)
+	}
+	if a := addresses[1]; a.Addr != "seed.example:5001" || a.LastSeen != 0 || a.Source != ADDR_SOURCE_SEED {
+		t.Fatalf("unexpected address %+v", a)
+	}
+}
+
+// 다른 peer에게 들은 주소만 실패가 쌓이면 버린다
+func TestAddrBookFailures(t *testing.T) {
+	book, _ := NewAddrBook("")
+	book.Add("seed.example:5001", ADDR_SOURCE_SEED)
+	book.Add("10.0.0.2:5001", ADDR_SOURCE_PEER)
+	for i := 0; i < MAX_FAILURES; i++ {
+		book.Failed("seed.example:5001")
+		book.Failed("10.0.0.2:5001")
+	}
+	addresses := book.Addresses()
+	if len(addresses) != 1 || addresses[0].Addr != "seed.example:5001" || addresses[0].Failures != MAX_FAILURES {
+		t.Fatalf("unexpected addresses %+v", addresses)
+	}
+	if !book.Remove("seed.example:5001") || book.Remove("seed.example:5001") {
+		t.Fatal("remove")
+	}
+}
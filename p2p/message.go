@@ -0,0 +1,144 @@
+// Package p2p 는 노드끼리 TCP 연결 하나를 유지하면서 주고받는 protocol이다.
+//
+// 각 message는 4 bytes(big endian) 길이 다음에 {"type", "payload"} JSON이 오는
+// frame이다. 연결하면 양쪽이 먼저 version을 보내 protocol 번호, chain id,
+// 노드 id, 최고 높이, body를 버린 높이를 확인하고 verack을 주고받은 뒤에 다른 message를 보낸다.
+// handshake 뒤에는 getaddr/addr로 서로 아는 peer 주소를 나누고 AddrBook에 보관한다.
+package p2p
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/sw90lee/blockchain_study/block"
+)
+
+const (
+	PROTOCOL_VERSION = 1
+	MAX_MESSAGE_SIZE = 32 << 20
+
+	MSG_VERSION     = "version"
+	MSG_VERACK      = "verack"
+	MSG_PING        = "ping"
+	MSG_PONG        = "pong"
+	MSG_TRANSACTION = "tx"
+	MSG_BLOCK       = "block"
+	MSG_GET_HEADERS = "get_headers"
+	MSG_HEADERS     = "headers"
+	MSG_GET_BLOCKS  = "get_blocks"
+	MSG_BLOCKS      = "blocks"
+	MSG_GET_ADDR    = "getaddr"
+	MSG_ADDR        = "addr"
+
+	// addr message 하나에 담는 최대 주소 수
+	MAX_ADDR_COUNT = 100
+)
+
+var ErrMessageTooLarge = errors.New("message too large")
+
+type Message struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// 연결 직후 양쪽이 보내는 자기 소개
+type Version struct {
+	ProtocolVersion int    `json:"protocol_version"`
+	ChainId         string `json:"chain_id"`
+	NodeId          string `json:"node_id"`
+	BestHeight      int    `json:"best_height"`
+	// 이 높이보다 낮은 Block은 body가 없어 get_blocks에 답하지 않는다
+	PrunedHeight int `json:"pruned_height,omitempty"`
+	// 다른 노드가 이 노드에 연결할 때 쓰는 주소, 비어 있으면 받지 않는다
+	ListenAddr string `json:"listen_addr,omitempty"`
+}
+
+type Ping struct {
+	Nonce uint64 `json:"nonce"`
+}
+
+// 새로 만들어졌거나 받은 Block
+type BlockMessage struct {
+	Height int          `json:"height"`
+	Block  *block.Block `json:"block"`
+}
+
+// get_headers와 get_blocks, From 높이부터 끝까지
+type GetBlocks struct {
+	From int `json:"from"`
+}
+
+type Headers struct {
+	From    int            `json:"from"`
+	Headers []*block.Block `json:"headers"`
+	// 보낸 노드의 지금 pruned height, handshake 뒤에 정리했을 수도 있다
+	PrunedHeight int `json:"pruned_height,omitempty"`
+}
+
+type Blocks struct {
+	From   int            `json:"from"`
+	Blocks []*block.Block `json:"blocks"`
+}
+
+// 보낸 노드가 handshake에 성공한 적 있는 peer 주소, 최근에 본 순서
+type Addr struct {
+	Addresses []string `json:"addresses"`
+}
+
+func NewMessage(msgType string, payload interface{}) (*Message, error) {
+	m := &Message{Type: msgType}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		m.Payload = data
+	}
+	return m, nil
+}
+
+func (m *Message) Decode(v interface{}) error {
+	if len(m.Payload) == 0 {
+		return fmt.Errorf("%s: empty payload", m.Type)
+	}
+	return json.Unmarshal(m.Payload, v)
+}
+
+// 길이와 본문을 한 번에 쓴다
+func WriteMessage(w io.Writer, m *Message) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if len(data) > MAX_MESSAGE_SIZE {
+		return ErrMessageTooLarge
+	}
+	frame := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	copy(frame[4:], data)
+	_, err = w.Write(frame)
+	return err
+}
+
+func ReadMessage(r io.Reader) (*Message, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > MAX_MESSAGE_SIZE {
+		return nil, ErrMessageTooLarge
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	var m Message
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
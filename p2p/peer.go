@@ -0,0 +1,134 @@
+package p2p
+
+import (
+	"log"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	HANDSHAKE_TIMEOUT = 10 * time.Second
+	PING_INTERVAL     = 30 * time.Second
+	// 이 동안 아무 message도 오지 않으면 연결을 끊는다
+	IDLE_TIMEOUT = 3 * PING_INTERVAL
+	// 보내기를 기다리는 message가 이만큼 쌓이면 그 뒤의 message는 버린다
+	SEND_BUFFER_SIZE = 256
+)
+
+// handshake를 마친 연결 하나
+type Peer struct {
+	conn     net.Conn
+	version  *Version
+	outbound bool
+	// 다른 노드가 이 peer에 연결할 수 있는 주소, 받지 않는 peer면 빈 문자열
+	listenAddr string
+	send       chan *Message
+	quit       chan struct{}
+	once       sync.Once
+}
+
+// /p2p/peers 응답
+type PeerInfo struct {
+	NodeId          string `json:"node_id"`
+	Addr            string `json:"addr"`
+	ListenAddr      string `json:"listen_addr,omitempty"`
+	Outbound        bool   `json:"outbound"`
+	ProtocolVersion int    `json:"protocol_version"`
+	BestHeight      int    `json:"best_height"`
+	PrunedHeight    int    `json:"pruned_height,omitempty"`
+}
+
+func newPeer(conn net.Conn, version *Version, outbound bool, listenAddr string) *Peer {
+	return &Peer{
+		conn:       conn,
+		version:    version,
+		outbound:   outbound,
+		listenAddr: listenAddr,
+		send:       make(chan *Message, SEND_BUFFER_SIZE),
+		quit:       make(chan struct{}),
+	}
+}
+
+func (p *Peer) Id() string {
+	return p.version.NodeId
+}
+
+func (p *Peer) Addr() string {
+	return p.conn.RemoteAddr().String()
+}
+
+func (p *Peer) Info() *PeerInfo {
+	return &PeerInfo{
+		NodeId:          p.version.NodeId,
+		Addr:            p.Addr(),
+		ListenAddr:      p.listenAddr,
+		Outbound:        p.outbound,
+		ProtocolVersion: p.version.ProtocolVersion,
+		BestHeight:      p.version.BestHeight,
+		PrunedHeight:    p.version.PrunedHeight,
+	}
+}
+
+// 기다리지 않고 보내기 queue에 넣는다
+func (p *Peer) Send(msgType string, payload interface{}) bool {
+	m, err := NewMessage(msgType, payload)
+	if err != nil {
+		log.Printf("ERROR: %v", err)
+		return false
+	}
+	select {
+	case p.send <- m:
+		return true
+	case <-p.quit:
+		return false
+	default:
+		log.Printf("action=drop_message, peer=%s, type=%s", p.Id(), msgType)
+		return false
+	}
+}
+
+func (p *Peer) Close() {
+	p.once.Do(func() {
+		close(p.quit)
+		p.conn.Close()
+	})
+}
+
+func (p *Peer) writeLoop() {
+	ticker := time.NewTicker(PING_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case m := <-p.send:
+			if err := WriteMessage(p.conn, m); err != nil {
+				log.Printf("ERROR: write to %s: %v", p.Id(), err)
+				p.Close()
+				return
+			}
+		case <-ticker.C:
+			p.Send(MSG_PING, &Ping{Nonce: rand.Uint64()})
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// 연결이 끊길 때까지 받은 message를 handle에 넘긴다
+func (p *Peer) readLoop(handle func(*Peer, *Message)) {
+	defer p.Close()
+	for {
+		p.conn.SetReadDeadline(time.Now().Add(IDLE_TIMEOUT))
+		m, err := ReadMessage(p.conn)
+		if err != nil {
+			select {
+			case <-p.quit:
+			default:
+				log.Printf("action=peer_disconnected, peer=%s, error=%v", p.Id(), err)
+			}
+			return
+		}
+		handle(p, m)
+	}
+}
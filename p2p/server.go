@@ -0,0 +1,645 @@
+package p2p
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sw90lee/blockchain_study/block"
+)
+
+const (
+	DIAL_TIMEOUT = 5 * time.Second
+	// 이미 본 Transaction, Block 기록이 이만큼 쌓이면 비운다
+	MAX_KNOWN = 10000
+	// 연결한 peer가 이보다 적으면 AddrBook의 주소로 더 연결한다
+	TARGET_PEERS       = 8
+	DISCOVERY_INTERVAL = 30 * time.Second
+)
+
+var (
+	ErrProtocolVersion   = errors.New("unsupported protocol version")
+	ErrChainMismatch     = errors.New("chain id mismatch")
+	ErrSelfConnection    = errors.New("connected to self")
+	ErrDuplicatePeer     = errors.New("duplicate peer")
+	ErrUnexpectedMessage = errors.New("unexpected message")
+)
+
+// Blockchain 하나를 대신해 peer와 연결을 맺고 message를 주고받는다
+type Server struct {
+	bc       *block.Blockchain
+	chainId  string
+	nodeId   string
+	listener net.Listener
+
+	peers map[string]*Peer
+	// 다시 알리지 않도록 이미 본 Transaction, Block의 hash
+	known map[string]bool
+	book  *AddrBook
+	// 연결을 시도 중인 주소와 연결해 보니 자기 자신이었던 주소
+	dialing map[string]bool
+	self    map[string]bool
+	quit    chan struct{}
+	mux     sync.Mutex
+}
+
+// bc가 만들거나 받은 Transaction과 Block을 peer에게 알리도록 Relay로 등록한다
+func NewServer(bc *block.Blockchain, chainId string) *Server {
+	s := &Server{
+		bc:      bc,
+		chainId: chainId,
+		nodeId:  newNodeId(),
+		peers:   make(map[string]*Peer),
+		known:   make(map[string]bool),
+		book:    &AddrBook{addresses: make(map[string]*PeerAddress)},
+		dialing: make(map[string]bool),
+		self:    make(map[string]bool),
+		quit:    make(chan struct{}),
+	}
+	bc.SetRelay(s)
+	return s
+}
+
+// 기본은 저장하지 않는 AddrBook, Listen 전에 호출해야 한다
+func (s *Server) SetAddrBook(book *AddrBook) {
+	s.book = book
+}
+
+func (s *Server) AddrBook() *AddrBook {
+	return s.book
+}
+
+func newNodeId() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func (s *Server) NodeId() string {
+	return s.nodeId
+}
+
+func (s *Server) Listen(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.mux.Lock()
+	s.listener = l
+	s.mux.Unlock()
+	log.Printf("action=p2p_listen, addr=%s, node_id=%s", l.Addr(), s.nodeId)
+	go s.acceptLoop(l)
+	return nil
+}
+
+// Listen하지 않았으면 빈 문자열
+func (s *Server) Addr() string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+func (s *Server) acceptLoop(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			if _, err := s.handshake(conn, false, ""); err != nil {
+				log.Printf("ERROR: handshake with %s: %v", conn.RemoteAddr(), err)
+				conn.Close()
+			}
+		}()
+	}
+}
+
+// addr에 연결하고 handshake가 끝날 때까지 기다린다, 실패하면 AddrBook에 기록한다
+func (s *Server) Connect(addr string) (*Peer, error) {
+	conn, err := net.DialTimeout("tcp", addr, DIAL_TIMEOUT)
+	if err != nil {
+		s.book.Failed(addr)
+		return nil, err
+	}
+	p, err := s.handshake(conn, true, addr)
+	if err != nil {
+		conn.Close()
+		if errors.Is(err, ErrSelfConnection) {
+			s.mux.Lock()
+			s.self[addr] = true
+			s.mux.Unlock()
+		} else if !errors.Is(err, ErrDuplicatePeer) {
+			s.book.Failed(addr)
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+// 이미 연결했거나 연결 중이거나 자기 자신인 주소는 건너뛴다
+func (s *Server) dial(addr string) {
+	s.mux.Lock()
+	if s.dialing[addr] || s.self[addr] || s.connectedLocked(addr) {
+		s.mux.Unlock()
+		return
+	}
+	s.dialing[addr] = true
+	s.mux.Unlock()
+
+	if _, err := s.Connect(addr); err != nil {
+		log.Printf("action=dial_failed, addr=%s, error=%v", addr, err)
+	}
+	s.mux.Lock()
+	delete(s.dialing, addr)
+	s.mux.Unlock()
+}
+
+func (s *Server) connectedLocked(addr string) bool {
+	for _, p := range s.peers {
+		if p.listenAddr == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// seeds를 AddrBook에 넣고, 연결한 peer가 TARGET_PEERS보다 적으면 주기적으로 더 연결한다
+func (s *Server) Discover(seeds []string) {
+	for _, seed := range seeds {
+		if _, err := s.book.Add(seed, ADDR_SOURCE_SEED); err != nil {
+			log.Printf("ERROR: seed %s: %v", seed, err)
+		}
+	}
+	go func() {
+		ticker := time.NewTicker(DISCOVERY_INTERVAL)
+		defer ticker.Stop()
+		for {
+			s.fill()
+			select {
+			case <-ticker.C:
+			case <-s.quit:
+				return
+			}
+		}
+	}()
+}
+
+// 최근에 본 주소부터 모자란 만큼 연결을 시도한다
+func (s *Server) fill() {
+	s.mux.Lock()
+	missing := TARGET_PEERS - len(s.peers) - len(s.dialing)
+	s.mux.Unlock()
+	for _, a := range s.book.Addresses() {
+		if missing <= 0 {
+			return
+		}
+		s.mux.Lock()
+		skip := s.dialing[a.Addr] || s.self[a.Addr] || s.connectedLocked(a.Addr)
+		s.mux.Unlock()
+		if !skip {
+			go s.dial(a.Addr)
+			missing -= 1
+		}
+	}
+}
+
+// 운영자가 넣은 주소, 바로 연결을 시도한다
+func (s *Server) AddPeer(addr string) error {
+	if _, err := s.book.Add(addr, ADDR_SOURCE_MANUAL); err != nil {
+		return err
+	}
+	go s.dial(addr)
+	return nil
+}
+
+// AddrBook에서 빼고 그 주소로 연결된 peer를 끊는다
+func (s *Server) RemovePeer(addr string) bool {
+	removed := s.book.Remove(addr)
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for _, p := range s.peers {
+		if p.listenAddr == addr {
+			p.Close()
+			removed = true
+		}
+	}
+	return removed
+}
+
+// /admin/peers 응답
+type KnownPeer struct {
+	*PeerAddress
+	Connected bool `json:"connected"`
+}
+
+// AddrBook의 주소와 지금 연결되어 있는지
+func (s *Server) KnownPeers() []*KnownPeer {
+	addresses := s.book.Addresses()
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	peers := make([]*KnownPeer, 0, len(addresses))
+	for _, a := range addresses {
+		peers = append(peers, &KnownPeer{PeerAddress: a, Connected: s.connectedLocked(a.Addr)})
+	}
+	return peers
+}
+
+func (s *Server) localVersion() *Version {
+	return &Version{
+		ProtocolVersion: PROTOCOL_VERSION,
+		ChainId:         s.chainId,
+		NodeId:          s.nodeId,
+		BestHeight:      len(s.bc.Chain()) - 1,
+		PrunedHeight:    s.bc.PrunedHeight(),
+		ListenAddr:      s.Addr(),
+	}
+}
+
+// 양쪽이 version을 보내고 확인한 뒤 verack을 보낸다, 성공하면 peer로 등록한다
+// dialAddr는 연결할 때 쓴 주소, 받은 연결이면 빈 문자열
+func (s *Server) handshake(conn net.Conn, outbound bool, dialAddr string) (*Peer, error) {
+	conn.SetDeadline(time.Now().Add(HANDSHAKE_TIMEOUT))
+	m, err := NewMessage(MSG_VERSION, s.localVersion())
+	if err != nil {
+		return nil, err
+	}
+	if err := WriteMessage(conn, m); err != nil {
+		return nil, err
+	}
+	var remote Version
+	if err := s.expect(conn, MSG_VERSION, &remote); err != nil {
+		return nil, err
+	}
+	if err := s.checkVersion(&remote); err != nil {
+		return nil, err
+	}
+	if err := WriteMessage(conn, &Message{Type: MSG_VERACK}); err != nil {
+		return nil, err
+	}
+	if err := s.expect(conn, MSG_VERACK, nil); err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+
+	listenAddr := dialAddr
+	if !outbound {
+		listenAddr = reachableAddr(conn.RemoteAddr(), remote.ListenAddr)
+	}
+	p := newPeer(conn, &remote, outbound, listenAddr)
+	if err := s.addPeer(p); err != nil {
+		return nil, err
+	}
+	if listenAddr != "" {
+		s.book.Seen(listenAddr, time.Now())
+	}
+	log.Printf("action=peer_connected, peer=%s, addr=%s, outbound=%v", p.Id(), p.Addr(), outbound)
+	go p.writeLoop()
+	go func() {
+		p.readLoop(s.handle)
+		s.dropPeer(p)
+	}()
+
+	p.Send(MSG_GET_ADDR, nil)
+	if remote.BestHeight > len(s.bc.Chain())-1 {
+		p.Send(MSG_GET_HEADERS, &GetBlocks{From: 0})
+	}
+	return p, nil
+}
+
+func (s *Server) expect(conn net.Conn, msgType string, v interface{}) error {
+	m, err := ReadMessage(conn)
+	if err != nil {
+		return err
+	}
+	if m.Type != msgType {
+		return fmt.Errorf("%w: %s, want %s", ErrUnexpectedMessage, m.Type, msgType)
+	}
+	if v == nil {
+		return nil
+	}
+	return m.Decode(v)
+}
+
+func (s *Server) checkVersion(v *Version) error {
+	if v.ProtocolVersion != PROTOCOL_VERSION {
+		return fmt.Errorf("%w: %d", ErrProtocolVersion, v.ProtocolVersion)
+	}
+	if v.ChainId != s.chainId {
+		return fmt.Errorf("%w: %q", ErrChainMismatch, v.ChainId)
+	}
+	if v.NodeId == s.nodeId {
+		return ErrSelfConnection
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if _, ok := s.peers[v.NodeId]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicatePeer, v.NodeId)
+	}
+	return nil
+}
+
+func (s *Server) addPeer(p *Peer) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if _, ok := s.peers[p.Id()]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicatePeer, p.Id())
+	}
+	s.peers[p.Id()] = p
+	return nil
+}
+
+// 받은 연결의 peer가 알린 listen 주소는 host가 0.0.0.0일 수 있으므로 연결이 온 IP와 그 port를 쓴다
+func reachableAddr(remote net.Addr, listenAddr string) string {
+	if listenAddr == "" {
+		return ""
+	}
+	_, port, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(remote.String())
+	if err != nil {
+		return ""
+	}
+	return net.JoinHostPort(host, port)
+}
+
+func (s *Server) dropPeer(p *Peer) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.peers[p.Id()] == p {
+		delete(s.peers, p.Id())
+	}
+}
+
+// node id 순서
+func (s *Server) Peers() []*PeerInfo {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	peers := make([]*PeerInfo, 0, len(s.peers))
+	for _, p := range s.peers {
+		peers = append(peers, p.Info())
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].NodeId < peers[j].NodeId })
+	return peers
+}
+
+func (s *Server) PeerCount() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return len(s.peers)
+}
+
+// 모든 peer에게 header chain을 요청한다, 더 긴 chain은 handleHeaders가 받아 온다
+func (s *Server) Sync() int {
+	n := s.PeerCount()
+	s.broadcast(nil, MSG_GET_HEADERS, &GetBlocks{From: 0})
+	return n
+}
+
+func (s *Server) Close() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	select {
+	case <-s.quit:
+	default:
+		close(s.quit)
+	}
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	for _, p := range s.peers {
+		p.Close()
+	}
+}
+
+// 처음 보는 key면 true
+func (s *Server) markKnown(key string) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.known[key] {
+		return false
+	}
+	if len(s.known) >= MAX_KNOWN {
+		s.known = make(map[string]bool)
+	}
+	s.known[key] = true
+	return true
+}
+
+// from을 뺀 모든 peer에게 보낸다
+func (s *Server) broadcast(from *Peer, msgType string, payload interface{}) {
+	s.mux.Lock()
+	peers := make([]*Peer, 0, len(s.peers))
+	for _, p := range s.peers {
+		if p != from {
+			peers = append(peers, p)
+		}
+	}
+	s.mux.Unlock()
+	for _, p := range peers {
+		p.Send(msgType, payload)
+	}
+}
+
+func transactionKey(tr *block.TransactionRequest) string {
+	return fmt.Sprintf("tx:%x", tr.Transaction().Hash())
+}
+
+func blockKey(b *block.Block) string {
+	return fmt.Sprintf("block:%x", b.Hash())
+}
+
+func (s *Server) RelayTransaction(tr *block.TransactionRequest) {
+	if s.markKnown(transactionKey(tr)) {
+		s.broadcast(nil, MSG_TRANSACTION, tr)
+	}
+}
+
+func (s *Server) RelayBlock(b *block.Block, height int) {
+	if s.markKnown(blockKey(b)) {
+		s.broadcast(nil, MSG_BLOCK, &BlockMessage{Height: height, Block: b})
+	}
+}
+
+func (s *Server) handle(p *Peer, m *Message) {
+	var err error
+	switch m.Type {
+	case MSG_PING:
+		var ping Ping
+		if err = m.Decode(&ping); err == nil {
+			p.Send(MSG_PONG, &ping)
+		}
+	case MSG_PONG:
+	case MSG_TRANSACTION:
+		err = s.handleTransaction(p, m)
+	case MSG_BLOCK:
+		err = s.handleBlock(p, m)
+	case MSG_GET_HEADERS:
+		var req GetBlocks
+		if err = m.Decode(&req); err == nil {
+			p.Send(MSG_HEADERS, &Headers{From: req.From, Headers: s.bc.HeadersFrom(req.From),
+				PrunedHeight: s.bc.PrunedHeight()})
+		}
+	case MSG_HEADERS:
+		err = s.handleHeaders(p, m)
+	case MSG_GET_BLOCKS:
+		var req GetBlocks
+		if err = m.Decode(&req); err == nil {
+			if blocks, ok := s.bc.BlocksFrom(req.From); ok {
+				p.Send(MSG_BLOCKS, &Blocks{From: req.From, Blocks: blocks})
+			} else {
+				err = fmt.Errorf("blocks from %d are pruned", req.From)
+			}
+		}
+	case MSG_BLOCKS:
+		err = s.handleBlocks(p, m)
+	case MSG_GET_ADDR:
+		p.Send(MSG_ADDR, s.addrFor(p))
+	case MSG_ADDR:
+		err = s.handleAddr(p, m)
+	default:
+		err = fmt.Errorf("%w: %s", ErrUnexpectedMessage, m.Type)
+	}
+	if err != nil {
+		log.Printf("ERROR: %s from %s: %v", m.Type, p.Id(), err)
+	}
+}
+
+func (s *Server) handleTransaction(p *Peer, m *Message) error {
+	var tr block.TransactionRequest
+	if err := m.Decode(&tr); err != nil {
+		return err
+	}
+	if !tr.Validate() {
+		return errors.New("invalid transaction request")
+	}
+	if !s.markKnown(transactionKey(&tr)) {
+		return nil
+	}
+	if s.bc.AddTransactionRequest(&tr) {
+		s.broadcast(p, MSG_TRANSACTION, &tr)
+	}
+	return nil
+}
+
+// 지금 끝에 바로 붙는 Block이면 받고, 더 앞선 Block이면 header부터 다시 받는다
+func (s *Server) handleBlock(p *Peer, m *Message) error {
+	var bm BlockMessage
+	if err := m.Decode(&bm); err != nil {
+		return err
+	}
+	if bm.Block == nil {
+		return errors.New("empty block")
+	}
+	if !s.markKnown(blockKey(bm.Block)) {
+		return nil
+	}
+	chain := s.bc.Chain()
+	switch {
+	case bm.Height == len(chain) && bm.Block.PreviousHash() == chain[len(chain)-1].Hash():
+		candidate := append(append([]*block.Block{}, chain...), bm.Block)
+		if s.bc.OfferChain(candidate, p.Id()) {
+			s.broadcast(p, MSG_BLOCK, &bm)
+		}
+	case bm.Height >= len(chain):
+		p.Send(MSG_GET_HEADERS, &GetBlocks{From: 0})
+	}
+	return nil
+}
+
+// 더 긴 header chain이면 갈라진 높이부터 Block을 요청한다, 그 높이의 body를 버린 peer에게는 요청하지 않는다
+func (s *Server) handleHeaders(p *Peer, m *Message) error {
+	var h Headers
+	if err := m.Decode(&h); err != nil {
+		return err
+	}
+	chain := s.bc.Chain()
+	if h.From != 0 || len(h.Headers) <= len(chain) {
+		return nil
+	}
+	if !s.bc.ValidHeaders(h.Headers) {
+		return errors.New("invalid headers")
+	}
+	fork := 0
+	for fork < len(chain) && chain[fork].Hash() == h.Headers[fork].Hash() {
+		fork += 1
+	}
+	if fork == 0 {
+		return errors.New("different genesis block")
+	}
+	// 정리된 peer는 갈라진 높이의 body가 없으므로 다른 peer를 기다린다
+	if fork < h.PrunedHeight {
+		log.Printf("action=skip_pruned_peer, peer=%s, fork=%d, pruned_height=%d", p.Id(), fork, h.PrunedHeight)
+		return nil
+	}
+	p.Send(MSG_GET_BLOCKS, &GetBlocks{From: fork})
+	return nil
+}
+
+func (s *Server) handleBlocks(p *Peer, m *Message) error {
+	var bs Blocks
+	if err := m.Decode(&bs); err != nil {
+		return err
+	}
+	chain := s.bc.Chain()
+	if bs.From <= 0 || bs.From > len(chain) || len(bs.Blocks) == 0 {
+		return nil
+	}
+	candidate := append(append([]*block.Block{}, chain[:bs.From]...), bs.Blocks...)
+	if s.bc.OfferChain(candidate, p.Id()) {
+		tip := candidate[len(candidate)-1]
+		s.markKnown(blockKey(tip))
+		s.broadcast(p, MSG_BLOCK, &BlockMessage{Height: len(candidate) - 1, Block: tip})
+	}
+	return nil
+}
+
+// handshake에 성공한 적 있는 주소 중 p 자신을 뺀 것
+func (s *Server) addrFor(p *Peer) *Addr {
+	addresses := []string{}
+	for _, a := range s.book.Addresses() {
+		if len(addresses) == MAX_ADDR_COUNT || a.LastSeen == 0 {
+			break
+		}
+		if a.Addr != p.listenAddr {
+			addresses = append(addresses, a.Addr)
+		}
+	}
+	return &Addr{Addresses: addresses}
+}
+
+// 처음 듣는 주소는 AddrBook에 넣고, peer가 모자라면 연결한다
+func (s *Server) handleAddr(p *Peer, m *Message) error {
+	var addr Addr
+	if err := m.Decode(&addr); err != nil {
+		return err
+	}
+	if len(addr.Addresses) > MAX_ADDR_COUNT {
+		return fmt.Errorf("%d addresses, at most %d allowed", len(addr.Addresses), MAX_ADDR_COUNT)
+	}
+	added := false
+	for _, a := range addr.Addresses {
+		isNew, err := s.book.Add(a, ADDR_SOURCE_PEER)
+		if err != nil {
+			log.Printf("ERROR: address from %s: %v", p.Id(), err)
+			continue
+		}
+		added = added || isNew
+	}
+	if added {
+		s.fill()
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package p2p
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sw90lee/blockchain_study/block"
+	"github.com/sw90lee/blockchain_study/clock"
+	"github.com/sw90lee/blockchain_study/wallet"
+)
+
+const TEST_CHAIN_ID = "test"
+
+// Block timestamp가 test마다 같도록 가짜 시계로 시작한다
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newServer(t *testing.T, name string, chainId string) *Server {
+	return newServerWithClock(t, name, chainId, clock.NewFake(start))
+}
+
+func newServerWithClock(t *testing.T, name string, chainId string, c clock.Clock) *Server {
+	bc := block.NewBlockchainWithClock(name, 0, c)
+	p := block.DefaultParams()
+	p.MiningDifficulty = 1
+	bc.SetParams(p)
+	s := NewServer(bc, chainId)
+	if err := s.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+func send(t *testing.T, bc *block.Blockchain, value float32) {
+	w := wallet.NewWallet()
+	wt := wallet.NewTransaction(w.PrivateKey(), w.PublicKey(), w.BlockchainAddress(), "bob", value)
+	bt := block.NewTransaction(w.BlockchainAddress(), "bob", value)
+	if !bc.CreateTransaction(bt, w.PublicKey(), wt.GenerateSignature()) {
+		t.Fatal("transaction rejected")
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	a, b := newServer(t, "a", TEST_CHAIN_ID), newServer(t, "b", TEST_CHAIN_ID)
+	p, err := a.Connect(b.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Id() != b.NodeId() || !p.Info().Outbound {
+		t.Fatalf("unexpected peer %+v", p.Info())
+	}
+	waitFor(t, "inbound peer", func() bool { return len(b.Peers()) == 1 })
+	if info := b.Peers()[0]; info.NodeId != a.NodeId() || info.Outbound || info.ListenAddr != a.Addr() {
+		t.Fatalf("unexpected peer %+v", info)
+	}
+
+	if _, err := a.Connect(b.Addr()); !errors.Is(err, ErrDuplicatePeer) {
+		t.Fatalf("expected duplicate peer, got %v", err)
+	}
+	if _, err := a.Connect(a.Addr()); !errors.Is(err, ErrSelfConnection) {
+		t.Fatalf("expected self connection, got %v", err)
+	}
+}
+
+func TestHandshakeChainMismatch(t *testing.T) {
+	a, b := newServer(t, "a", TEST_CHAIN_ID), newServer(t, "b", "other")
+	if _, err := a.Connect(b.Addr()); !errors.Is(err, ErrChainMismatch) {
+		t.Fatalf("expected chain mismatch, got %v", err)
+	}
+	if len(a.Peers()) != 0 || len(b.Peers()) != 0 {
+		t.Fatal("peer registered after failed handshake")
+	}
+}
+
+// 다른 goroutine이 chain을 바꾸는 중에도 안전하게 읽는다
+func tip(bc *block.Blockchain) [32]byte {
+	chain := bc.Chain()
+	return chain[len(chain)-1].Hash()
+}
+
+// a에서 만든 Transaction과 Block이 b를 거쳐 c까지 전해진다
+func TestPropagation(t *testing.T) {
+	a, b, c := newServer(t, "a", TEST_CHAIN_ID), newServer(t, "b", TEST_CHAIN_ID), newServer(t, "c", TEST_CHAIN_ID)
+	if _, err := a.Connect(b.Addr()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Connect(c.Addr()); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "peers", func() bool { return len(b.Peers()) >= 2 && len(c.Peers()) >= 1 })
+
+	send(t, a.bc, 1)
+	waitFor(t, "transaction", func() bool { return len(c.bc.TransactionPool()) == 1 })
+
+	if !a.bc.Mining() {
+		t.Fatal("mining failed")
+	}
+	waitFor(t, "block", func() bool { return len(c.bc.Chain()) == 2 })
+	if tip(c.bc) != tip(a.bc) {
+		t.Fatal("chains differ")
+	}
+	waitFor(t, "pool cleared", func() bool { return len(c.bc.TransactionPool()) == 0 })
+}
+
+// 늦게 연결한 노드는 handshake의 높이를 보고 chain을 받아 간다
+func TestSyncOnConnect(t *testing.T) {
+	a, b := newServer(t, "a", TEST_CHAIN_ID), newServer(t, "b", TEST_CHAIN_ID)
+	for i := 0; i < 3; i++ {
+		send(t, a.bc, float32(i+1))
+		if !a.bc.Mining() {
+			t.Fatal("mining failed")
+		}
+	}
+	if _, err := b.Connect(a.Addr()); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "chain", func() bool { return len(b.bc.Chain()) == 4 })
+	if tip(b.bc) != tip(a.bc) {
+		t.Fatal("chains differ")
+	}
+}
+
+// 알리지 않고 만든 Block도 Sync로 받아 온다
+func TestSync(t *testing.T) {
+	a, b := newServer(t, "a", TEST_CHAIN_ID), newServer(t, "b", TEST_CHAIN_ID)
+	if _, err := b.Connect(a.Addr()); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "peers", func() bool { return a.PeerCount() == 1 })
+
+	a.bc.SetRelay(nil)
+	for i := 0; i < 2; i++ {
+		send(t, a.bc, float32(i+1))
+		if !a.bc.Mining() {
+			t.Fatal("mining failed")
+		}
+	}
+	a.bc.SetRelay(a)
+	if len(b.bc.Chain()) != 1 {
+		t.Fatal("block relayed")
+	}
+
+	if n := b.Sync(); n != 1 {
+		t.Fatalf("asked %d peers", n)
+	}
+	waitFor(t, "chain", func() bool { return len(b.bc.Chain()) == 3 })
+	if tip(b.bc) != tip(a.bc) {
+		t.Fatal("chains differ")
+	}
+}
+
+// 시계가 서로 다른 노드도 genesis Block이 같으므로 chain을 받아 간다
+func TestSyncWithIndependentClocks(t *testing.T) {
+	a := newServerWithClock(t, "a", TEST_CHAIN_ID, clock.System)
+	b := newServerWithClock(t, "b", TEST_CHAIN_ID, clock.NewFake(start.Add(72*time.Hour)))
+	if a.bc.Chain()[0].Hash() != b.bc.Chain()[0].Hash() {
+		t.Fatal("genesis blocks differ")
+	}
+	for i := 0; i < 2; i++ {
+		send(t, a.bc, float32(i+1))
+		if !a.bc.Mining() {
+			t.Fatal("mining failed")
+		}
+	}
+	if _, err := b.Connect(a.Addr()); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "chain", func() bool { return len(b.bc.Chain()) == 3 })
+	if tip(b.bc) != tip(a.bc) {
+		t.Fatal("chains differ")
+	}
+}
+
+// 갈라진 높이의 body를 버린 peer에게는 get_blocks를 보내지 않는다
+func TestSkipPrunedPeer(t *testing.T) {
+	a, b := newServer(t, "a", TEST_CHAIN_ID), newServer(t, "b", TEST_CHAIN_ID)
+	for i := 0; i < 3; i++ {
+		send(t, a.bc, float32(i+1))
+		if !a.bc.Mining() {
+			t.Fatal("mining failed")
+		}
+	}
+	a.bc.SetPruneDepth(1)
+	if v := a.localVersion(); v.PrunedHeight != 3 || v.BestHeight != 3 {
+		t.Fatalf("unexpected version %+v", v)
+	}
+	p, err := b.Connect(a.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Info().PrunedHeight != 3 {
+		t.Fatalf("unexpected peer %+v", p.Info())
+	}
+
+	for _, c := range []struct {
+		prunedHeight int
+		sent         int
+	}{{3, 0}, {1, 1}} {
+		q := newPeer(nil, &Version{NodeId: "a"}, true, "")
+		m, _ := NewMessage(MSG_HEADERS, &Headers{Headers: a.bc.Headers(), PrunedHeight: c.prunedHeight})
+		if err := b.handleHeaders(q, m); err != nil {
+			t.Fatal(err)
+		}
+		if len(q.send) != c.sent {
+			t.Fatalf("pruned height %d: %d messages sent", c.prunedHeight, len(q.send))
+		}
+	}
+}
+
+// a와 c는 seed b만 알지만 b에게 받은 주소로 서로 연결한다
+func TestDiscoverFromSeed(t *testing.T) {
+	a, b, c := newServer(t, "a", TEST_CHAIN_ID), newServer(t, "b", TEST_CHAIN_ID), newServer(t, "c", TEST_CHAIN_ID)
+	c.Discover([]string{b.Addr()})
+	waitFor(t, "seed", func() bool { return len(b.Peers()) == 1 })
+	a.Discover([]string{b.Addr()})
+
+	connected := func(s *Server, addr string) bool {
+		for _, p := range s.KnownPeers() {
+			if p.Addr == addr && p.Connected && p.LastSeen > 0 {
+				return true
+			}
+		}
+		return false
+	}
+	waitFor(t, "discovery", func() bool { return connected(a, c.Addr()) || connected(c, a.Addr()) })
+
+	if !a.RemovePeer(b.Addr()) {
+		t.Fatal("seed not removed")
+	}
+	waitFor(t, "disconnect", func() bool { return len(b.Peers()) == 1 })
+}
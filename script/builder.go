@@ -0,0 +1,50 @@
+package script
+
+type Builder struct {
+	script []byte
+	err    error
+}
+
+func NewBuilder() *Builder {
+	return &Builder{script: make([]byte, 0)}
+}
+
+func (b *Builder) AddOp(op byte) *Builder {
+	b.script = append(b.script, op)
+	return b
+}
+
+// 데이터 push, 가능하면 OP_0 ~ OP_16 으로 줄여서 넣는다
+func (b *Builder) AddData(data []byte) *Builder {
+	if len(data) > MAX_ELEMENT_SIZE {
+		b.err = ErrElementTooBig
+		return b
+	}
+	if len(data) == 0 {
+		return b.AddOp(OP_0)
+	}
+	if len(data) == 1 && data[0] >= 1 && data[0] <= 16 {
+		return b.AddOp(OP_1 + data[0] - 1)
+	}
+	b.script = append(b.script, OP_PUSHDATA1, byte(len(data)))
+	b.script = append(b.script, data...)
+	return b
+}
+
+func (b *Builder) AddInt(n int64) *Builder {
+	if n < 0 {
+		b.err = ErrInvalidNumber
+		return b
+	}
+	return b.AddData(NumberBytes(n))
+}
+
+func (b *Builder) Script() ([]byte, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if len(b.script) > MAX_SCRIPT_SIZE {
+		return nil, ErrScriptTooBig
+	}
+	return b.script, nil
+}
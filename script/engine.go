@@ -0,0 +1,308 @@
+package script
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"math/big"
+)
+
+// script 실행에 필요한 Transaction 정보
+type Context struct {
+	// 서명 대상 Transaction hash
+	SigHash [32]byte
+	// Transaction이 들어갈 Block 높이와 시각(unix second)
+	Height int64
+	Time   int64
+}
+
+type engine struct {
+	stack [][]byte
+	ctx   *Context
+	ops   int
+}
+
+// unlocking script를 실행한 stack 위에서 locking script를 실행
+func Execute(unlockingScript []byte, lockingScript []byte, ctx *Context) error {
+	if !IsPushOnly(unlockingScript) {
+		return ErrNotPushOnly
+	}
+	e := &engine{stack: make([][]byte, 0), ctx: ctx}
+	if err := e.run(unlockingScript); err != nil {
+		return err
+	}
+	if err := e.run(lockingScript); err != nil {
+		return err
+	}
+	if len(e.stack) == 0 || !asBool(e.stack[len(e.stack)-1]) {
+		return ErrEvalFalse
+	}
+	return nil
+}
+
+func (e *engine) push(data []byte) error {
+	if len(data) > MAX_ELEMENT_SIZE {
+		return ErrElementTooBig
+	}
+	if len(e.stack) >= MAX_STACK_SIZE {
+		return ErrStackOverflow
+	}
+	e.stack = append(e.stack, data)
+	return nil
+}
+
+func (e *engine) pop() ([]byte, error) {
+	if len(e.stack) == 0 {
+		return nil, ErrStackUnderflow
+	}
+	data := e.stack[len(e.stack)-1]
+	e.stack = e.stack[:len(e.stack)-1]
+	return data, nil
+}
+
+func (e *engine) peek() ([]byte, error) {
+	if len(e.stack) == 0 {
+		return nil, ErrStackUnderflow
+	}
+	return e.stack[len(e.stack)-1], nil
+}
+
+func (e *engine) popNumber() (int64, error) {
+	data, err := e.pop()
+	if err != nil {
+		return 0, err
+	}
+	return numberFromBytes(data)
+}
+
+func boolBytes(b bool) []byte {
+	if b {
+		return []byte{1}
+	}
+	return []byte{}
+}
+
+func (e *engine) run(script []byte) error {
+	instructions, err := parse(script)
+	if err != nil {
+		return err
+	}
+
+	// 실행 중인 OP_IF 분기들의 실행 여부
+	conditions := make([]bool, 0)
+	executing := func() bool {
+		for _, c := range conditions {
+			if !c {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, in := range instructions {
+		if !isPush(in.op) {
+			e.ops += 1
+			if e.ops > MAX_OPS {
+				return ErrTooManyOps
+			}
+		}
+
+		switch in.op {
+		case OP_IF, OP_NOTIF:
+			cond := false
+			if executing() {
+				data, err := e.pop()
+				if err != nil {
+					return err
+				}
+				cond = asBool(data)
+				if in.op == OP_NOTIF {
+					cond = !cond
+				}
+			}
+			conditions = append(conditions, cond)
+			continue
+		case OP_ELSE:
+			if len(conditions) == 0 {
+				return ErrUnbalancedIf
+			}
+			conditions[len(conditions)-1] = !conditions[len(conditions)-1]
+			continue
+		case OP_ENDIF:
+			if len(conditions) == 0 {
+				return ErrUnbalancedIf
+			}
+			conditions = conditions[:len(conditions)-1]
+			continue
+		}
+
+		if !executing() {
+			continue
+		}
+		if err := e.step(in); err != nil {
+			return err
+		}
+	}
+
+	if len(conditions) != 0 {
+		return ErrUnbalancedIf
+	}
+	return nil
+}
+
+func (e *engine) step(in instruction) error {
+	if isPush(in.op) {
+		return e.push(in.data)
+	}
+
+	switch in.op {
+	case OP_VERIFY:
+		data, err := e.pop()
+		if err != nil {
+			return err
+		}
+		if !asBool(data) {
+			return ErrVerifyFailed
+		}
+	case OP_RETURN:
+		return ErrEarlyReturn
+	case OP_DROP:
+		_, err := e.pop()
+		return err
+	case OP_DUP:
+		data, err := e.peek()
+		if err != nil {
+			return err
+		}
+		return e.push(data)
+	case OP_SWAP:
+		if len(e.stack) < 2 {
+			return ErrStackUnderflow
+		}
+		n := len(e.stack)
+		e.stack[n-1], e.stack[n-2] = e.stack[n-2], e.stack[n-1]
+	case OP_EQUAL, OP_EQUALVERIFY:
+		a, err := e.pop()
+		if err != nil {
+			return err
+		}
+		b, err := e.pop()
+		if err != nil {
+			return err
+		}
+		equal := bytes.Equal(a, b)
+		if in.op == OP_EQUALVERIFY {
+			if !equal {
+				return ErrVerifyFailed
+			}
+			return nil
+		}
+		return e.push(boolBytes(equal))
+	case OP_SHA256:
+		data, err := e.pop()
+		if err != nil {
+			return err
+		}
+		h := sha256.Sum256(data)
+		return e.push(h[:])
+	case OP_CHECKSIG, OP_CHECKSIGVERIFY:
+		publicKey, err := e.pop()
+		if err != nil {
+			return err
+		}
+		signature, err := e.pop()
+		if err != nil {
+			return err
+		}
+		ok := e.verifySignature(publicKey, signature)
+		if in.op == OP_CHECKSIGVERIFY {
+			if !ok {
+				return ErrVerifyFailed
+			}
+			return nil
+		}
+		return e.push(boolBytes(ok))
+	case OP_CHECKMULTISIG:
+		return e.checkMultisig()
+	case OP_CHECKLOCKTIMEVERIFY:
+		// stack은 그대로 두고 lock time만 확인 (보통 뒤에 OP_DROP)
+		data, err := e.peek()
+		if err != nil {
+			return err
+		}
+		lockTime, err := numberFromBytes(data)
+		if err != nil {
+			return err
+		}
+		if lockTime < LOCKTIME_THRESHOLD {
+			if e.ctx.Height < lockTime {
+				return ErrLockTime
+			}
+		} else if e.ctx.Time < lockTime {
+			return ErrLockTime
+		}
+	default:
+		return ErrUnknownOpcode
+	}
+	return nil
+}
+
+// <sig1> ... <sigM> M <pub1> ... <pubN> N OP_CHECKMULTISIG
+// 서명은 public key와 같은 순서여야 한다
+func (e *engine) checkMultisig() error {
+	n, err := e.popNumber()
+	if err != nil {
+		return err
+	}
+	if n <= 0 || n > MAX_MULTISIG_KEYS {
+		return ErrInvalidMultisig
+	}
+	publicKeys := make([][]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		if publicKeys[i], err = e.pop(); err != nil {
+			return err
+		}
+	}
+	m, err := e.popNumber()
+	if err != nil {
+		return err
+	}
+	if m <= 0 || m > n {
+		return ErrInvalidMultisig
+	}
+	signatures := make([][]byte, m)
+	for i := m - 1; i >= 0; i-- {
+		if signatures[i], err = e.pop(); err != nil {
+			return err
+		}
+	}
+
+	k := 0
+	for _, signature := range signatures {
+		for k < len(publicKeys) && !e.verifySignature(publicKeys[k], signature) {
+			k += 1
+		}
+		if k == len(publicKeys) {
+			return e.push(boolBytes(false))
+		}
+		k += 1
+	}
+	return e.push(boolBytes(true))
+}
+
+// public key는 X||Y, 서명은 R||S 각각 64바이트
+func (e *engine) verifySignature(publicKey []byte, signature []byte) bool {
+	if len(publicKey) != 64 || len(signature) != 64 {
+		return false
+	}
+	curve := elliptic.P256()
+	x := new(big.Int).SetBytes(publicKey[:32])
+	y := new(big.Int).SetBytes(publicKey[32:])
+	if !curve.IsOnCurve(x, y) {
+		return false
+	}
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	return ecdsa.Verify(&ecdsa.PublicKey{Curve: curve, X: x, Y: y}, e.ctx.SigHash[:], r, s)
+}
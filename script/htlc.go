@@ -0,0 +1,99 @@
+package script
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+
+	"github.com/sw90lee/blockchain_study/utils"
+)
+
+// Hash Time Locked Contract
+//
+//	OP_IF
+//	    OP_SHA256 <hash> OP_EQUALVERIFY <recipient pub> OP_CHECKSIG
+//	OP_ELSE
+//	    <timeout> OP_CHECKLOCKTIMEVERIFY OP_DROP <sender pub> OP_CHECKSIG
+//	OP_ENDIF
+func HTLCScript(hash [32]byte, recipientPublicKey *ecdsa.PublicKey,
+	senderPublicKey *ecdsa.PublicKey, timeout int64) ([]byte, error) {
+	return NewBuilder().
+		AddOp(OP_IF).
+		AddOp(OP_SHA256).
+		AddData(hash[:]).
+		AddOp(OP_EQUALVERIFY).
+		AddData(PublicKeyBytes(recipientPublicKey)).
+		AddOp(OP_CHECKSIG).
+		AddOp(OP_ELSE).
+		AddInt(timeout).
+		AddOp(OP_CHECKLOCKTIMEVERIFY).
+		AddOp(OP_DROP).
+		AddData(PublicKeyBytes(senderPublicKey)).
+		AddOp(OP_CHECKSIG).
+		AddOp(OP_ENDIF).
+		Script()
+}
+
+// 수신자가 preimage를 공개하고 가져가는 unlocking script
+func HTLCRedeemScript(s *utils.Signature, preimage []byte) ([]byte, error) {
+	return NewBuilder().
+		AddData(SignatureBytes(s)).
+		AddData(preimage).
+		AddOp(OP_1).
+		Script()
+}
+
+// timeout 이후 송신자가 돌려받는 unlocking script
+func HTLCRefundScript(s *utils.Signature) ([]byte, error) {
+	return NewBuilder().
+		AddData(SignatureBytes(s)).
+		AddOp(OP_0).
+		Script()
+}
+
+type HTLC struct {
+	Hash               []byte
+	RecipientPublicKey []byte
+	SenderPublicKey    []byte
+	Timeout            int64
+}
+
+// HTLCScript로 만든 locking script에서 조건을 읽음
+func ParseHTLC(lockingScript []byte) (*HTLC, bool) {
+	instructions, err := parse(lockingScript)
+	if err != nil || len(instructions) != 13 {
+		return nil, false
+	}
+	template := []byte{OP_IF, OP_SHA256, OP_PUSHDATA1, OP_EQUALVERIFY, OP_PUSHDATA1, OP_CHECKSIG,
+		OP_ELSE, 0, OP_CHECKLOCKTIMEVERIFY, OP_DROP, OP_PUSHDATA1, OP_CHECKSIG, OP_ENDIF}
+	for i, op := range template {
+		if i == 7 {
+			if !isPush(instructions[i].op) {
+				return nil, false
+			}
+			continue
+		}
+		if instructions[i].op != op {
+			return nil, false
+		}
+	}
+	timeout, err := numberFromBytes(instructions[7].data)
+	if err != nil {
+		return nil, false
+	}
+	return &HTLC{
+		Hash:               instructions[2].data,
+		RecipientPublicKey: instructions[4].data,
+		SenderPublicKey:    instructions[10].data,
+		Timeout:            timeout,
+	}, true
+}
+
+// redeem unlocking script에서 공개된 preimage를 꺼냄
+func HTLCPreimage(unlockingScript []byte) ([]byte, bool) {
+	instructions, err := parse(unlockingScript)
+	if err != nil || len(instructions) != 3 ||
+		!bytes.Equal(instructions[2].data, NumberBytes(1)) {
+		return nil, false
+	}
+	return instructions[1].data, true
+}
@@ -0,0 +1,66 @@
+package script
+
+import "strconv"
+
+const (
+	OP_0         = 0x00
+	OP_PUSHDATA1 = 0x4c
+	OP_1         = 0x51
+	OP_16        = 0x60
+
+	OP_IF     = 0x63
+	OP_NOTIF  = 0x64
+	OP_ELSE   = 0x67
+	OP_ENDIF  = 0x68
+	OP_VERIFY = 0x69
+	OP_RETURN = 0x6a
+
+	OP_DROP = 0x75
+	OP_DUP  = 0x76
+	OP_SWAP = 0x7c
+
+	OP_EQUAL       = 0x87
+	OP_EQUALVERIFY = 0x88
+
+	OP_SHA256              = 0xa8
+	OP_CHECKSIG            = 0xac
+	OP_CHECKSIGVERIFY      = 0xad
+	OP_CHECKMULTISIG       = 0xae
+	OP_CHECKLOCKTIMEVERIFY = 0xb1
+)
+
+var opcodeNames = map[byte]string{
+	OP_0:                   "OP_0",
+	OP_PUSHDATA1:           "OP_PUSHDATA1",
+	OP_IF:                  "OP_IF",
+	OP_NOTIF:               "OP_NOTIF",
+	OP_ELSE:                "OP_ELSE",
+	OP_ENDIF:               "OP_ENDIF",
+	OP_VERIFY:              "OP_VERIFY",
+	OP_RETURN:              "OP_RETURN",
+	OP_DROP:                "OP_DROP",
+	OP_DUP:                 "OP_DUP",
+	OP_SWAP:                "OP_SWAP",
+	OP_EQUAL:               "OP_EQUAL",
+	OP_EQUALVERIFY:         "OP_EQUALVERIFY",
+	OP_SHA256:              "OP_SHA256",
+	OP_CHECKSIG:            "OP_CHECKSIG",
+	OP_CHECKSIGVERIFY:      "OP_CHECKSIGVERIFY",
+	OP_CHECKMULTISIG:       "OP_CHECKMULTISIG",
+	OP_CHECKLOCKTIMEVERIFY: "OP_CHECKLOCKTIMEVERIFY",
+}
+
+var opcodeValues = make(map[string]byte)
+
+func init() {
+	for op := byte(OP_1); op <= OP_16; op++ {
+		opcodeNames[op] = "OP_" + strconv.Itoa(int(op-OP_1+1))
+	}
+	for op, name := range opcodeNames {
+		opcodeValues[name] = op
+	}
+}
+
+func isPush(op byte) bool {
+	return op == OP_0 || op == OP_PUSHDATA1 || (op >= OP_1 && op <= OP_16)
+}
@@ -0,0 +1,162 @@
+// Package script 는 Transaction 출력에 거는 잠금 조건을 표현하는
+// 작은 stack 기반 script 언어를 구현한다.
+//
+// 잠금(locking) script 는 수신 주소를 만들고, 그 주소에서 돈을 꺼낼 때
+// 해제(unlocking) script 를 함께 제출한다. 두 script 는 같은 stack 위에서
+// 순서대로 실행되며 마지막에 stack 맨 위 값이 참이어야 한다.
+package script
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	MAX_SCRIPT_SIZE    = 1024
+	MAX_ELEMENT_SIZE   = 128
+	MAX_STACK_SIZE     = 100
+	MAX_OPS            = 200
+	MAX_NUMBER_SIZE    = 8
+	MAX_MULTISIG_KEYS  = 15
+	LOCKTIME_THRESHOLD = 500000000
+)
+
+var (
+	ErrScriptTooBig    = errors.New("script: script too big")
+	ErrElementTooBig   = errors.New("script: element too big")
+	ErrStackOverflow   = errors.New("script: stack overflow")
+	ErrStackUnderflow  = errors.New("script: stack underflow")
+	ErrTooManyOps      = errors.New("script: too many operations")
+	ErrMalformedPush   = errors.New("script: malformed push")
+	ErrUnknownOpcode   = errors.New("script: unknown opcode")
+	ErrUnbalancedIf    = errors.New("script: unbalanced conditional")
+	ErrVerifyFailed    = errors.New("script: verify failed")
+	ErrEarlyReturn     = errors.New("script: OP_RETURN")
+	ErrNotPushOnly     = errors.New("script: unlocking script must be push only")
+	ErrInvalidNumber   = errors.New("script: invalid number")
+	ErrLockTime        = errors.New("script: lock time not reached")
+	ErrEvalFalse       = errors.New("script: evaluated to false")
+	ErrInvalidMultisig = errors.New("script: invalid multisig")
+)
+
+type instruction struct {
+	op   byte
+	data []byte
+}
+
+// script를 opcode 단위로 분해
+func parse(script []byte) ([]instruction, error) {
+	if len(script) > MAX_SCRIPT_SIZE {
+		return nil, ErrScriptTooBig
+	}
+	instructions := make([]instruction, 0)
+	for i := 0; i < len(script); i++ {
+		op := script[i]
+		switch {
+		case op == OP_PUSHDATA1:
+			if i+1 >= len(script) {
+				return nil, ErrMalformedPush
+			}
+			n := int(script[i+1])
+			if i+2+n > len(script) {
+				return nil, ErrMalformedPush
+			}
+			if n > MAX_ELEMENT_SIZE {
+				return nil, ErrElementTooBig
+			}
+			instructions = append(instructions, instruction{op, script[i+2 : i+2+n]})
+			i += 1 + n
+		case op == OP_0:
+			instructions = append(instructions, instruction{op, []byte{}})
+		case op >= OP_1 && op <= OP_16:
+			instructions = append(instructions, instruction{op, NumberBytes(int64(op - OP_1 + 1))})
+		default:
+			if _, ok := opcodeNames[op]; !ok {
+				return nil, fmt.Errorf("%w 0x%02x", ErrUnknownOpcode, op)
+			}
+			instructions = append(instructions, instruction{op, nil})
+		}
+	}
+	return instructions, nil
+}
+
+func IsPushOnly(script []byte) bool {
+	instructions, err := parse(script)
+	if err != nil {
+		return false
+	}
+	for _, in := range instructions {
+		if !isPush(in.op) {
+			return false
+		}
+	}
+	return true
+}
+
+// 사람이 읽을 수 있는 형태로 변환 ("OP_DUP 02ab... OP_CHECKSIG")
+func Disassemble(script []byte) (string, error) {
+	instructions, err := parse(script)
+	if err != nil {
+		return "", err
+	}
+	tokens := make([]string, len(instructions))
+	for i, in := range instructions {
+		if in.op == OP_PUSHDATA1 {
+			tokens[i] = hex.EncodeToString(in.data)
+		} else {
+			tokens[i] = opcodeNames[in.op]
+		}
+	}
+	return strings.Join(tokens, " "), nil
+}
+
+// Disassemble 형식의 문자열을 script로 변환
+func Assemble(asm string) ([]byte, error) {
+	b := NewBuilder()
+	for _, token := range strings.Fields(asm) {
+		if op, ok := opcodeValues[token]; ok {
+			b.AddOp(op)
+			continue
+		}
+		data, err := hex.DecodeString(token)
+		if err != nil {
+			return nil, fmt.Errorf("script: invalid token %q", token)
+		}
+		b.AddData(data)
+	}
+	return b.Script()
+}
+
+// 숫자는 최대 8바이트의 big-endian 음이 아닌 정수
+func NumberBytes(n int64) []byte {
+	if n == 0 {
+		return []byte{}
+	}
+	b := make([]byte, 0, MAX_NUMBER_SIZE)
+	for v := uint64(n); v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	return b
+}
+
+func numberFromBytes(b []byte) (int64, error) {
+	if len(b) > MAX_NUMBER_SIZE || (len(b) == MAX_NUMBER_SIZE && b[0]&0x80 != 0) {
+		return 0, ErrInvalidNumber
+	}
+	var n int64
+	for _, c := range b {
+		n = n<<8 | int64(c)
+	}
+	return n, nil
+}
+
+func asBool(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,232 @@
+package script
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/sw90lee/blockchain_study/utils"
+)
+
+var sigHash = sha256.Sum256([]byte("transaction"))
+
+func testContext() *Context {
+	return &Context{SigHash: sigHash, Height: 100, Time: 1700000000}
+}
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func sign(t *testing.T, key *ecdsa.PrivateKey) *utils.Signature {
+	r, s, err := ecdsa.Sign(rand.Reader, key, sigHash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &utils.Signature{R: r, S: s}
+}
+
+func mustAssemble(t *testing.T, asm string) []byte {
+	s, err := Assemble(asm)
+	if err != nil {
+		t.Fatalf("%s: %v", asm, err)
+	}
+	return s
+}
+
+func repeat(op byte, n int) []byte {
+	return bytes.Repeat([]byte{op}, n)
+}
+
+// stack, 비교, 분기 opcode
+func TestExecuteOpcodes(t *testing.T) {
+	for _, c := range []struct {
+		name      string
+		unlocking string
+		locking   string
+		err       error
+	}{
+		{"dup equal", "OP_5", "OP_DUP OP_EQUAL", nil},
+		{"swap", "OP_1 OP_2", "OP_SWAP OP_1 OP_EQUALVERIFY OP_2 OP_EQUAL", nil},
+		{"drop", "OP_0 OP_1", "OP_DROP OP_DROP OP_1", nil},
+		{"equal false", "OP_1", "OP_2 OP_EQUAL", ErrEvalFalse},
+		{"equalverify", "OP_1", "OP_2 OP_EQUALVERIFY OP_1", ErrVerifyFailed},
+		{"verify", "OP_0", "OP_VERIFY OP_1", ErrVerifyFailed},
+		{"return", "OP_1", "OP_RETURN", ErrEarlyReturn},
+		{"if", "OP_1", "OP_IF OP_2 OP_ELSE OP_0 OP_ENDIF", nil},
+		{"else", "OP_0", "OP_IF OP_0 OP_ELSE OP_3 OP_ENDIF", nil},
+		{"notif", "OP_0", "OP_NOTIF OP_1 OP_ELSE OP_0 OP_ENDIF", nil},
+		{"nested if", "OP_0 OP_1", "OP_IF OP_IF OP_0 OP_ELSE OP_1 OP_ENDIF OP_ENDIF", nil},
+		{"skipped branch", "OP_0", "OP_IF OP_RETURN OP_ENDIF OP_1", nil},
+		{"unbalanced", "OP_1", "OP_IF OP_1", ErrUnbalancedIf},
+		{"stray endif", "OP_1", "OP_ENDIF", ErrUnbalancedIf},
+		{"underflow", "", "OP_DUP", ErrStackUnderflow},
+		{"empty stack", "", "", ErrEvalFalse},
+	} {
+		err := Execute(mustAssemble(t, c.unlocking), mustAssemble(t, c.locking), testContext())
+		if !errors.Is(err, c.err) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.err, err)
+		}
+	}
+}
+
+func TestHashLock(t *testing.T) {
+	preimage := []byte("secret")
+	locking, _ := HashLockScript(sha256.Sum256(preimage))
+	good, _ := NewBuilder().AddData(preimage).Script()
+	bad, _ := NewBuilder().AddData([]byte("guess")).Script()
+	if err := Execute(good, locking, testContext()); err != nil {
+		t.Fatal(err)
+	}
+	if err := Execute(bad, locking, testContext()); !errors.Is(err, ErrEvalFalse) {
+		t.Fatalf("expected false, got %v", err)
+	}
+}
+
+func TestCheckSig(t *testing.T) {
+	key, other := newKey(t), newKey(t)
+	locking, _ := PayToPublicKey(&key.PublicKey)
+	good, _ := SignatureScript(sign(t, key))
+	bad, _ := SignatureScript(sign(t, other))
+	if err := Execute(good, locking, testContext()); err != nil {
+		t.Fatal(err)
+	}
+	if err := Execute(bad, locking, testContext()); !errors.Is(err, ErrEvalFalse) {
+		t.Fatalf("expected false, got %v", err)
+	}
+	// 다른 Transaction의 서명은 쓸 수 없다
+	ctx := testContext()
+	ctx.SigHash = sha256.Sum256([]byte("other transaction"))
+	if err := Execute(good, locking, ctx); !errors.Is(err, ErrEvalFalse) {
+		t.Fatalf("expected false, got %v", err)
+	}
+
+	verify, _ := NewBuilder().AddData(PublicKeyBytes(&key.PublicKey)).AddOp(OP_CHECKSIGVERIFY).AddOp(OP_1).Script()
+	if err := Execute(bad, verify, testContext()); !errors.Is(err, ErrVerifyFailed) {
+		t.Fatalf("expected verify failure, got %v", err)
+	}
+}
+
+func TestCheckMultisig(t *testing.T) {
+	keys := []*ecdsa.PrivateKey{newKey(t), newKey(t), newKey(t)}
+	publicKeys := []*ecdsa.PublicKey{&keys[0].PublicKey, &keys[1].PublicKey, &keys[2].PublicKey}
+	locking, _ := MultisigScript(2, publicKeys)
+
+	for _, c := range []struct {
+		name   string
+		signer []int
+		err    error
+	}{
+		{"first and last", []int{0, 2}, nil},
+		{"last two", []int{1, 2}, nil},
+		{"wrong order", []int{2, 0}, ErrEvalFalse},
+		{"same key twice", []int{1, 1}, ErrEvalFalse},
+		{"one signature", []int{1}, ErrStackUnderflow},
+	} {
+		signatures := make([]*utils.Signature, len(c.signer))
+		for i, k := range c.signer {
+			signatures[i] = sign(t, keys[k])
+		}
+		unlocking, _ := SignatureScript(signatures...)
+		if err := Execute(unlocking, locking, testContext()); !errors.Is(err, c.err) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.err, err)
+		}
+	}
+
+	tooMany, _ := NewBuilder().AddOp(OP_1).AddInt(MAX_MULTISIG_KEYS + 1).AddOp(OP_CHECKMULTISIG).Script()
+	if err := Execute(nil, tooMany, testContext()); !errors.Is(err, ErrInvalidMultisig) {
+		t.Fatalf("expected invalid multisig, got %v", err)
+	}
+}
+
+func TestCheckLockTime(t *testing.T) {
+	key := newKey(t)
+	unlocking, _ := SignatureScript(sign(t, key))
+	for _, c := range []struct {
+		name     string
+		lockTime int64
+		err      error
+	}{
+		{"height reached", 100, nil},
+		{"height not reached", 101, ErrLockTime},
+		{"time reached", 1700000000, nil},
+		{"time not reached", 1700000001, ErrLockTime},
+	} {
+		locking, _ := TimeLockScript(c.lockTime, &key.PublicKey)
+		if err := Execute(unlocking, locking, testContext()); !errors.Is(err, c.err) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.err, err)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	big := make([]byte, MAX_ELEMENT_SIZE+1)
+	if _, err := NewBuilder().AddData(big).Script(); !errors.Is(err, ErrElementTooBig) {
+		t.Errorf("builder: expected element too big, got %v", err)
+	}
+	push := append([]byte{OP_PUSHDATA1, byte(len(big))}, big...)
+	if err := Execute(push, []byte{OP_1}, testContext()); !errors.Is(err, ErrNotPushOnly) {
+		t.Errorf("parse: expected rejection, got %v", err)
+	}
+	if _, err := parse(push); !errors.Is(err, ErrElementTooBig) {
+		t.Errorf("parse: expected element too big, got %v", err)
+	}
+	if _, err := parse([]byte{OP_PUSHDATA1, 10, 1}); !errors.Is(err, ErrMalformedPush) {
+		t.Errorf("expected malformed push, got %v", err)
+	}
+
+	if err := Execute(repeat(OP_1, MAX_SCRIPT_SIZE+1), []byte{OP_1}, testContext()); !errors.Is(err, ErrNotPushOnly) {
+		t.Errorf("script size: expected rejection, got %v", err)
+	}
+	if err := Execute(nil, repeat(OP_1, MAX_SCRIPT_SIZE+1), testContext()); !errors.Is(err, ErrScriptTooBig) {
+		t.Errorf("expected script too big, got %v", err)
+	}
+	if err := Execute(repeat(OP_1, MAX_STACK_SIZE+1), []byte{OP_1}, testContext()); !errors.Is(err, ErrStackOverflow) {
+		t.Errorf("expected stack overflow, got %v", err)
+	}
+
+	// OP_DUP OP_DROP 쌍은 stack을 늘리지 않고 operation만 센다
+	ops := append([]byte{OP_1}, bytes.Repeat([]byte{OP_DUP, OP_DROP}, MAX_OPS/2)...)
+	if err := Execute(nil, ops, testContext()); err != nil {
+		t.Errorf("MAX_OPS operations rejected: %v", err)
+	}
+	ops = append(ops, OP_DUP)
+	if err := Execute(nil, ops, testContext()); !errors.Is(err, ErrTooManyOps) {
+		t.Errorf("expected too many ops, got %v", err)
+	}
+
+	number := append([]byte{OP_PUSHDATA1, MAX_NUMBER_SIZE + 1}, make([]byte, MAX_NUMBER_SIZE+1)...)
+	if err := Execute(number, []byte{OP_CHECKLOCKTIMEVERIFY}, testContext()); !errors.Is(err, ErrInvalidNumber) {
+		t.Errorf("expected invalid number, got %v", err)
+	}
+
+	if err := Execute([]byte{OP_1}, []byte{0xff}, testContext()); !errors.Is(err, ErrUnknownOpcode) {
+		t.Errorf("expected unknown opcode, got %v", err)
+	}
+	if err := Execute([]byte{OP_1, OP_DUP}, []byte{OP_EQUAL}, testContext()); !errors.Is(err, ErrNotPushOnly) {
+		t.Errorf("expected push only, got %v", err)
+	}
+}
+
+func TestAssembleRoundTrip(t *testing.T) {
+	key := newKey(t)
+	locking, _ := HTLCScript(sha256.Sum256([]byte("secret")), &key.PublicKey, &key.PublicKey, 500)
+	asm, err := Disassemble(locking)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := mustAssemble(t, asm); !bytes.Equal(got, locking) {
+		t.Fatalf("round trip changed script: %s", asm)
+	}
+	if h, ok := ParseHTLC(locking); !ok || h.Timeout != 500 {
+		t.Fatalf("unexpected htlc %+v", h)
+	}
+}
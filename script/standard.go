@@ -0,0 +1,74 @@
+package script
+
+import (
+	"crypto/ecdsa"
+
+	"github.com/sw90lee/blockchain_study/utils"
+)
+
+func PublicKeyBytes(publicKey *ecdsa.PublicKey) []byte {
+	b := make([]byte, 64)
+	publicKey.X.FillBytes(b[:32])
+	publicKey.Y.FillBytes(b[32:])
+	return b
+}
+
+func SignatureBytes(s *utils.Signature) []byte {
+	b := make([]byte, 64)
+	s.R.FillBytes(b[:32])
+	s.S.FillBytes(b[32:])
+	return b
+}
+
+// <pub> OP_CHECKSIG
+func PayToPublicKey(publicKey *ecdsa.PublicKey) ([]byte, error) {
+	return NewBuilder().
+		AddData(PublicKeyBytes(publicKey)).
+		AddOp(OP_CHECKSIG).
+		Script()
+}
+
+// M <pub1> ... <pubN> N OP_CHECKMULTISIG
+func MultisigScript(required int, publicKeys []*ecdsa.PublicKey) ([]byte, error) {
+	b := NewBuilder().AddInt(int64(required))
+	for _, publicKey := range publicKeys {
+		b.AddData(PublicKeyBytes(publicKey))
+	}
+	return b.AddInt(int64(len(publicKeys))).
+		AddOp(OP_CHECKMULTISIG).
+		Script()
+}
+
+// <locktime> OP_CHECKLOCKTIMEVERIFY OP_DROP <pub> OP_CHECKSIG
+func TimeLockScript(lockTime int64, publicKey *ecdsa.PublicKey) ([]byte, error) {
+	return NewBuilder().
+		AddInt(lockTime).
+		AddOp(OP_CHECKLOCKTIMEVERIFY).
+		AddOp(OP_DROP).
+		AddData(PublicKeyBytes(publicKey)).
+		AddOp(OP_CHECKSIG).
+		Script()
+}
+
+// OP_SHA256 <hash> OP_EQUAL
+func HashLockScript(hash [32]byte) ([]byte, error) {
+	return NewBuilder().
+		AddOp(OP_SHA256).
+		AddData(hash[:]).
+		AddOp(OP_EQUAL).
+		Script()
+}
+
+// 서명들을 순서대로 push 하는 unlocking script
+func SignatureScript(signatures ...*utils.Signature) ([]byte, error) {
+	b := NewBuilder()
+	for _, s := range signatures {
+		b.AddData(SignatureBytes(s))
+	}
+	return b.Script()
+}
+
+// locking script의 hash로 만든 주소
+func Address(lockingScript []byte) string {
+	return utils.ScriptAddress(lockingScript)
+}
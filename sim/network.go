@@ -0,0 +1,303 @@
+// Package sim 은 여러 Blockchain 노드를 한 process 안에서 실행하는 시뮬레이터이다.
+//
+// 노드끼리의 HTTP 요청은 socket 대신 메모리 안의 transport로 전달되고,
+// 연결마다 지연과 손실을, 네트워크 전체에 분할을 정할 수 있다.
+// 응답을 쓰지 않는 알림(PUT, DELETE)은 가짜 시계에 걸어 두었다가 지연이 지나면
+// 도착하므로, 시계를 돌리기 전까지는 전송 중이고 지연에 따라 순서가 바뀐다.
+// 응답이 필요한 GET은 지연만큼 시계를 돌린 뒤 바로 답한다.
+// 손실과 jitter는 seed로 만든 난수를 쓰므로 같은 순서로 호출하면 같은 결과가 나온다.
+package sim
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/sw90lee/blockchain_study/block"
+	"github.com/sw90lee/blockchain_study/clock"
+)
+
+// 가짜 시계의 시작 시각
+var START_TIME = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Settle이 전송 중인 알림을 기다리는 최대 시간
+const SETTLE_TIMEOUT = time.Hour
+
+var (
+	ErrUnreachable = errors.New("node unreachable")
+	ErrDropped     = errors.New("message dropped")
+)
+
+// from에서 to로 가는 요청에 적용되는 조건
+type Link struct {
+	Latency time.Duration
+	// 알림마다 0 이상 Jitter 미만의 지연을 더 붙인다
+	Jitter   time.Duration
+	DropRate float64
+}
+
+type Stats struct {
+	Delivered int
+	Dropped   int
+	// 멈춘 노드나 분할된 노드로 보내서 전달되지 않은 요청, 전송 중에 끊긴 알림도 센다
+	Unreachable int
+	// 보냈지만 아직 도착하지 않은 알림
+	InFlight int
+}
+
+type Network struct {
+	clock  *clock.Fake
+	rand   *rand.Rand
+	params block.Params
+
+	nodes []*Node
+	// 노드 이름별 분할 그룹, 비어 있으면 분할되지 않은 상태
+	groups      map[string]int
+	defaultLink Link
+	links       map[[2]string]Link
+	stats       Stats
+	// 전송 중인 알림 중 가장 늦게 도착하는 시각
+	lastArrival time.Time
+	mux         sync.Mutex
+}
+
+// 서로 이웃인 n개의 노드, 이름은 node-0부터
+func NewNetwork(n int, seed int64) *Network {
+	nw := &Network{
+		clock:  clock.NewFake(START_TIME),
+		rand:   rand.New(rand.NewSource(seed)),
+		params: block.DefaultParams(),
+		groups: make(map[string]int),
+		links:  make(map[[2]string]Link),
+	}
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("node-%d", i)
+	}
+	for i, name := range names {
+		node := &Node{name: name, port: uint16(5000 + i), network: nw}
+		for _, neighbor := range names {
+			if neighbor != name {
+				node.neighbors = append(node.neighbors, neighbor)
+			}
+		}
+		node.handler = node.newHandler()
+		node.reset()
+		nw.nodes = append(nw.nodes, node)
+	}
+	return nw
+}
+
+// 모든 노드가 함께 쓰는 시계, 지연과 mining timer가 이 시계로 흐른다
+func (nw *Network) Clock() *clock.Fake {
+	return nw.clock
+}
+
+// 모든 노드에 적용하고, 다시 시작하는 노드에도 적용한다
+func (nw *Network) SetParams(p block.Params) {
+	nw.params = p
+	for _, node := range nw.nodes {
+		node.bc.SetParams(p)
+	}
+}
+
+func (nw *Network) Node(i int) *Node {
+	return nw.nodes[i]
+}
+
+func (nw *Network) Nodes() []*Node {
+	return nw.nodes
+}
+
+func (nw *Network) find(name string) *Node {
+	for _, node := range nw.nodes {
+		if node.name == name {
+			return node
+		}
+	}
+	return nil
+}
+
+// SetLinkBetween으로 따로 정하지 않은 모든 연결
+func (nw *Network) SetLink(link Link) {
+	nw.mux.Lock()
+	defer nw.mux.Unlock()
+	nw.defaultLink = link
+}
+
+// from에서 to 방향만 바꾼다
+func (nw *Network) SetLinkBetween(from string, to string, link Link) {
+	nw.mux.Lock()
+	defer nw.mux.Unlock()
+	nw.links[[2]string{from, to}] = link
+}
+
+// 같은 그룹 안의 노드끼리만 통신한다, 어느 그룹에도 없는 노드는 모두와 끊긴다
+func (nw *Network) Partition(groups ...[]string) {
+	nw.mux.Lock()
+	defer nw.mux.Unlock()
+	nw.groups = make(map[string]int)
+	for i, group := range groups {
+		for _, name := range group {
+			nw.groups[name] = i + 1
+		}
+	}
+	for _, node := range nw.nodes {
+		if nw.groups[node.name] == 0 {
+			nw.groups[node.name] = -1 - len(nw.groups)
+		}
+	}
+}
+
+func (nw *Network) Heal() {
+	nw.mux.Lock()
+	defer nw.mux.Unlock()
+	nw.groups = make(map[string]int)
+}
+
+func (nw *Network) Stats() Stats {
+	nw.mux.Lock()
+	defer nw.mux.Unlock()
+	return nw.stats
+}
+
+// 실행 중인 노드의 마지막 Block이 모두 같은지
+func (nw *Network) Converged() bool {
+	var tip [32]byte
+	first := true
+	for _, node := range nw.nodes {
+		if !node.up {
+			continue
+		}
+		hash := node.bc.LastBlock().Hash()
+		if !first && hash != tip {
+			return false
+		}
+		tip, first = hash, false
+	}
+	return true
+}
+
+// from에서 to로 보낼 수 있으면 그 연결의 조건, lock을 잡고 부른다
+func (nw *Network) route(from string, to string) (*Node, Link, bool) {
+	sender, receiver := nw.find(from), nw.find(to)
+	if sender == nil || receiver == nil || !sender.up || !receiver.up ||
+		(len(nw.groups) > 0 && nw.groups[from] != nw.groups[receiver.name]) {
+		return nil, Link{}, false
+	}
+	link, ok := nw.links[[2]string{from, receiver.name}]
+	if !ok {
+		link = nw.defaultLink
+	}
+	return receiver, link, true
+}
+
+// 받는 노드의 handler를 호출하는 동안 lock을 잡지 않는다
+// handler가 다시 다른 노드에 요청할 수 있기 때문이다
+func (nw *Network) deliver(from string, req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+	nw.mux.Lock()
+	receiver, link, ok := nw.route(from, req.URL.Host)
+	if !ok {
+		nw.stats.Unreachable++
+		nw.mux.Unlock()
+		return nil, ErrUnreachable
+	}
+	if link.DropRate > 0 && nw.rand.Float64() < link.DropRate {
+		nw.stats.Dropped++
+		nw.mux.Unlock()
+		return nil, ErrDropped
+	}
+
+	if req.Method == http.MethodGet {
+		nw.stats.Delivered++
+		nw.mux.Unlock()
+		nw.clock.Advance(link.Latency)
+		return serve(receiver, req), nil
+	}
+
+	delay := link.Latency
+	if link.Jitter > 0 {
+		delay += time.Duration(nw.rand.Int63n(int64(link.Jitter)))
+	}
+	nw.stats.InFlight++
+	if arrival := nw.clock.Now().Add(delay); arrival.After(nw.lastArrival) {
+		nw.lastArrival = arrival
+	}
+	nw.mux.Unlock()
+
+	// 보낸 쪽이 body를 닫기 전에 읽어 두고, 도착했을 때 새 요청으로 만든다
+	var body []byte
+	if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+	}
+	nw.clock.AfterFunc(delay, func() {
+		nw.mux.Lock()
+		nw.stats.InFlight--
+		receiver, _, ok := nw.route(from, req.URL.Host)
+		if !ok {
+			nw.stats.Unreachable++
+			nw.mux.Unlock()
+			return
+		}
+		nw.stats.Delivered++
+		nw.mux.Unlock()
+		arrived := req.Clone(req.Context())
+		arrived.Body = io.NopCloser(bytes.NewReader(body))
+		serve(receiver, arrived)
+	})
+	return &http.Response{
+		Status:     "202 Accepted",
+		StatusCode: http.StatusAccepted,
+		Header:     make(http.Header),
+		Body:       http.NoBody,
+		Request:    req,
+	}, nil
+}
+
+func serve(receiver *Node, req *http.Request) *http.Response {
+	recorder := httptest.NewRecorder()
+	receiver.handler.ServeHTTP(recorder, req)
+	resp := recorder.Result()
+	resp.Request = req
+	return resp
+}
+
+// 전송 중인 알림이 모두 도착할 때까지 시계를 돌린다, 도착한 알림이 다시 알림을 보내도 기다린다
+func (nw *Network) Settle() bool {
+	deadline := nw.clock.Now().Add(SETTLE_TIMEOUT)
+	for {
+		nw.mux.Lock()
+		inFlight, lastArrival := nw.stats.InFlight, nw.lastArrival
+		nw.mux.Unlock()
+		if inFlight == 0 {
+			return true
+		}
+		now := nw.clock.Now()
+		if now.After(deadline) {
+			return false
+		}
+		if lastArrival.Before(now) {
+			lastArrival = now
+		}
+		nw.clock.Advance(lastArrival.Sub(now))
+	}
+}
+
+// 한 노드가 보내는 요청을 Network로 넘긴다
+type transport struct {
+	network *Network
+	from    string
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.network.deliver(t.from, req)
+}
@@ -0,0 +1,208 @@
+package sim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sw90lee/blockchain_study/block"
+	"github.com/sw90lee/blockchain_study/wallet"
+)
+
+func newNetwork(n int) *Network {
+	nw := NewNetwork(n, 1)
+	p := block.DefaultParams()
+	p.MiningDifficulty = 1
+	nw.SetParams(p)
+	return nw
+}
+
+// node에서 서명한 Transaction을 만들어 이웃에게 보내고 보낸 주소를 돌려준다
+func send(t *testing.T, node *Node, value float32) string {
+	w := wallet.NewWallet()
+	wt := wallet.NewTransaction(w.PrivateKey(), w.PublicKey(), w.BlockchainAddress(), "bob", value)
+	bt := block.NewTransaction(w.BlockchainAddress(), "bob", value)
+	if !node.Blockchain().CreateTransaction(bt, w.PublicKey(), wt.GenerateSignature()) {
+		t.Fatal("transaction rejected")
+	}
+	return w.BlockchainAddress()
+}
+
+func settle(t *testing.T, nw *Network) {
+	if !nw.Settle() {
+		t.Fatal("messages still in flight")
+	}
+}
+
+func poolSizes(nw *Network) []int {
+	sizes := []int{}
+	for _, node := range nw.Nodes() {
+		sizes = append(sizes, len(node.Blockchain().TransactionPool()))
+	}
+	return sizes
+}
+
+func TestPartition(t *testing.T) {
+	nw := newNetwork(3)
+	nw.Partition([]string{"node-0", "node-1"}, []string{"node-2"})
+	send(t, nw.Node(0), 1)
+	settle(t, nw)
+	if sizes := poolSizes(nw); sizes[0] != 1 || sizes[1] != 1 || sizes[2] != 0 {
+		t.Fatalf("unexpected pools %v", sizes)
+	}
+	if s := nw.Stats(); s.Delivered != 1 || s.Unreachable != 1 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+
+	nw.Heal()
+	send(t, nw.Node(0), 2)
+	settle(t, nw)
+	if sizes := poolSizes(nw); sizes[1] != 2 || sizes[2] != 1 {
+		t.Fatalf("unexpected pools %v", sizes)
+	}
+}
+
+func TestLatencyAndDrops(t *testing.T) {
+	nw := newNetwork(3)
+	nw.SetLink(Link{Latency: 100 * time.Millisecond})
+	nw.SetLinkBetween("node-0", "node-2", Link{DropRate: 1})
+	send(t, nw.Node(0), 1)
+	if s := nw.Stats(); s.InFlight != 1 || s.Delivered != 0 || len(nw.Node(1).Blockchain().TransactionPool()) != 0 {
+		t.Fatalf("delivered before latency, stats %+v", s)
+	}
+	settle(t, nw)
+	if sizes := poolSizes(nw); sizes[1] != 1 || sizes[2] != 0 {
+		t.Fatalf("unexpected pools %v", sizes)
+	}
+	if s := nw.Stats(); s.Delivered != 1 || s.Dropped != 1 || s.InFlight != 0 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+	if elapsed := nw.Clock().Now().Sub(START_TIME); elapsed != 100*time.Millisecond {
+		t.Fatalf("clock advanced %v", elapsed)
+	}
+}
+
+// 같은 seed면 같은 요청이 버려진다
+func TestDropsAreReproducible(t *testing.T) {
+	run := func() []int {
+		nw := newNetwork(4)
+		nw.SetLink(Link{DropRate: 0.5})
+		for i := 0; i < 5; i++ {
+			send(t, nw.Node(0), float32(i+1))
+		}
+		settle(t, nw)
+		return poolSizes(nw)
+	}
+	first, second := run(), run()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("%v != %v", first, second)
+		}
+	}
+}
+
+func TestStopAndRestart(t *testing.T) {
+	nw := newNetwork(3)
+	nw.Node(2).Stop()
+	send(t, nw.Node(0), 1)
+	settle(t, nw)
+	if sizes := poolSizes(nw); sizes[1] != 1 || sizes[2] != 0 {
+		t.Fatalf("unexpected pools %v", sizes)
+	}
+
+	// 같은 시계로 만든 genesis Block은 모든 노드에서 같다
+	if !nw.Converged() {
+		t.Fatal("genesis blocks differ")
+	}
+
+	nw.Node(0).Mine()
+	settle(t, nw)
+	if !nw.Node(2).Restart() || !nw.Converged() {
+		t.Fatal("restarted node did not catch up")
+	}
+}
+
+func TestForkConverges(t *testing.T) {
+	nw := newNetwork(3)
+	nw.Partition([]string{"node-0", "node-1"}, []string{"node-2"})
+	send(t, nw.Node(0), 1)
+	nw.Node(0).Mine()
+	send(t, nw.Node(0), 1)
+	nw.Node(0).Mine()
+	send(t, nw.Node(2), 1)
+	nw.Node(2).Mine()
+	settle(t, nw)
+	if nw.Converged() {
+		t.Fatal("partitions converged")
+	}
+
+	nw.Heal()
+	if !nw.Node(2).Blockchain().ResolveConflicts() || !nw.Converged() {
+		t.Fatal("fork not resolved")
+	}
+	if len(nw.Node(2).Blockchain().Chain()) != 3 {
+		t.Fatalf("node-2 has %d blocks", len(nw.Node(2).Blockchain().Chain()))
+	}
+}
+
+// 늦게 보낸 알림이 지연이 짧으면 먼저 도착한다
+func TestReorderedDelivery(t *testing.T) {
+	nw := newNetwork(2)
+	nw.SetLink(Link{Latency: 300 * time.Millisecond})
+	first := send(t, nw.Node(0), 1)
+	nw.SetLink(Link{Latency: 100 * time.Millisecond})
+	second := send(t, nw.Node(0), 2)
+	if s := nw.Stats(); s.InFlight != 2 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+
+	nw.Clock().Advance(200 * time.Millisecond)
+	pool := nw.Node(1).Blockchain().TransactionPool()
+	if len(pool) != 1 || pool[0].Sender() != second {
+		t.Fatalf("unexpected pool %v", pool)
+	}
+	settle(t, nw)
+	pool = nw.Node(1).Blockchain().TransactionPool()
+	if len(pool) != 2 || pool[1].Sender() != first {
+		t.Fatalf("unexpected pool %v", pool)
+	}
+
+	// 전송 중에 멈춘 노드에게는 도착하지 않는다
+	send(t, nw.Node(0), 3)
+	nw.Node(1).Stop()
+	settle(t, nw)
+	if s := nw.Stats(); s.Unreachable != 1 || s.Delivered != 2 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
+
+// jitter도 seed로 정해지므로 같은 seed면 같은 순서로 도착한다
+func TestJitterIsReproducible(t *testing.T) {
+	run := func() []int {
+		nw := newNetwork(2)
+		nw.SetLink(Link{Latency: 10 * time.Millisecond, Jitter: time.Second})
+		index := make(map[string]int)
+		for i := 0; i < 5; i++ {
+			index[send(t, nw.Node(0), float32(i+1))] = i
+		}
+		settle(t, nw)
+		order := []int{}
+		for _, tx := range nw.Node(1).Blockchain().TransactionPool() {
+			order = append(order, index[tx.Sender()])
+		}
+		return order
+	}
+	first, second := run(), run()
+	if len(first) != 5 || len(second) != 5 {
+		t.Fatalf("unexpected pools %v %v", first, second)
+	}
+	reordered := false
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("%v != %v", first, second)
+		}
+		reordered = reordered || first[i] != i
+	}
+	if !reordered {
+		t.Fatalf("jitter did not reorder %v", first)
+	}
+}
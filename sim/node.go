@@ -0,0 +1,179 @@
+package sim
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/sw90lee/blockchain_study/block"
+	"github.com/sw90lee/blockchain_study/utils"
+)
+
+// 시뮬레이터 안의 노드 하나, 이름이 host 역할을 하고 채굴 보상도 이름으로 받는다
+type Node struct {
+	name      string
+	port      uint16
+	neighbors []string
+	network   *Network
+	bc        *block.Blockchain
+	handler   http.Handler
+	up        bool
+}
+
+func (n *Node) Name() string {
+	return n.name
+}
+
+func (n *Node) Blockchain() *block.Blockchain {
+	return n.bc
+}
+
+func (n *Node) Up() bool {
+	return n.up
+}
+
+func (n *Node) Mine() bool {
+	return n.bc.Mining()
+}
+
+// 멈춘 노드는 요청을 보내지도 받지도 않는다
+func (n *Node) Stop() {
+	n.network.mux.Lock()
+	defer n.network.mux.Unlock()
+	n.up = false
+}
+
+func (n *Node) Start() {
+	n.network.mux.Lock()
+	defer n.network.mux.Unlock()
+	n.up = true
+}
+
+// blockchain_server처럼 chain을 메모리에만 두므로 genesis부터 새로 시작해서 이웃에게 받는다
+func (n *Node) Restart() bool {
+	n.network.mux.Lock()
+	n.reset()
+	n.network.mux.Unlock()
+	return n.bc.ResolveConflicts()
+}
+
+func (n *Node) reset() {
+	bc := block.NewBlockchainWithClock(n.name, n.port, n.network.clock)
+	bc.SetParams(n.network.params)
+	bc.UseNeighbors(n.neighbors)
+	bc.SetHTTPClient(&http.Client{Transport: &transport{network: n.network, from: n.name}})
+	n.bc = bc
+	n.up = true
+}
+
+// 노드끼리 호출하는 blockchain_server의 endpoint
+func (n *Node) newHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", n.getChain)
+	mux.HandleFunc("/transactions", n.transactions)
+	mux.HandleFunc("/consensus", n.consensus)
+	mux.HandleFunc("/status", n.status)
+	mux.HandleFunc("/snapshot", n.snapshot)
+	mux.HandleFunc("/headers", n.headers)
+	mux.HandleFunc("/blocks", n.blocks)
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	m, _ := json.Marshal(v)
+	w.Header().Add("Content-Type", "application/json")
+	io.WriteString(w, string(m[:]))
+}
+
+func (n *Node) getChain(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		writeJSON(w, n.bc)
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (n *Node) transactions(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPut:
+		var t block.TransactionRequest
+		if err := json.NewDecoder(req.Body).Decode(&t); err != nil || !t.Validate() {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var isUpdated bool
+		if t.IsScript() {
+			lockingScript, unlockingScript, ok := t.Scripts()
+			isUpdated = ok && n.bc.AddScriptTransaction(t.Transaction(), lockingScript, unlockingScript)
+		} else if t.IsMultisig() {
+			publicKeys, signatures, ok := t.MultisigKeys()
+			isUpdated = ok && n.bc.AddMultisigTransaction(t.Transaction(),
+				*t.MultisigRequired, publicKeys, signatures)
+		} else {
+			publicKey := utils.PublicKeyFromString(*t.SenderPublicKey)
+			signature := utils.SignatureFromString(*t.Signature)
+			isUpdated = n.bc.AddTransaction(t.Transaction(), publicKey, signature)
+		}
+		if !isUpdated {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, string(utils.JsonStatus("success")))
+	case http.MethodDelete:
+		n.bc.ClearTransactionPool()
+		io.WriteString(w, string(utils.JsonStatus("success")))
+	default:
+		log.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (n *Node) consensus(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPut:
+		if n.bc.ResolveConflicts() {
+			io.WriteString(w, string(utils.JsonStatus("success")))
+		} else {
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+		}
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (n *Node) status(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, n.bc.Status())
+}
+
+func (n *Node) snapshot(w http.ResponseWriter, req *http.Request) {
+	snapshot := n.bc.Snapshot()
+	if snapshot == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	writeJSON(w, snapshot)
+}
+
+func (n *Node) headers(w http.ResponseWriter, req *http.Request) {
+	from, _ := strconv.Atoi(req.URL.Query().Get("from"))
+	writeJSON(w, n.bc.HeadersFrom(from))
+}
+
+func (n *Node) blocks(w http.ResponseWriter, req *http.Request) {
+	from, err := strconv.Atoi(req.URL.Query().Get("from"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	blocks, ok := n.bc.BlocksFrom(from)
+	if !ok {
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+	writeJSON(w, blocks)
+}
@@ -0,0 +1,178 @@
+// Package spv는 Block header만 받아서 자기 주소의 Transaction이
+// chain에 들어갔는지 Merkle proof로 확인하는 light client이다.
+package spv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/sw90lee/blockchain_study/block"
+)
+
+var ErrInvalidHeaders = errors.New("invalid header chain")
+
+// header를 받아 검증한 결과에 포함된 Transaction
+type Payment struct {
+	Height        int                `json:"height"`
+	BlockHash     string             `json:"block_hash"`
+	Confirmations int                `json:"confirmations"`
+	Transaction   *block.Transaction `json:"transaction"`
+}
+
+type Client struct {
+	node       string
+	consensus  string
+	difficulty int
+	headers    []*block.Block
+	addresses  []string
+	// node에 올린 Bloom filter
+	filterId string
+}
+
+// node는 blockchain_server의 host:port
+func NewClient(node string, consensus string) *Client {
+	return &Client{node: node, consensus: consensus, difficulty: block.MINING_DIFFICULTY}
+}
+
+// node가 기본값과 다른 mining difficulty로 실행 중일 때
+func (c *Client) SetDifficulty(difficulty int) {
+	c.difficulty = difficulty
+}
+
+func (c *Client) AddAddress(address string) {
+	for _, a := range c.addresses {
+		if a == address {
+			return
+		}
+	}
+	c.addresses = append(c.addresses, address)
+}
+
+func (c *Client) Addresses() []string {
+	return c.addresses
+}
+
+func (c *Client) Headers() []*block.Block {
+	return c.headers
+}
+
+// 받은 header가 없으면 -1
+func (c *Client) Height() int {
+	return len(c.headers) - 1
+}
+
+// height 높이 Block 위에 쌓인 Block 수 + 1, 아직 받지 않은 높이면 0
+func (c *Client) Confirmations(height int) int {
+	if height < 0 || height >= len(c.headers) {
+		return 0
+	}
+	return len(c.headers) - height
+}
+
+// 새 header를 받아 이어 붙인다, 마지막 header와 이어지지 않으면 처음부터 다시 받는다
+func (c *Client) Sync() error {
+	from := len(c.headers)
+	var headers []*block.Block
+	if err := c.get(fmt.Sprintf("/headers?from=%d", from), &headers); err != nil {
+		return err
+	}
+	if len(headers) == 0 {
+		return nil
+	}
+
+	if from > 0 && headers[0].PreviousHash() == c.headers[from-1].Hash() {
+		if !block.ValidHeaderChain(c.consensus, c.difficulty, append([]*block.Block{c.headers[from-1]}, headers...)) {
+			return ErrInvalidHeaders
+		}
+		c.headers = append(c.headers, headers...)
+		log.Printf("action=spv_sync, height=%d", c.Height())
+		return nil
+	}
+
+	all := headers
+	if from > 0 {
+		all = nil
+		if err := c.get("/headers", &all); err != nil {
+			return err
+		}
+	}
+	if !block.ValidHeaderChain(c.consensus, c.difficulty, all) {
+		return ErrInvalidHeaders
+	}
+	if len(all) <= len(c.headers) {
+		return nil
+	}
+	log.Printf("action=spv_sync, height=%d, new_height=%d", c.Height(), len(all)-1)
+	c.headers = all
+	return nil
+}
+
+// header의 Merkle root로 Transaction이 그 Block에 들어 있는지 확인
+func (c *Client) Verify(p *block.TransactionProof) bool {
+	if p.Transaction == nil || p.Proof == nil || p.Height < 0 || p.Height >= len(c.headers) {
+		return false
+	}
+	header := c.headers[p.Height]
+	if fmt.Sprintf("%x", header.Hash()) != p.BlockHash {
+		return false
+	}
+	return p.Proof.Verify(p.Transaction.Hash(), header.MerkleRoot())
+}
+
+// 등록한 주소의 Transaction 중 받은 header로 확인된 것
+func (c *Client) Payments() ([]*Payment, error) {
+	var payments []*Payment
+	if len(c.headers) == 0 {
+		return payments, nil
+	}
+	seen := make(map[string]bool)
+	for _, address := range c.addresses {
+		from := 0
+		for {
+			var resp block.AddressProofsResponse
+			path := fmt.Sprintf("/addresses/%s/proofs?from_height=%d&to_height=%d",
+				url.PathEscape(address), from, c.Height())
+			if err := c.get(path, &resp); err != nil {
+				return nil, err
+			}
+			for _, p := range resp.Proofs {
+				if !c.Verify(p) {
+					log.Printf("ERROR: invalid proof for %s at block %d", address, p.Height)
+					continue
+				}
+				key := fmt.Sprintf("%d:%d", p.Height, p.Proof.Index())
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				payments = append(payments, &Payment{
+					Height:        p.Height,
+					BlockHash:     p.BlockHash,
+					Confirmations: c.Confirmations(p.Height),
+					Transaction:   p.Transaction,
+				})
+			}
+			if resp.NextHeight == 0 {
+				break
+			}
+			from = resp.NextHeight
+		}
+	}
+	return payments, nil
+}
+
+func (c *Client) get(path string, v interface{}) error {
+	resp, err := http.Get(fmt.Sprintf("http://%s%s", c.node, path))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
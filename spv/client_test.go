@@ -0,0 +1,155 @@
+package spv
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sw90lee/blockchain_study/block"
+	"github.com/sw90lee/blockchain_study/clock"
+	"github.com/sw90lee/blockchain_study/wallet"
+)
+
+// blockchain_server의 /headers와 /addresses/{address}/proofs만 흉내 낸다
+type node struct {
+	bc *block.Blockchain
+	// nil이 아니면 보내기 전에 응답 JSON을 바꾼다
+	tamper func(path string, v interface{})
+}
+
+func (n *node) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var v interface{}
+	switch {
+	case r.URL.Path == "/headers":
+		from, _ := strconv.Atoi(r.URL.Query().Get("from"))
+		v = n.bc.HeadersFrom(from)
+	case strings.HasPrefix(r.URL.Path, "/addresses/") && strings.HasSuffix(r.URL.Path, "/proofs"):
+		address := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/addresses/"), "/proofs")
+		proofs, next := n.bc.AddressProofs(address, 0, -1, block.ADDRESS_TRANSACTIONS_LIMIT)
+		v = &block.AddressProofsResponse{Address: address, Proofs: proofs, NextHeight: next}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	m, _ := json.Marshal(v)
+	if n.tamper != nil {
+		var raw interface{}
+		json.Unmarshal(m, &raw)
+		n.tamper(r.URL.Path, raw)
+		m, _ = json.Marshal(raw)
+	}
+	io.WriteString(w, string(m[:]))
+}
+
+func newNode(t *testing.T) (*node, *Client) {
+	bc := block.NewBlockchainWithClock("miner", 0, clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+	p := block.DefaultParams()
+	p.MiningDifficulty = 1
+	bc.SetParams(p)
+	n := &node{bc: bc}
+	s := httptest.NewServer(n)
+	t.Cleanup(s.Close)
+
+	c := NewClient(strings.TrimPrefix(s.URL, "http://"), block.CONSENSUS_PROOF_OF_WORK)
+	c.SetDifficulty(1)
+	c.AddAddress("bob")
+	return n, c
+}
+
+// bob에게 value를 보내는 Transaction을 넣고 Block을 만든다
+func pay(t *testing.T, bc *block.Blockchain, value float32) {
+	w := wallet.NewWallet()
+	wt := wallet.NewTransaction(w.PrivateKey(), w.PublicKey(), w.BlockchainAddress(), "bob", value)
+	bt := block.NewTransaction(w.BlockchainAddress(), "bob", value)
+	if !bc.CreateTransaction(bt, w.PublicKey(), wt.GenerateSignature()) {
+		t.Fatal("transaction rejected")
+	}
+	if !bc.Mining() {
+		t.Fatal("mining failed")
+	}
+}
+
+func TestSyncAndPayments(t *testing.T) {
+	n, c := newNode(t)
+	pay(t, n.bc, 1)
+	if err := c.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Height() != 1 {
+		t.Fatalf("height %d", c.Height())
+	}
+
+	// 이어지는 header만 받아 붙인다
+	pay(t, n.bc, 2)
+	if err := c.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Height() != 2 || c.Headers()[2].Hash() != n.bc.LastBlock().Hash() {
+		t.Fatalf("unexpected headers at height %d", c.Height())
+	}
+
+	payments, err := c.Payments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payments) != 2 {
+		t.Fatalf("%d payments", len(payments))
+	}
+	for i, p := range payments {
+		if p.Height != i+1 || p.Confirmations != 2-i || p.Transaction.Recipient() != "bob" {
+			t.Fatalf("unexpected payment %+v", p)
+		}
+	}
+}
+
+// 중간 header를 바꾸면 다음 header의 previous hash와 이어지지 않는다
+func TestRejectAlteredHeader(t *testing.T) {
+	n, c := newNode(t)
+	pay(t, n.bc, 1)
+	pay(t, n.bc, 2)
+	n.tamper = func(path string, v interface{}) {
+		header := v.([]interface{})[1].(map[string]interface{})
+		header["nonce"] = header["nonce"].(float64) + 1
+	}
+	if err := c.Sync(); !errors.Is(err, ErrInvalidHeaders) {
+		t.Fatalf("expected invalid headers, got %v", err)
+	}
+	if c.Height() != -1 {
+		t.Fatalf("kept %d headers", len(c.Headers()))
+	}
+}
+
+// Transaction을 바꾸면 Merkle proof가 header의 root와 맞지 않는다
+func TestRejectAlteredProof(t *testing.T) {
+	n, c := newNode(t)
+	pay(t, n.bc, 1)
+	if err := c.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	tampered := 0
+	n.tamper = func(path string, v interface{}) {
+		if path == "/headers" {
+			return
+		}
+		for _, p := range v.(map[string]interface{})["proofs"].([]interface{}) {
+			p.(map[string]interface{})["transaction"].(map[string]interface{})["value"] = 100
+			tampered += 1
+		}
+	}
+	payments, err := c.Payments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tampered == 0 {
+		t.Fatal("no proofs served")
+	}
+	if len(payments) != 0 {
+		t.Fatalf("accepted altered proof %+v", payments[0])
+	}
+}
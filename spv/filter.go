@@ -0,0 +1,112 @@
+package spv
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/sw90lee/blockchain_study/block"
+	"github.com/sw90lee/blockchain_study/bloom"
+)
+
+// 주소 하나당 이 정도의 다른 주소도 filter에 걸리게 해서 어떤 주소가 client 것인지 숨긴다
+const DEFAULT_FALSE_POSITIVE_RATE = 0.01
+
+var ErrNoFilter = errors.New("no filter loaded")
+
+// 등록한 주소로 filter를 만들어 node에 올린다
+func (c *Client) LoadFilter(fpRate float64, tweak uint32) error {
+	f := bloom.New(len(c.addresses), fpRate, tweak)
+	for _, address := range c.addresses {
+		f.Add([]byte(address))
+	}
+	m, _ := json.Marshal(f)
+	resp, err := http.Post(fmt.Sprintf("http://%s/filter", c.node), "application/json", bytes.NewBuffer(m))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	var v struct {
+		Id string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return err
+	}
+	c.filterId = v.Id
+	return nil
+}
+
+func (c *Client) mine(t *block.Transaction) bool {
+	for _, address := range c.addresses {
+		if t.Sender() == address || t.Recipient() == address {
+			return true
+		}
+	}
+	return false
+}
+
+// filter에 걸린 Transaction 중 받은 header로 확인되고 실제로 내 주소인 것
+// from 높이부터 받은 header 끝까지 확인한다
+func (c *Client) FilteredPayments(from int) ([]*Payment, error) {
+	if c.filterId == "" {
+		return nil, ErrNoFilter
+	}
+	var payments []*Payment
+	for from < len(c.headers) {
+		var blocks []*block.FilteredBlock
+		if err := c.get(fmt.Sprintf("/filter/blocks?id=%s&from=%d", c.filterId, from), &blocks); err != nil {
+			return nil, err
+		}
+		if len(blocks) == 0 {
+			break
+		}
+		for _, fb := range blocks {
+			if fb.Height >= len(c.headers) {
+				return payments, nil
+			}
+			for _, p := range fb.Proofs {
+				if !c.Verify(p) {
+					log.Printf("ERROR: invalid proof at block %d", p.Height)
+					continue
+				}
+				if !c.mine(p.Transaction) {
+					continue
+				}
+				payments = append(payments, &Payment{
+					Height:        p.Height,
+					BlockHash:     p.BlockHash,
+					Confirmations: c.Confirmations(p.Height),
+					Transaction:   p.Transaction,
+				})
+			}
+			from = fb.Height + 1
+		}
+	}
+	return payments, nil
+}
+
+// 아직 Block에 들어가지 않은 내 Transaction, proof가 없으므로 확인되지 않은 것으로 다룬다
+func (c *Client) PendingTransactions() ([]*block.Transaction, error) {
+	if c.filterId == "" {
+		return nil, ErrNoFilter
+	}
+	var v struct {
+		Transactions []*block.Transaction `json:"transactions"`
+	}
+	if err := c.get(fmt.Sprintf("/filter/transactions?id=%s", c.filterId), &v); err != nil {
+		return nil, err
+	}
+	var transactions []*block.Transaction
+	for _, t := range v.Transactions {
+		if c.mine(t) {
+			transactions = append(transactions, t)
+		}
+	}
+	return transactions, nil
+}
@@ -0,0 +1,264 @@
+package token
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const MAX_METADATA_SIZE = 1024
+
+var (
+	ErrInvalidNFT   = errors.New("token: invalid nft")
+	ErrNFTExists    = errors.New("token: nft already minted")
+	ErrUnknownNFT   = errors.New("token: unknown nft")
+	ErrNotNFTOwner  = errors.New("token: sender is not the nft owner")
+	ErrNFTDuplicate = errors.New("token: nft transferred twice in one block")
+)
+
+// 만든 주소와 content hash로 NFT id 생성
+func NFTID(creator string, contentHash [32]byte) string {
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s:%x", creator, contentHash)))
+	return hex.EncodeToString(h[:])
+}
+
+// 발행 또는 이동 한 번의 기록
+type Provenance struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Height int    `json:"height"`
+}
+
+type NFT struct {
+	id          string
+	contentHash [32]byte
+	metadata    string
+	creator     string
+	owner       string
+	history     []*Provenance
+}
+
+func (n *NFT) Id() string {
+	return n.id
+}
+
+func (n *NFT) Owner() string {
+	return n.owner
+}
+
+func (n *NFT) Creator() string {
+	return n.creator
+}
+
+func (n *NFT) History() []*Provenance {
+	return n.history
+}
+
+func (n *NFT) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Id          string        `json:"id"`
+		ContentHash string        `json:"content_hash"`
+		Metadata    string        `json:"metadata"`
+		Creator     string        `json:"creator"`
+		Owner       string        `json:"owner"`
+		History     []*Provenance `json:"history"`
+	}{
+		Id:          n.id,
+		ContentHash: fmt.Sprintf("%x", n.contentHash),
+		Metadata:    n.metadata,
+		Creator:     n.creator,
+		Owner:       n.owner,
+		History:     n.history,
+	})
+}
+
+func (n *NFT) UnmarshalJSON(data []byte) error {
+	var contentHash string
+	v := &struct {
+		Id          *string        `json:"id"`
+		ContentHash *string        `json:"content_hash"`
+		Metadata    *string        `json:"metadata"`
+		Creator     *string        `json:"creator"`
+		Owner       *string        `json:"owner"`
+		History     *[]*Provenance `json:"history"`
+	}{
+		Id:          &n.id,
+		ContentHash: &contentHash,
+		Metadata:    &n.metadata,
+		Creator:     &n.creator,
+		Owner:       &n.owner,
+		History:     &n.history,
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	h, err := hex.DecodeString(contentHash)
+	if err != nil || len(h) != 32 {
+		return ErrInvalidNFT
+	}
+	copy(n.contentHash[:], h)
+	return nil
+}
+
+// Transaction에 실리는 NFT 발행(content hash, metadata) 또는 이동(id) 정보
+type NFTPayload struct {
+	id          string
+	contentHash [32]byte
+	metadata    string
+}
+
+func NewMintPayload(contentHash [32]byte, metadata string) *NFTPayload {
+	return &NFTPayload{contentHash: contentHash, metadata: metadata}
+}
+
+func NewNFTTransferPayload(id string) *NFTPayload {
+	return &NFTPayload{id: id}
+}
+
+func (p *NFTPayload) IsMint() bool {
+	return p.id == ""
+}
+
+func (p *NFTPayload) Id() string {
+	return p.id
+}
+
+// sender가 보낸 payload가 가리키는 NFT id
+func (p *NFTPayload) NFTId(sender string) string {
+	if p.IsMint() {
+		return NFTID(sender, p.contentHash)
+	}
+	return p.id
+}
+
+func (p *NFTPayload) Validate() error {
+	if p.IsMint() {
+		if p.contentHash == [32]byte{} || len(p.metadata) > MAX_METADATA_SIZE {
+			return ErrInvalidNFT
+		}
+		return nil
+	}
+	if b, err := hex.DecodeString(p.id); err != nil || len(b) != 32 ||
+		p.contentHash != [32]byte{} || p.metadata != "" {
+		return ErrInvalidNFT
+	}
+	return nil
+}
+
+func (p *NFTPayload) MarshalJSON() ([]byte, error) {
+	contentHash := ""
+	if p.contentHash != [32]byte{} {
+		contentHash = fmt.Sprintf("%x", p.contentHash)
+	}
+	return json.Marshal(struct {
+		Id          string `json:"id,omitempty"`
+		ContentHash string `json:"content_hash,omitempty"`
+		Metadata    string `json:"metadata,omitempty"`
+	}{
+		Id:          p.id,
+		ContentHash: contentHash,
+		Metadata:    p.metadata,
+	})
+}
+
+func (p *NFTPayload) UnmarshalJSON(data []byte) error {
+	var contentHash string
+	v := &struct {
+		Id          *string `json:"id"`
+		ContentHash *string `json:"content_hash"`
+		Metadata    *string `json:"metadata"`
+	}{
+		Id:          &p.id,
+		ContentHash: &contentHash,
+		Metadata:    &p.metadata,
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if contentHash != "" {
+		h, err := hex.DecodeString(contentHash)
+		if err != nil || len(h) != 32 {
+			return ErrInvalidNFT
+		}
+		copy(p.contentHash[:], h)
+	}
+	return nil
+}
+
+// NFT id별 현재 소유자와 이동 기록
+type NFTRegistry struct {
+	nfts map[string]*NFT
+}
+
+func NewNFTRegistry() *NFTRegistry {
+	return &NFTRegistry{nfts: make(map[string]*NFT)}
+}
+
+func (r *NFTRegistry) Copy() *NFTRegistry {
+	c := NewNFTRegistry()
+	for id, n := range r.nfts {
+		copied := *n
+		copied.history = append([]*Provenance(nil), n.history...)
+		c.nfts[id] = &copied
+	}
+	return c
+}
+
+func (r *NFTRegistry) NFT(id string) *NFT {
+	return r.nfts[id]
+}
+
+func (r *NFTRegistry) OwnedBy(owner string) []*NFT {
+	nfts := make([]*NFT, 0)
+	for _, n := range r.nfts {
+		if n.owner == owner {
+			nfts = append(nfts, n)
+		}
+	}
+	return nfts
+}
+
+// height 높이 Block의 payload를 적용, 오류가 나면 registry는 바뀌지 않는다
+func (r *NFTRegistry) Apply(sender string, recipient string, p *NFTPayload, height int) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	if p.IsMint() {
+		id := NFTID(sender, p.contentHash)
+		if r.nfts[id] != nil {
+			return ErrNFTExists
+		}
+		r.nfts[id] = &NFT{
+			id:          id,
+			contentHash: p.contentHash,
+			metadata:    p.metadata,
+			creator:     sender,
+			owner:       recipient,
+			history:     []*Provenance{{From: sender, To: recipient, Height: height}},
+		}
+		return nil
+	}
+
+	n := r.nfts[p.id]
+	if n == nil {
+		return ErrUnknownNFT
+	}
+	if n.owner != sender {
+		return ErrNotNFTOwner
+	}
+	n.owner = recipient
+	n.history = append(n.history, &Provenance{From: sender, To: recipient, Height: height})
+	return nil
+}
+
+func (r *NFTRegistry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.nfts)
+}
+
+func (r *NFTRegistry) UnmarshalJSON(data []byte) error {
+	r.nfts = make(map[string]*NFT)
+	return json.Unmarshal(data, &r.nfts)
+}
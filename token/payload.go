@@ -0,0 +1,82 @@
+package token
+
+import (
+	"encoding/json"
+)
+
+// Transaction에 실리는 token 발행/이동 정보
+type Payload struct {
+	symbol   string
+	decimals uint8
+	amount   uint64
+	issue    bool
+}
+
+func NewIssuePayload(symbol string, decimals uint8, amount uint64) *Payload {
+	return &Payload{symbol: symbol, decimals: decimals, amount: amount, issue: true}
+}
+
+func NewTransferPayload(symbol string, amount uint64) *Payload {
+	return &Payload{symbol: symbol, amount: amount}
+}
+
+func (p *Payload) Symbol() string {
+	return p.symbol
+}
+
+func (p *Payload) Decimals() uint8 {
+	return p.decimals
+}
+
+func (p *Payload) Amount() uint64 {
+	return p.amount
+}
+
+func (p *Payload) IsIssue() bool {
+	return p.issue
+}
+
+func (p *Payload) Validate() error {
+	if !ValidSymbol(p.symbol) {
+		return ErrInvalidSymbol
+	}
+	if p.decimals > MAX_DECIMALS || (!p.issue && p.decimals != 0) {
+		return ErrInvalidDecimals
+	}
+	if p.amount == 0 {
+		return ErrInvalidAmount
+	}
+	return nil
+}
+
+func (p *Payload) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Symbol   string `json:"symbol"`
+		Decimals uint8  `json:"decimals,omitempty"`
+		Amount   uint64 `json:"amount"`
+		Issue    bool   `json:"issue,omitempty"`
+	}{
+		Symbol:   p.symbol,
+		Decimals: p.decimals,
+		Amount:   p.amount,
+		Issue:    p.issue,
+	})
+}
+
+func (p *Payload) UnmarshalJSON(data []byte) error {
+	v := &struct {
+		Symbol   *string `json:"symbol"`
+		Decimals *uint8  `json:"decimals"`
+		Amount   *uint64 `json:"amount"`
+		Issue    *bool   `json:"issue"`
+	}{
+		Symbol:   &p.symbol,
+		Decimals: &p.decimals,
+		Amount:   &p.amount,
+		Issue:    &p.issue,
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+// Package token 은 기본 coin 옆에서 움직이는 사용자 발행 token을 구현한다.
+//
+// 발행(issue) Transaction 은 symbol, 소수 자릿수(decimals), 발행량을 정하고
+// 처음 발행한 주소(issuer)만 같은 symbol 을 추가로 발행할 수 있다.
+// 금액은 모두 소수점을 뺀 가장 작은 단위(uint64)로 다룬다.
+package token
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+const (
+	MAX_SYMBOL_LENGTH = 8
+	MAX_DECIMALS      = 18
+)
+
+var (
+	ErrInvalidSymbol   = errors.New("token: invalid symbol")
+	ErrInvalidDecimals = errors.New("token: invalid decimals")
+	ErrInvalidAmount   = errors.New("token: invalid amount")
+	ErrUnknownToken    = errors.New("token: unknown token")
+	ErrNotIssuer       = errors.New("token: sender is not the issuer")
+	ErrSupplyOverflow  = errors.New("token: supply overflow")
+	ErrNotEnough       = errors.New("token: not enough balance")
+)
+
+var symbolPattern = regexp.MustCompile(`^[A-Z][A-Z0-9]*$`)
+
+func ValidSymbol(symbol string) bool {
+	return len(symbol) <= MAX_SYMBOL_LENGTH && symbolPattern.MatchString(symbol)
+}
+
+type Token struct {
+	symbol   string
+	decimals uint8
+	issuer   string
+	supply   uint64
+}
+
+func (t *Token) Symbol() string {
+	return t.symbol
+}
+
+func (t *Token) Decimals() uint8 {
+	return t.decimals
+}
+
+func (t *Token) Issuer() string {
+	return t.issuer
+}
+
+func (t *Token) Supply() uint64 {
+	return t.supply
+}
+
+// 가장 작은 단위의 amount를 decimals 자리 소수 문자열로 변환
+func FormatAmount(amount uint64, decimals uint8) string {
+	s := fmt.Sprintf("%0*d", int(decimals)+1, amount)
+	if decimals == 0 {
+		return s
+	}
+	i := len(s) - int(decimals)
+	return s[:i] + "." + s[i:]
+}
+
+// "12.5" 같은 문자열을 가장 작은 단위의 amount로 변환
+func ParseAmount(s string, decimals uint8) (uint64, error) {
+	whole, frac := strings.TrimSpace(s), ""
+	if i := strings.Index(whole, "."); i >= 0 {
+		whole, frac = whole[:i], whole[i+1:]
+	}
+	if len(frac) > int(decimals) || (whole == "" && frac == "") {
+		return 0, ErrInvalidAmount
+	}
+	digits := whole + frac + strings.Repeat("0", int(decimals)-len(frac))
+	n, ok := new(big.Int).SetString(digits, 10)
+	if !ok || n.Sign() < 0 || !n.IsUint64() {
+		return 0, ErrInvalidAmount
+	}
+	return n.Uint64(), nil
+}
+
+// chain의 token 발행/이동을 순서대로 적용한 결과
+type Ledger struct {
+	tokens   map[string]*Token
+	balances map[string]map[string]uint64
+}
+
+func NewLedger() *Ledger {
+	return &Ledger{
+		tokens:   make(map[string]*Token),
+		balances: make(map[string]map[string]uint64),
+	}
+}
+
+func (l *Ledger) Copy() *Ledger {
+	c := NewLedger()
+	for symbol, t := range l.tokens {
+		copied := *t
+		c.tokens[symbol] = &copied
+	}
+	for symbol, balances := range l.balances {
+		c.balances[symbol] = make(map[string]uint64, len(balances))
+		for address, amount := range balances {
+			c.balances[symbol][address] = amount
+		}
+	}
+	return c
+}
+
+func (l *Ledger) Token(symbol string) *Token {
+	return l.tokens[symbol]
+}
+
+func (l *Ledger) Tokens() map[string]*Token {
+	return l.tokens
+}
+
+func (l *Ledger) BalanceOf(symbol string, address string) uint64 {
+	return l.balances[symbol][address]
+}
+
+// address가 가진 token별 잔액
+func (l *Ledger) Balances(address string) map[string]uint64 {
+	balances := make(map[string]uint64)
+	for symbol, b := range l.balances {
+		if amount := b[address]; amount > 0 {
+			balances[symbol] = amount
+		}
+	}
+	return balances
+}
+
+// payload를 적용, 오류가 나면 ledger는 바뀌지 않는다
+func (l *Ledger) Apply(sender string, recipient string, p *Payload) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	t := l.tokens[p.symbol]
+	if p.issue {
+		if t == nil {
+			t = &Token{symbol: p.symbol, decimals: p.decimals, issuer: sender}
+			l.tokens[p.symbol] = t
+			l.balances[p.symbol] = make(map[string]uint64)
+		} else if t.issuer != sender {
+			return ErrNotIssuer
+		} else if t.decimals != p.decimals {
+			return ErrInvalidDecimals
+		}
+		if t.supply > math.MaxUint64-p.amount {
+			return ErrSupplyOverflow
+		}
+		t.supply += p.amount
+		l.balances[p.symbol][recipient] += p.amount
+		return nil
+	}
+
+	if t == nil {
+		return ErrUnknownToken
+	}
+	balances := l.balances[p.symbol]
+	if balances[sender] < p.amount {
+		return ErrNotEnough
+	}
+	balances[sender] -= p.amount
+	if balances[sender] == 0 {
+		delete(balances, sender)
+	}
+	balances[recipient] += p.amount
+	return nil
+}
+
+func (t *Token) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Symbol   string `json:"symbol"`
+		Decimals uint8  `json:"decimals"`
+		Issuer   string `json:"issuer"`
+		Supply   uint64 `json:"supply"`
+	}{
+		Symbol:   t.symbol,
+		Decimals: t.decimals,
+		Issuer:   t.issuer,
+		Supply:   t.supply,
+	})
+}
+
+func (t *Token) UnmarshalJSON(data []byte) error {
+	v := &struct {
+		Symbol   *string `json:"symbol"`
+		Decimals *uint8  `json:"decimals"`
+		Issuer   *string `json:"issuer"`
+		Supply   *uint64 `json:"supply"`
+	}{
+		Symbol:   &t.symbol,
+		Decimals: &t.decimals,
+		Issuer:   &t.issuer,
+		Supply:   &t.supply,
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (l *Ledger) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Tokens   map[string]*Token            `json:"tokens"`
+		Balances map[string]map[string]uint64 `json:"balances"`
+	}{
+		Tokens:   l.tokens,
+		Balances: l.balances,
+	})
+}
+
+func (l *Ledger) UnmarshalJSON(data []byte) error {
+	v := &struct {
+		Tokens   *map[string]*Token            `json:"tokens"`
+		Balances *map[string]map[string]uint64 `json:"balances"`
+	}{
+		Tokens:   &l.tokens,
+		Balances: &l.balances,
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if l.tokens == nil {
+		l.tokens = make(map[string]*Token)
+	}
+	if l.balances == nil {
+		l.balances = make(map[string]map[string]uint64)
+	}
+	return nil
+}
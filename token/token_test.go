@@ -0,0 +1,76 @@
+package token
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestLedgerApply(t *testing.T) {
+	l := NewLedger()
+	if err := l.Apply("alice", "alice", NewIssuePayload("GOLD", 2, 1000)); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Apply("alice", "bob", NewTransferPayload("GOLD", 400)); err != nil {
+		t.Fatal(err)
+	}
+	if l.BalanceOf("GOLD", "alice") != 600 || l.BalanceOf("GOLD", "bob") != 400 {
+		t.Fatalf("unexpected balances %v %v", l.Balances("alice"), l.Balances("bob"))
+	}
+
+	for _, c := range []struct {
+		name   string
+		sender string
+		p      *Payload
+		err    error
+	}{
+		{"not issuer", "bob", NewIssuePayload("GOLD", 2, 1), ErrNotIssuer},
+		{"decimals", "alice", NewIssuePayload("GOLD", 3, 1), ErrInvalidDecimals},
+		{"overflow", "alice", NewIssuePayload("GOLD", 2, math.MaxUint64), ErrSupplyOverflow},
+		{"not enough", "bob", NewTransferPayload("GOLD", 401), ErrNotEnough},
+		{"unknown", "alice", NewTransferPayload("SILVER", 1), ErrUnknownToken},
+		{"symbol", "alice", NewIssuePayload("gold", 0, 1), ErrInvalidSymbol},
+		{"amount", "alice", NewTransferPayload("GOLD", 0), ErrInvalidAmount},
+	} {
+		before := l.Copy()
+		if err := l.Apply(c.sender, "carol", c.p); !errors.Is(err, c.err) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.err, err)
+		}
+		if l.BalanceOf("GOLD", "alice") != before.BalanceOf("GOLD", "alice") || l.Token("GOLD").Supply() != 1000 {
+			t.Errorf("%s: ledger changed", c.name)
+		}
+	}
+}
+
+func TestNFTRegistryApply(t *testing.T) {
+	r := NewNFTRegistry()
+	mint := NewMintPayload([32]byte{1}, "art")
+	if err := r.Apply("alice", "alice", mint, 1); err != nil {
+		t.Fatal(err)
+	}
+	id := mint.NFTId("alice")
+	if err := r.Apply("alice", "alice", mint, 2); !errors.Is(err, ErrNFTExists) {
+		t.Fatalf("expected exists, got %v", err)
+	}
+	if err := r.Apply("bob", "bob", NewNFTTransferPayload(id), 2); !errors.Is(err, ErrNotNFTOwner) {
+		t.Fatalf("expected not owner, got %v", err)
+	}
+	if err := r.Apply("alice", "bob", NewNFTTransferPayload(id), 2); err != nil {
+		t.Fatal(err)
+	}
+	if n := r.NFT(id); n.Owner() != "bob" || n.Creator() != "alice" || len(n.History()) != 2 {
+		t.Fatalf("unexpected nft %+v", n)
+	}
+}
+
+func TestFormatAndParseAmount(t *testing.T) {
+	if s := FormatAmount(12345, 2); s != "123.45" {
+		t.Fatalf("unexpected %s", s)
+	}
+	if v, err := ParseAmount("123.45", 2); err != nil || v != 12345 {
+		t.Fatalf("unexpected %d %v", v, err)
+	}
+	if _, err := ParseAmount("1.234", 2); err == nil {
+		t.Fatal("too many decimals accepted")
+	}
+}
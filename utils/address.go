@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/btcsuite/btcutil/base58"
+	"golang.org/x/crypto/ripemd160"
+)
+
+// 주소 앞에 붙는 버전 바이트, 주소를 만든 방식을 구분한다
+const (
+	ADDRESS_VERSION_PUBLIC_KEY = 0x00
+	ADDRESS_VERSION_SCRIPT     = 0x05
+	ADDRESS_VERSION_MULTISIG   = 0x06
+	ADDRESS_VERSION_CONTRACT   = 0x08
+)
+
+// Public Key로 Blockchain Address 생성
+func BlockchainAddress(publicKey *ecdsa.PublicKey) string {
+	// 2. Public Key 에 SHA-256을 수행 (32 byte)
+	h2 := sha256.New()
+	h2.Write(publicKey.X.Bytes())
+	h2.Write(publicKey.Y.Bytes())
+	digest2 := h2.Sum(nil)
+	return encodeAddress(ADDRESS_VERSION_PUBLIC_KEY, digest2)
+}
+
+// m-of-n 다중서명 주소 생성 (버전 바이트 0x06)
+func MultisigAddress(required int, publicKeys []*ecdsa.PublicKey) string {
+	h := sha256.New()
+	h.Write([]byte{byte(required), byte(len(publicKeys))})
+	for _, publicKey := range publicKeys {
+		h.Write([]byte(fmt.Sprintf("%064x%064x", publicKey.X.Bytes(), publicKey.Y.Bytes())))
+	}
+	return encodeAddress(ADDRESS_VERSION_MULTISIG, h.Sum(nil))
+}
+
+// locking script hash 주소 (버전 바이트 0x05)
+func ScriptAddress(script []byte) string {
+	h := sha256.Sum256(script)
+	return encodeAddress(ADDRESS_VERSION_SCRIPT, h[:])
+}
+
+// contract 주소 (버전 바이트 0x08)
+func ContractAddress(seed []byte) string {
+	h := sha256.Sum256(seed)
+	return encodeAddress(ADDRESS_VERSION_CONTRACT, h[:])
+}
+
+// 주소의 버전 바이트, base58이나 checksum이 맞지 않으면 false
+func AddressVersion(address string) (byte, bool) {
+	dc8 := base58.Decode(address)
+	if len(dc8) != 25 {
+		return 0, false
+	}
+	h5 := sha256.Sum256(dc8[:21])
+	h6 := sha256.Sum256(h5[:])
+	if string(h6[:4]) != string(dc8[21:]) {
+		return 0, false
+	}
+	return dc8[0], true
+}
+
+func encodeAddress(version byte, digest2 []byte) string {
+	// 3. SHA-256 결과에 대해 pipemd-160 Hash를 수행 (20 bytes)
+	h3 := ripemd160.New()
+	h3.Write(digest2)
+	digest3 := h3.Sum(nil)
+	// 4. RIPEMD-160 해시 앞에 버전 바이트 추가(메인 네트워크의 경우 0x00)
+	vd4 := make([]byte, 21)
+	vd4[0] = version
+	copy(vd4[1:], digest3[:])
+	// 5. 확장된 ripemd160 결과에 대해 sha-256 해시 수행
+	h5 := sha256.New()
+	h5.Write(vd4)
+	digest5 := h5.Sum(nil)
+	// 6. 이전 SHA-256 해시의 결과에 대해 SHA-256 해시를 수행합니다.
+	h6 := sha256.New()
+	h6.Write(digest5)
+	digest6 := h6.Sum(nil)
+	// 7. 체크섬을 위해 두 번째 SHA-256 해시의 처음 4바이트를 가져옵니다.
+	chksum := digest6[:4]
+	// 8. 확장 RIPEMD-160 해시 끝에 7에서 4 체크섬 바이트를 4(25바이트)에서 더합니다.
+	dc8 := make([]byte, 25)
+	copy(dc8[:21], vd4[:])
+	copy(dc8[21:], chksum[:])
+	// 9. 결과를 바이트 문자열에서 base58로 변환합니다.
+	return base58.Encode(dc8)
+}
@@ -57,5 +57,11 @@ func GetHost() string {
 		return "127.0.0.1"
 	}
 	fmt.Println(address)
-	return address[3]
+	// 조회 결과의 개수와 순서는 환경마다 다르므로 loopback이 아닌 첫 IPv4 주소를 쓴다
+	for _, a := range address {
+		if ip := net.ParseIP(a); ip != nil && ip.To4() != nil && !ip.IsLoopback() {
+			return a
+		}
+	}
+	return "127.0.0.1"
 }
@@ -0,0 +1,120 @@
+package vm
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// contract assembly를 bytecode로 변환
+//
+//	; 주석
+//	loop:        JUMPDEST를 만들고 위치를 label로 기록
+//	@loop        label 위치를 PUSH
+//	0x0a 10 'ten PUSH (hex, 10진수, ' 뒤의 문자열)
+//	SSTORE       opcode
+func Assemble(asm string) ([]byte, error) {
+	tokens := make([]string, 0)
+	for _, line := range strings.Split(asm, "\n") {
+		if i := strings.Index(line, ";"); i >= 0 {
+			line = line[:i]
+		}
+		tokens = append(tokens, strings.Fields(line)...)
+	}
+
+	// label 위치를 알기 위해 먼저 크기만 계산 (label PUSH는 항상 2바이트)
+	labels := make(map[string]int)
+	pc := 0
+	for _, token := range tokens {
+		switch {
+		case strings.HasSuffix(token, ":"):
+			labels[strings.TrimSuffix(token, ":")] = pc
+			pc += 1
+		case strings.HasPrefix(token, "@"):
+			pc += 4
+		default:
+			if _, ok := opcodeValues[token]; ok {
+				pc += 1
+				continue
+			}
+			data, err := pushData(token)
+			if err != nil {
+				return nil, err
+			}
+			pc += 2 + len(data)
+		}
+	}
+
+	code := make([]byte, 0, pc)
+	for _, token := range tokens {
+		switch {
+		case strings.HasSuffix(token, ":"):
+			code = append(code, JUMPDEST)
+		case strings.HasPrefix(token, "@"):
+			dest, ok := labels[strings.TrimPrefix(token, "@")]
+			if !ok {
+				return nil, fmt.Errorf("vm: unknown label %q", token)
+			}
+			code = append(code, PUSH, 2, byte(dest>>8), byte(dest))
+		default:
+			if op, ok := opcodeValues[token]; ok {
+				code = append(code, op)
+				continue
+			}
+			data, _ := pushData(token)
+			code = append(code, PUSH, byte(len(data)))
+			code = append(code, data...)
+		}
+	}
+	if len(code) > MAX_CODE_SIZE {
+		return nil, ErrCodeTooBig
+	}
+	return code, nil
+}
+
+func pushData(token string) ([]byte, error) {
+	var data []byte
+	switch {
+	case strings.HasPrefix(token, "0x"):
+		b, err := hex.DecodeString(token[2:])
+		if err != nil {
+			return nil, fmt.Errorf("vm: invalid token %q", token)
+		}
+		data = b
+	case strings.HasPrefix(token, "'"):
+		data = []byte(token[1:])
+	default:
+		n, ok := new(big.Int).SetString(token, 10)
+		if !ok || n.Sign() < 0 {
+			return nil, fmt.Errorf("vm: invalid token %q", token)
+		}
+		data = n.Bytes()
+	}
+	if len(data) > MAX_WORD_SIZE {
+		return nil, ErrWordTooBig
+	}
+	return data, nil
+}
+
+func Disassemble(code []byte) (string, error) {
+	tokens := make([]string, 0)
+	for pc := 0; pc < len(code); pc++ {
+		op := code[pc]
+		o, ok := opcodes[op]
+		if !ok {
+			return "", ErrInvalidOpcode
+		}
+		if op != PUSH {
+			tokens = append(tokens, o.name)
+			continue
+		}
+		if pc+1 >= len(code) || pc+2+int(code[pc+1]) > len(code) {
+			return "", ErrInvalidOpcode
+		}
+		n := int(code[pc+1])
+		tokens = append(tokens, "0x"+hex.EncodeToString(code[pc+2:pc+2+n]))
+		pc += 1 + n
+	}
+	return strings.Join(tokens, " "), nil
+}
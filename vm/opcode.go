@@ -0,0 +1,87 @@
+package vm
+
+const (
+	STOP = 0x00
+
+	ADD    = 0x01
+	SUB    = 0x02
+	MUL    = 0x03
+	DIV    = 0x04
+	MOD    = 0x05
+	LT     = 0x10
+	GT     = 0x11
+	EQ     = 0x12
+	ISZERO = 0x13
+
+	SHA256 = 0x20
+	CONCAT = 0x21
+
+	CALLER    = 0x30
+	ADDRESS   = 0x31
+	INPUT     = 0x32
+	INPUTSIZE = 0x33
+	HEIGHT    = 0x34
+
+	POP      = 0x50
+	DUP      = 0x51
+	SWAP     = 0x52
+	PICK     = 0x53
+	SLOAD    = 0x54
+	SSTORE   = 0x55
+	JUMP     = 0x56
+	JUMPI    = 0x57
+	JUMPDEST = 0x5b
+
+	PUSH = 0x60
+
+	LOG = 0xa0
+
+	RETURN = 0xf3
+	REVERT = 0xfd
+)
+
+type opcode struct {
+	name string
+	gas  uint64
+}
+
+var opcodes = map[byte]opcode{
+	STOP:      {"STOP", 0},
+	ADD:       {"ADD", 3},
+	SUB:       {"SUB", 3},
+	MUL:       {"MUL", 5},
+	DIV:       {"DIV", 5},
+	MOD:       {"MOD", 5},
+	LT:        {"LT", 3},
+	GT:        {"GT", 3},
+	EQ:        {"EQ", 3},
+	ISZERO:    {"ISZERO", 3},
+	SHA256:    {"SHA256", 30},
+	CONCAT:    {"CONCAT", 3},
+	CALLER:    {"CALLER", 2},
+	ADDRESS:   {"ADDRESS", 2},
+	INPUT:     {"INPUT", 3},
+	INPUTSIZE: {"INPUTSIZE", 2},
+	HEIGHT:    {"HEIGHT", 2},
+	POP:       {"POP", 2},
+	DUP:       {"DUP", 3},
+	SWAP:      {"SWAP", 3},
+	PICK:      {"PICK", 3},
+	SLOAD:     {"SLOAD", 50},
+	SSTORE:    {"SSTORE", 200},
+	JUMP:      {"JUMP", 8},
+	JUMPI:     {"JUMPI", 10},
+	JUMPDEST:  {"JUMPDEST", 1},
+	PUSH:      {"PUSH", 3},
+	LOG:       {"LOG", 100},
+	RETURN:    {"RETURN", 0},
+	REVERT:    {"REVERT", 0},
+}
+
+var opcodeValues = make(map[string]byte)
+
+func init() {
+	for op, o := range opcodes {
+		opcodeValues[o.name] = op
+	}
+}
@@ -0,0 +1,92 @@
+package vm
+
+import (
+	"encoding/hex"
+	"encoding/json"
+)
+
+// Transaction에 실리는 contract 배포/호출 정보
+type Payload struct {
+	code     []byte
+	input    [][]byte
+	gasLimit uint64
+	gasPrice float32
+}
+
+func NewDeployPayload(code []byte, gasLimit uint64, gasPrice float32) *Payload {
+	return &Payload{code: code, gasLimit: gasLimit, gasPrice: gasPrice}
+}
+
+func NewCallPayload(input [][]byte, gasLimit uint64, gasPrice float32) *Payload {
+	return &Payload{input: input, gasLimit: gasLimit, gasPrice: gasPrice}
+}
+
+func (p *Payload) IsDeploy() bool {
+	return len(p.code) > 0
+}
+
+func (p *Payload) Code() []byte {
+	return p.code
+}
+
+func (p *Payload) Input() [][]byte {
+	return p.input
+}
+
+func (p *Payload) GasLimit() uint64 {
+	return p.gasLimit
+}
+
+func (p *Payload) GasPrice() float32 {
+	return p.gasPrice
+}
+
+func (p *Payload) MarshalJSON() ([]byte, error) {
+	var input []string
+	for _, in := range p.input {
+		input = append(input, hex.EncodeToString(in))
+	}
+	return json.Marshal(struct {
+		Code     string   `json:"code,omitempty"`
+		Input    []string `json:"input,omitempty"`
+		GasLimit uint64   `json:"gas_limit"`
+		GasPrice float32  `json:"gas_price"`
+	}{
+		Code:     hex.EncodeToString(p.code),
+		Input:    input,
+		GasLimit: p.gasLimit,
+		GasPrice: p.gasPrice,
+	})
+}
+
+func (p *Payload) UnmarshalJSON(data []byte) error {
+	var code string
+	var input []string
+	v := &struct {
+		Code     *string   `json:"code"`
+		Input    *[]string `json:"input"`
+		GasLimit *uint64   `json:"gas_limit"`
+		GasPrice *float32  `json:"gas_price"`
+	}{
+		Code:     &code,
+		Input:    &input,
+		GasLimit: &p.gasLimit,
+		GasPrice: &p.gasPrice,
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	var err error
+	if p.code, err = hex.DecodeString(code); err != nil {
+		return err
+	}
+	p.input = nil
+	for _, in := range input {
+		b, err := hex.DecodeString(in)
+		if err != nil {
+			return err
+		}
+		p.input = append(p.input, b)
+	}
+	return nil
+}
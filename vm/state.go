@@ -0,0 +1,151 @@
+package vm
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"sort"
+
+	"github.com/sw90lee/blockchain_study/utils"
+)
+
+// 배포된 contract의 code와 key/value storage
+type Contract struct {
+	code    []byte
+	storage map[string][]byte
+}
+
+func (c *Contract) Code() []byte {
+	return c.code
+}
+
+func (c *Contract) Storage() map[string][]byte {
+	return c.storage
+}
+
+// 모든 contract의 상태
+type State struct {
+	contracts map[string]*Contract
+}
+
+func NewState() *State {
+	return &State{contracts: make(map[string]*Contract)}
+}
+
+func (s *State) Copy() *State {
+	c := NewState()
+	for address, contract := range s.contracts {
+		storage := make(map[string][]byte, len(contract.storage))
+		for k, v := range contract.storage {
+			storage[k] = v
+		}
+		c.contracts[address] = &Contract{contract.code, storage}
+	}
+	return c
+}
+
+func (s *State) Contract(address string) *Contract {
+	return s.contracts[address]
+}
+
+func (s *State) Contracts() map[string]*Contract {
+	return s.contracts
+}
+
+func (s *State) deploy(address string, code []byte) {
+	s.contracts[address] = &Contract{code, make(map[string][]byte)}
+}
+
+// 주소 순서, key 순서로 모든 contract의 code와 storage를 hash
+// contract가 하나도 없으면 0
+func (s *State) Root() [32]byte {
+	if len(s.contracts) == 0 {
+		return [32]byte{}
+	}
+	h := sha256.New()
+	addresses := make([]string, 0, len(s.contracts))
+	for address := range s.contracts {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		c := s.contracts[address]
+		writeBytes(h, []byte(address))
+		writeBytes(h, c.code)
+		keys := make([]string, 0, len(c.storage))
+		for k := range c.storage {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			writeBytes(h, []byte(k))
+			writeBytes(h, c.storage[k])
+		}
+	}
+	var root [32]byte
+	copy(root[:], h.Sum(nil))
+	return root
+}
+
+func writeBytes(h hash.Hash, b []byte) {
+	l := make([]byte, 4)
+	binary.BigEndian.PutUint32(l, uint32(len(b)))
+	h.Write(l)
+	h.Write(b)
+}
+
+// 배포 Transaction이 들어간 Block 높이와 순서로 contract 주소 생성
+func ContractAddress(deployer string, height int, index int) string {
+	return utils.ContractAddress([]byte(fmt.Sprintf("%s:%d:%d", deployer, height, index)))
+}
+
+// snapshot으로 주고받기 위한 JSON (key와 value는 hex)
+func (s *State) MarshalJSON() ([]byte, error) {
+	type contract struct {
+		Code    string            `json:"code"`
+		Storage map[string]string `json:"storage"`
+	}
+	contracts := make(map[string]*contract, len(s.contracts))
+	for address, c := range s.contracts {
+		storage := make(map[string]string, len(c.storage))
+		for k, v := range c.storage {
+			storage[hex.EncodeToString([]byte(k))] = hex.EncodeToString(v)
+		}
+		contracts[address] = &contract{hex.EncodeToString(c.code), storage}
+	}
+	return json.Marshal(contracts)
+}
+
+func (s *State) UnmarshalJSON(data []byte) error {
+	var contracts map[string]*struct {
+		Code    string            `json:"code"`
+		Storage map[string]string `json:"storage"`
+	}
+	if err := json.Unmarshal(data, &contracts); err != nil {
+		return err
+	}
+	s.contracts = make(map[string]*Contract, len(contracts))
+	for address, c := range contracts {
+		code, err := hex.DecodeString(c.Code)
+		if err != nil {
+			return err
+		}
+		storage := make(map[string][]byte, len(c.Storage))
+		for k, v := range c.Storage {
+			key, err := hex.DecodeString(k)
+			if err != nil {
+				return err
+			}
+			value, err := hex.DecodeString(v)
+			if err != nil {
+				return err
+			}
+			storage[string(key)] = value
+		}
+		s.contracts[address] = &Contract{code, storage}
+	}
+	return nil
+}
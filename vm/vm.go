@@ -0,0 +1,469 @@
+// Package vm 은 chain 위에 배포되는 contract를 실행하는 결정적인
+// stack 기반 가상 머신이다.
+//
+// 모든 연산은 gas를 소모하고, gas가 부족하거나 실행이 실패하면
+// 그 호출에서 바뀐 storage와 log는 모두 버려진다. 외부 I/O나
+// 시간처럼 노드마다 다를 수 있는 값에는 접근할 수 없다.
+package vm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+)
+
+const (
+	MAX_CODE_SIZE  = 4096
+	MAX_STACK_SIZE = 256
+	MAX_WORD_SIZE  = 64
+	MAX_INPUTS     = 16
+	MAX_LOGS       = 64
+
+	DEPLOY_GAS          = 1000
+	DEPLOY_GAS_PER_BYTE = 5
+	LOG_GAS_PER_BYTE    = 1
+)
+
+var (
+	ErrOutOfGas       = errors.New("vm: out of gas")
+	ErrCodeTooBig     = errors.New("vm: code too big")
+	ErrStackOverflow  = errors.New("vm: stack overflow")
+	ErrStackUnderflow = errors.New("vm: stack underflow")
+	ErrWordTooBig     = errors.New("vm: word too big")
+	ErrInvalidOpcode  = errors.New("vm: invalid opcode")
+	ErrInvalidJump    = errors.New("vm: invalid jump destination")
+	ErrInvalidInput   = errors.New("vm: invalid input index")
+	ErrTooManyLogs    = errors.New("vm: too many logs")
+	ErrUnderflow      = errors.New("vm: arithmetic underflow")
+	ErrDivisionByZero = errors.New("vm: division by zero")
+	ErrReverted       = errors.New("vm: reverted")
+	ErrNoContract     = errors.New("vm: no contract at address")
+	ErrContractExists = errors.New("vm: contract already exists")
+	ErrTooManyInputs  = errors.New("vm: too many inputs")
+)
+
+// contract가 남기는 event
+type Log struct {
+	Contract string
+	Topic    []byte
+	Data     []byte
+}
+
+// 호출 환경
+type Context struct {
+	Caller   string
+	Contract string
+	Input    [][]byte
+	Height   int64
+	GasLimit uint64
+}
+
+type Result struct {
+	GasUsed uint64
+	Return  []byte
+	Logs    []*Log
+	Err     error
+}
+
+// code를 address에 배포
+func Deploy(state *State, address string, code []byte, gasLimit uint64) *Result {
+	gas := uint64(DEPLOY_GAS + DEPLOY_GAS_PER_BYTE*len(code))
+	if gas > gasLimit {
+		return &Result{GasUsed: gasLimit, Err: ErrOutOfGas}
+	}
+	if len(code) > MAX_CODE_SIZE {
+		return &Result{GasUsed: gasLimit, Err: ErrCodeTooBig}
+	}
+	if state.Contract(address) != nil {
+		return &Result{GasUsed: gasLimit, Err: ErrContractExists}
+	}
+	state.deploy(address, code)
+	return &Result{GasUsed: gas}
+}
+
+// contract 호출, 실패하면 state는 바뀌지 않는다
+func Execute(state *State, ctx *Context) *Result {
+	contract := state.Contract(ctx.Contract)
+	if contract == nil {
+		return &Result{GasUsed: ctx.GasLimit, Err: ErrNoContract}
+	}
+	if len(ctx.Input) > MAX_INPUTS {
+		return &Result{GasUsed: ctx.GasLimit, Err: ErrTooManyInputs}
+	}
+	for _, in := range ctx.Input {
+		if len(in) > MAX_WORD_SIZE {
+			return &Result{GasUsed: ctx.GasLimit, Err: ErrWordTooBig}
+		}
+	}
+
+	m := &machine{
+		ctx:     ctx,
+		code:    contract.code,
+		storage: contract.storage,
+		writes:  make(map[string][]byte),
+		stack:   make([][]byte, 0),
+	}
+	ret, err := m.run()
+	if err != nil {
+		gasUsed := m.gasUsed
+		if err == ErrOutOfGas {
+			gasUsed = ctx.GasLimit
+		}
+		return &Result{GasUsed: gasUsed, Err: err}
+	}
+
+	for k, v := range m.writes {
+		if len(v) == 0 {
+			delete(contract.storage, k)
+		} else {
+			contract.storage[k] = v
+		}
+	}
+	return &Result{GasUsed: m.gasUsed, Return: ret, Logs: m.logs}
+}
+
+type machine struct {
+	ctx     *Context
+	code    []byte
+	storage map[string][]byte
+	// 호출이 성공했을 때만 storage에 반영
+	writes  map[string][]byte
+	stack   [][]byte
+	logs    []*Log
+	gasUsed uint64
+}
+
+func (m *machine) useGas(gas uint64) error {
+	if m.gasUsed+gas > m.ctx.GasLimit {
+		m.gasUsed = m.ctx.GasLimit
+		return ErrOutOfGas
+	}
+	m.gasUsed += gas
+	return nil
+}
+
+func (m *machine) push(word []byte) error {
+	if len(word) > MAX_WORD_SIZE {
+		return ErrWordTooBig
+	}
+	if len(m.stack) >= MAX_STACK_SIZE {
+		return ErrStackOverflow
+	}
+	m.stack = append(m.stack, word)
+	return nil
+}
+
+func (m *machine) pop() ([]byte, error) {
+	if len(m.stack) == 0 {
+		return nil, ErrStackUnderflow
+	}
+	word := m.stack[len(m.stack)-1]
+	m.stack = m.stack[:len(m.stack)-1]
+	return word, nil
+}
+
+func (m *machine) pop2() ([]byte, []byte, error) {
+	a, err := m.pop()
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := m.pop()
+	if err != nil {
+		return nil, nil, err
+	}
+	return a, b, nil
+}
+
+func (m *machine) pushNumber(n *big.Int) error {
+	return m.push(n.Bytes())
+}
+
+func (m *machine) pushBool(b bool) error {
+	if b {
+		return m.push([]byte{1})
+	}
+	return m.push([]byte{})
+}
+
+func isZero(word []byte) bool {
+	for _, c := range word {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *machine) sload(key []byte) []byte {
+	if v, ok := m.writes[string(key)]; ok {
+		return v
+	}
+	return m.storage[string(key)]
+}
+
+// JUMP 가능한 위치 (PUSH 데이터 안의 0x5b는 제외)
+func jumpDests(code []byte) map[int]bool {
+	dests := make(map[int]bool)
+	for pc := 0; pc < len(code); pc++ {
+		switch code[pc] {
+		case JUMPDEST:
+			dests[pc] = true
+		case PUSH:
+			if pc+1 < len(code) {
+				pc += 1 + int(code[pc+1])
+			}
+		}
+	}
+	return dests
+}
+
+func (m *machine) run() ([]byte, error) {
+	dests := jumpDests(m.code)
+	pc := 0
+	for pc < len(m.code) {
+		op := m.code[pc]
+		o, ok := opcodes[op]
+		if !ok {
+			return nil, ErrInvalidOpcode
+		}
+		if err := m.useGas(o.gas); err != nil {
+			return nil, err
+		}
+		pc += 1
+
+		switch op {
+		case STOP:
+			return nil, nil
+		case PUSH:
+			if pc >= len(m.code) || pc+1+int(m.code[pc]) > len(m.code) {
+				return nil, ErrInvalidOpcode
+			}
+			n := int(m.code[pc])
+			if err := m.push(m.code[pc+1 : pc+1+n]); err != nil {
+				return nil, err
+			}
+			pc += 1 + n
+		case ADD, SUB, MUL, DIV, MOD, LT, GT:
+			a, b, err := m.pop2()
+			if err != nil {
+				return nil, err
+			}
+			x, y := new(big.Int).SetBytes(a), new(big.Int).SetBytes(b)
+			if err := m.arithmetic(op, x, y); err != nil {
+				return nil, err
+			}
+		case EQ:
+			a, b, err := m.pop2()
+			if err != nil {
+				return nil, err
+			}
+			if err := m.pushBool(bytes.Equal(a, b)); err != nil {
+				return nil, err
+			}
+		case ISZERO:
+			a, err := m.pop()
+			if err != nil {
+				return nil, err
+			}
+			if err := m.pushBool(isZero(a)); err != nil {
+				return nil, err
+			}
+		case SHA256:
+			a, err := m.pop()
+			if err != nil {
+				return nil, err
+			}
+			h := sha256.Sum256(a)
+			if err := m.push(h[:]); err != nil {
+				return nil, err
+			}
+		case CONCAT:
+			a, b, err := m.pop2()
+			if err != nil {
+				return nil, err
+			}
+			c := make([]byte, 0, len(a)+len(b))
+			if err := m.push(append(append(c, b...), a...)); err != nil {
+				return nil, err
+			}
+		case CALLER:
+			if err := m.push([]byte(m.ctx.Caller)); err != nil {
+				return nil, err
+			}
+		case ADDRESS:
+			if err := m.push([]byte(m.ctx.Contract)); err != nil {
+				return nil, err
+			}
+		case INPUT:
+			a, err := m.pop()
+			if err != nil {
+				return nil, err
+			}
+			i := new(big.Int).SetBytes(a)
+			if !i.IsInt64() || i.Int64() >= int64(len(m.ctx.Input)) {
+				return nil, ErrInvalidInput
+			}
+			if err := m.push(m.ctx.Input[i.Int64()]); err != nil {
+				return nil, err
+			}
+		case INPUTSIZE:
+			if err := m.pushNumber(big.NewInt(int64(len(m.ctx.Input)))); err != nil {
+				return nil, err
+			}
+		case HEIGHT:
+			if err := m.pushNumber(big.NewInt(m.ctx.Height)); err != nil {
+				return nil, err
+			}
+		case POP:
+			if _, err := m.pop(); err != nil {
+				return nil, err
+			}
+		case DUP:
+			if len(m.stack) == 0 {
+				return nil, ErrStackUnderflow
+			}
+			if err := m.push(m.stack[len(m.stack)-1]); err != nil {
+				return nil, err
+			}
+		case SWAP:
+			if len(m.stack) < 2 {
+				return nil, ErrStackUnderflow
+			}
+			n := len(m.stack)
+			m.stack[n-1], m.stack[n-2] = m.stack[n-2], m.stack[n-1]
+		case PICK:
+			// n번째 아래 값을 복사 (0이면 DUP과 같다)
+			a, err := m.pop()
+			if err != nil {
+				return nil, err
+			}
+			n := new(big.Int).SetBytes(a)
+			if !n.IsInt64() || n.Int64() >= int64(len(m.stack)) {
+				return nil, ErrStackUnderflow
+			}
+			if err := m.push(m.stack[len(m.stack)-1-int(n.Int64())]); err != nil {
+				return nil, err
+			}
+		case SLOAD:
+			key, err := m.pop()
+			if err != nil {
+				return nil, err
+			}
+			if err := m.push(m.sload(key)); err != nil {
+				return nil, err
+			}
+		case SSTORE:
+			// key, value 순서로 push
+			value, key, err := m.pop2()
+			if err != nil {
+				return nil, err
+			}
+			m.writes[string(key)] = value
+		case JUMP, JUMPI:
+			dest, err := m.pop()
+			if err != nil {
+				return nil, err
+			}
+			if op == JUMPI {
+				cond, err := m.pop()
+				if err != nil {
+					return nil, err
+				}
+				if isZero(cond) {
+					continue
+				}
+			}
+			d := new(big.Int).SetBytes(dest)
+			if !d.IsInt64() || !dests[int(d.Int64())] {
+				return nil, ErrInvalidJump
+			}
+			pc = int(d.Int64())
+		case JUMPDEST:
+		case LOG:
+			// topic, data 순서로 push
+			data, topic, err := m.pop2()
+			if err != nil {
+				return nil, err
+			}
+			if len(m.logs) >= MAX_LOGS {
+				return nil, ErrTooManyLogs
+			}
+			if err := m.useGas(uint64(LOG_GAS_PER_BYTE * (len(topic) + len(data)))); err != nil {
+				return nil, err
+			}
+			m.logs = append(m.logs, &Log{Contract: m.ctx.Contract, Topic: topic, Data: data})
+		case RETURN:
+			return m.pop()
+		case REVERT:
+			return nil, ErrReverted
+		}
+	}
+	return nil, nil
+}
+
+// 두 번째로 push한 값(y)이 왼쪽 피연산자: PUSH y PUSH x SUB => y - x
+func (m *machine) arithmetic(op byte, x *big.Int, y *big.Int) error {
+	r := new(big.Int)
+	switch op {
+	case ADD:
+		r.Add(y, x)
+	case SUB:
+		if y.Cmp(x) < 0 {
+			return ErrUnderflow
+		}
+		r.Sub(y, x)
+	case MUL:
+		r.Mul(y, x)
+	case DIV, MOD:
+		if x.Sign() == 0 {
+			return ErrDivisionByZero
+		}
+		if op == DIV {
+			r.Div(y, x)
+		} else {
+			r.Mod(y, x)
+		}
+	case LT:
+		return m.pushBool(y.Cmp(x) < 0)
+	case GT:
+		return m.pushBool(y.Cmp(x) > 0)
+	}
+	return m.pushNumber(r)
+}
+
+func (l *Log) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Contract string `json:"contract"`
+		Topic    string `json:"topic"`
+		Data     string `json:"data"`
+	}{
+		Contract: l.Contract,
+		Topic:    hex.EncodeToString(l.Topic),
+		Data:     hex.EncodeToString(l.Data),
+	})
+}
+
+func (l *Log) UnmarshalJSON(data []byte) error {
+	var topic, d string
+	v := &struct {
+		Contract *string `json:"contract"`
+		Topic    *string `json:"topic"`
+		Data     *string `json:"data"`
+	}{
+		Contract: &l.Contract,
+		Topic:    &topic,
+		Data:     &d,
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	var err error
+	if l.Topic, err = hex.DecodeString(topic); err != nil {
+		return err
+	}
+	l.Data, err = hex.DecodeString(d)
+	return err
+}
@@ -0,0 +1,127 @@
+package vm
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// 호출할 때마다 1을 더해 저장하고 새 값을 돌려준다
+const counter = `
+	'count 'count SLOAD 1 ADD SSTORE
+	'count 'incremented LOG
+	'count SLOAD RETURN
+`
+
+func deploy(t *testing.T, state *State, asm string) {
+	code, err := Assemble(asm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := Deploy(state, "contract", code, 1000000); r.Err != nil {
+		t.Fatal(r.Err)
+	}
+}
+
+func call(state *State, gasLimit uint64, input ...[]byte) *Result {
+	return Execute(state, &Context{Caller: "alice", Contract: "contract", Input: input, Height: 7, GasLimit: gasLimit})
+}
+
+func TestGasMetering(t *testing.T) {
+	state := NewState()
+	deploy(t, state, "2 3 ADD RETURN")
+	r := call(state, 100)
+	if r.Err != nil || new(big.Int).SetBytes(r.Return).Int64() != 5 {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	// PUSH 두 번, ADD, RETURN
+	if want := opcodes[PUSH].gas*2 + opcodes[ADD].gas + opcodes[RETURN].gas; r.GasUsed != want {
+		t.Fatalf("gas used %d, want %d", r.GasUsed, want)
+	}
+
+	code, _ := Assemble("STOP")
+	if r := Deploy(state, "other", code, DEPLOY_GAS); !errors.Is(r.Err, ErrOutOfGas) || state.Contract("other") != nil {
+		t.Fatalf("deploy without enough gas: %+v", r)
+	}
+	if r := Deploy(state, "other", code, DEPLOY_GAS+DEPLOY_GAS_PER_BYTE); r.Err != nil || r.GasUsed != DEPLOY_GAS+DEPLOY_GAS_PER_BYTE {
+		t.Fatalf("unexpected deploy %+v", r)
+	}
+}
+
+func TestStorage(t *testing.T) {
+	state := NewState()
+	deploy(t, state, counter)
+	for i := int64(1); i <= 3; i++ {
+		r := call(state, 10000)
+		if r.Err != nil || new(big.Int).SetBytes(r.Return).Int64() != i {
+			t.Fatalf("call %d: %+v", i, r)
+		}
+		if len(r.Logs) != 1 || string(r.Logs[0].Topic) != "count" {
+			t.Fatalf("unexpected logs %+v", r.Logs)
+		}
+	}
+	if v := state.Contract("contract").Storage()["count"]; new(big.Int).SetBytes(v).Int64() != 3 {
+		t.Fatalf("unexpected storage %x", v)
+	}
+
+	// Copy한 state의 호출은 원래 state를 바꾸지 않는다
+	root := state.Root()
+	if r := call(state.Copy(), 10000); r.Err != nil {
+		t.Fatal(r.Err)
+	}
+	if state.Root() != root {
+		t.Fatal("copy shares storage")
+	}
+}
+
+// gas가 모자라거나 실패한 호출의 storage와 log는 버린다
+func TestOutOfGasRollback(t *testing.T) {
+	state := NewState()
+	deploy(t, state, counter)
+	if r := call(state, 10000); r.Err != nil {
+		t.Fatal(r.Err)
+	}
+	root := state.Root()
+
+	// SSTORE는 했지만 LOG의 gas가 모자라다
+	r := call(state, 300)
+	if !errors.Is(r.Err, ErrOutOfGas) || r.GasUsed != 300 || r.Logs != nil {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	if state.Root() != root {
+		t.Fatal("storage changed by failed call")
+	}
+
+	state = NewState()
+	deploy(t, state, "'key 'value SSTORE REVERT")
+	root = state.Root()
+	if !errors.Is(call(state, 10000).Err, ErrReverted) {
+		t.Fatal("expected revert")
+	}
+	if state.Root() != root {
+		t.Fatal("storage changed by reverted call")
+	}
+}
+
+func TestExecuteErrors(t *testing.T) {
+	for _, c := range []struct {
+		asm string
+		err error
+	}{
+		{"ADD", ErrStackUnderflow},
+		{"0 1 SUB", ErrUnderflow},
+		{"1 0 DIV", ErrDivisionByZero},
+		{"5 JUMP", ErrInvalidJump},
+		{"3 INPUT", ErrInvalidInput},
+		{"loop: 1 @loop JUMP", ErrOutOfGas},
+	} {
+		state := NewState()
+		deploy(t, state, c.asm)
+		if r := call(state, 1000, []byte("a")); !errors.Is(r.Err, c.err) {
+			t.Errorf("%s: expected %v, got %v", c.asm, c.err, r.Err)
+		}
+	}
+	if r := call(NewState(), 1000); !errors.Is(r.Err, ErrNoContract) || r.GasUsed != 1000 {
+		t.Fatalf("unexpected result %+v", r)
+	}
+}
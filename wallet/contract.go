@@ -0,0 +1,33 @@
+package wallet
+
+// contract 배포(code 또는 assembly) 또는 호출(contract_address) 요청
+type ContractRequest struct {
+	SenderPrivateKey        *string  `json:"sender_private_key"`
+	SenderPublicKey         *string  `json:"sender_public_key"`
+	SenderBlockchainAddress *string  `json:"sender_blockchain_address"`
+	Code                    *string  `json:"code,omitempty"`
+	Assembly                *string  `json:"assembly,omitempty"`
+	ContractAddress         *string  `json:"contract_address,omitempty"`
+	Input                   []string `json:"input,omitempty"`
+	GasLimit                *string  `json:"gas_limit"`
+	GasPrice                *string  `json:"gas_price"`
+}
+
+func (cr *ContractRequest) IsDeploy() bool {
+	return cr.ContractAddress == nil
+}
+
+func (cr *ContractRequest) Validate() bool {
+	if cr.SenderPrivateKey == nil ||
+		cr.SenderPublicKey == nil ||
+		cr.SenderBlockchainAddress == nil ||
+		cr.GasLimit == nil ||
+		cr.GasPrice == nil ||
+		len(*cr.SenderPublicKey) != 128 {
+		return false
+	}
+	if cr.IsDeploy() && cr.Code == nil && cr.Assembly == nil {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,44 @@
+package wallet
+
+type HTLCRequest struct {
+	SenderPrivateKey        *string `json:"sender_private_key"`
+	SenderPublicKey         *string `json:"sender_public_key"`
+	SenderBlockchainAddress *string `json:"sender_blockchain_address"`
+	RecipientPublicKey      *string `json:"recipient_public_key"`
+	Value                   *string `json:"value"`
+	Timeout                 *string `json:"timeout"`
+	// 비어 있으면 wallet server가 preimage를 새로 만든다
+	Hash *string `json:"hash,omitempty"`
+}
+
+func (hr *HTLCRequest) Validate() bool {
+	if hr.SenderPrivateKey == nil ||
+		hr.SenderPublicKey == nil ||
+		hr.SenderBlockchainAddress == nil ||
+		hr.RecipientPublicKey == nil ||
+		hr.Value == nil ||
+		hr.Timeout == nil ||
+		len(*hr.SenderPublicKey) != 128 ||
+		len(*hr.RecipientPublicKey) != 128 {
+		return false
+	}
+	return true
+}
+
+// HTLC에서 돈을 꺼내는 요청 (redeem은 preimage 필요)
+type HTLCSpendRequest struct {
+	PrivateKey    *string `json:"private_key"`
+	PublicKey     *string `json:"public_key"`
+	LockingScript *string `json:"locking_script"`
+	Preimage      *string `json:"preimage,omitempty"`
+}
+
+func (sr *HTLCSpendRequest) Validate() bool {
+	if sr.PrivateKey == nil ||
+		sr.PublicKey == nil ||
+		sr.LockingScript == nil ||
+		len(*sr.PublicKey) != 128 {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,165 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+
+	"github.com/sw90lee/blockchain_study/utils"
+)
+
+// 여러 서명자가 차례로 서명을 채워 넣는 m-of-n Transaction
+type MultisigTransaction struct {
+	required                   int
+	publicKeys                 []*ecdsa.PublicKey
+	senderBlockchainAddress    string
+	recipientBlockchainAddress string
+	value                      float32
+	signatures                 []*utils.Signature
+}
+
+func NewMultisigTransaction(required int, publicKeys []*ecdsa.PublicKey,
+	recipient string, value float32) *MultisigTransaction {
+	return &MultisigTransaction{
+		required:                   required,
+		publicKeys:                 publicKeys,
+		senderBlockchainAddress:    utils.MultisigAddress(required, publicKeys),
+		recipientBlockchainAddress: recipient,
+		value:                      value,
+		signatures:                 make([]*utils.Signature, len(publicKeys)),
+	}
+}
+
+func (mt *MultisigTransaction) SenderBlockchainAddress() string {
+	return mt.senderBlockchainAddress
+}
+
+func (mt *MultisigTransaction) RecipientBlockchainAddress() string {
+	return mt.recipientBlockchainAddress
+}
+
+func (mt *MultisigTransaction) Value() float32 {
+	return mt.value
+}
+
+func (mt *MultisigTransaction) Required() int {
+	return mt.required
+}
+
+// privateKey에 해당하는 public key 자리에 서명 추가
+func (mt *MultisigTransaction) Sign(privateKey *ecdsa.PrivateKey) bool {
+	for i, publicKey := range mt.publicKeys {
+		if publicKey.X.Cmp(privateKey.X) != 0 || publicKey.Y.Cmp(privateKey.Y) != 0 {
+			continue
+		}
+		t := NewTransaction(privateKey, publicKey,
+			mt.senderBlockchainAddress, mt.recipientBlockchainAddress, mt.value)
+		mt.signatures[i] = t.GenerateSignature()
+		return true
+	}
+	return false
+}
+
+func (mt *MultisigTransaction) SignatureCount() int {
+	count := 0
+	for _, s := range mt.signatures {
+		if s != nil {
+			count += 1
+		}
+	}
+	return count
+}
+
+func (mt *MultisigTransaction) IsComplete() bool {
+	return mt.SignatureCount() >= mt.required
+}
+
+func (mt *MultisigTransaction) PublicKeyStrs() []string {
+	keys := make([]string, len(mt.publicKeys))
+	for i, publicKey := range mt.publicKeys {
+		keys[i] = fmt.Sprintf("%064x%064x", publicKey.X.Bytes(), publicKey.Y.Bytes())
+	}
+	return keys
+}
+
+func (mt *MultisigTransaction) SignatureStrs() []string {
+	signatures := make([]string, len(mt.signatures))
+	for i, s := range mt.signatures {
+		if s != nil {
+			signatures[i] = s.String()
+		}
+	}
+	return signatures
+}
+
+func (mt *MultisigTransaction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Sender     string   `json:"sender_blockchain_address"`
+		Recipient  string   `json:"recipient_blockchain_address"`
+		Value      float32  `json:"value"`
+		Required   int      `json:"multisig_required"`
+		PublicKeys []string `json:"multisig_public_keys"`
+		Signatures []string `json:"signatures"`
+	}{
+		Sender:     mt.senderBlockchainAddress,
+		Recipient:  mt.recipientBlockchainAddress,
+		Value:      mt.value,
+		Required:   mt.required,
+		PublicKeys: mt.PublicKeyStrs(),
+		Signatures: mt.SignatureStrs(),
+	})
+}
+
+type MultisigRequest struct {
+	Required                   *int     `json:"multisig_required"`
+	PublicKeys                 []string `json:"multisig_public_keys"`
+	RecipientBlockchainAddress *string  `json:"recipient_blockchain_address"`
+	Value                      *string  `json:"value"`
+}
+
+func (mr *MultisigRequest) ValidateAddress() bool {
+	if mr.Required == nil ||
+		*mr.Required <= 0 ||
+		*mr.Required > len(mr.PublicKeys) {
+		return false
+	}
+	for _, k := range mr.PublicKeys {
+		if len(k) != 128 {
+			return false
+		}
+	}
+	return true
+}
+
+func (mr *MultisigRequest) Validate() bool {
+	if !mr.ValidateAddress() ||
+		mr.RecipientBlockchainAddress == nil ||
+		mr.Value == nil {
+		return false
+	}
+	return true
+}
+
+func (mr *MultisigRequest) ParsePublicKeys() []*ecdsa.PublicKey {
+	publicKeys := make([]*ecdsa.PublicKey, len(mr.PublicKeys))
+	for i, k := range mr.PublicKeys {
+		publicKeys[i] = utils.PublicKeyFromString(k)
+	}
+	return publicKeys
+}
+
+type MultisigSignRequest struct {
+	Id               *string `json:"id"`
+	SignerPrivateKey *string `json:"signer_private_key"`
+	SignerPublicKey  *string `json:"signer_public_key"`
+}
+
+func (sr *MultisigSignRequest) Validate() bool {
+	if sr.Id == nil ||
+		sr.SignerPrivateKey == nil ||
+		sr.SignerPublicKey == nil ||
+		len(*sr.SignerPublicKey) != 128 {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,43 @@
+package wallet
+
+// NFT 발행 요청, content_hash가 없으면 content의 sha256을 사용
+type NFTMintRequest struct {
+	SenderPrivateKey        *string `json:"sender_private_key"`
+	SenderPublicKey         *string `json:"sender_public_key"`
+	SenderBlockchainAddress *string `json:"sender_blockchain_address"`
+	ContentHash             *string `json:"content_hash,omitempty"`
+	Content                 *string `json:"content,omitempty"`
+	Metadata                *string `json:"metadata"`
+}
+
+func (mr *NFTMintRequest) Validate() bool {
+	if mr.SenderPrivateKey == nil ||
+		mr.SenderPublicKey == nil ||
+		mr.SenderBlockchainAddress == nil ||
+		mr.Metadata == nil ||
+		(mr.ContentHash == nil && mr.Content == nil) ||
+		len(*mr.SenderPublicKey) != 128 {
+		return false
+	}
+	return true
+}
+
+type NFTTransferRequest struct {
+	SenderPrivateKey           *string `json:"sender_private_key"`
+	SenderPublicKey            *string `json:"sender_public_key"`
+	SenderBlockchainAddress    *string `json:"sender_blockchain_address"`
+	RecipientBlockchainAddress *string `json:"recipient_blockchain_address"`
+	Id                         *string `json:"id"`
+}
+
+func (tr *NFTTransferRequest) Validate() bool {
+	if tr.SenderPrivateKey == nil ||
+		tr.SenderPublicKey == nil ||
+		tr.SenderBlockchainAddress == nil ||
+		tr.RecipientBlockchainAddress == nil ||
+		tr.Id == nil ||
+		len(*tr.SenderPublicKey) != 128 {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,24 @@
+package wallet
+
+// 새 token 발행 또는 같은 token 추가 발행 요청
+type TokenIssueRequest struct {
+	SenderPrivateKey        *string `json:"sender_private_key"`
+	SenderPublicKey         *string `json:"sender_public_key"`
+	SenderBlockchainAddress *string `json:"sender_blockchain_address"`
+	Symbol                  *string `json:"symbol"`
+	Decimals                *string `json:"decimals"`
+	Supply                  *string `json:"supply"`
+}
+
+func (ir *TokenIssueRequest) Validate() bool {
+	if ir.SenderPrivateKey == nil ||
+		ir.SenderPublicKey == nil ||
+		ir.SenderBlockchainAddress == nil ||
+		ir.Symbol == nil ||
+		ir.Decimals == nil ||
+		ir.Supply == nil ||
+		len(*ir.SenderPublicKey) != 128 {
+		return false
+	}
+	return true
+}
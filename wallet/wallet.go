@@ -8,9 +8,9 @@ import (
 	"encoding/json"
 	"fmt"
 
-	"github.com/btcsuite/btcutil/base58"
+	"github.com/sw90lee/blockchain_study/token"
 	"github.com/sw90lee/blockchain_study/utils"
-	"golang.org/x/crypto/ripemd160"
+	"github.com/sw90lee/blockchain_study/vm"
 )
 
 // 지갑
@@ -26,36 +26,7 @@ func NewWallet() *Wallet {
 	privateKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	w.privateKey = privateKey
 	w.publicKey = &w.privateKey.PublicKey
-	// 2. Public Key 에 SHA-256을 수행 (32 byte)
-	h2 := sha256.New()
-	h2.Write(w.publicKey.X.Bytes())
-	h2.Write(w.publicKey.Y.Bytes())
-	digest2 := h2.Sum(nil)
-	// 3. SHA-256 결과에 대해 pipemd-160 Hash를 수행 (20 bytes)
-	h3 := ripemd160.New()
-	h3.Write(digest2)
-	digest3 := h3.Sum(nil)
-	// 4. RIPEMD-160 해시 앞에 버전 바이트 추가(메인 네트워크의 경우 0x00)
-	vd4 := make([]byte, 21)
-	vd4[0] = 0x00
-	copy(vd4[1:], digest3[:])
-	// 5. 확장된 ripemd160 결과에 대해 sha-256 해시 수행
-	h5 := sha256.New()
-	h5.Write(vd4)
-	digest5 := h5.Sum(nil)
-	// 6. 이전 SHA-256 해시의 결과에 대해 SHA-256 해시를 수행합니다.
-	h6 := sha256.New()
-	h6.Write(digest5)
-	digest6 := h6.Sum(nil)
-	// 7. 체크섬을 위해 두 번째 SHA-256 해시의 처음 4바이트를 가져옵니다.
-	chksum := digest6[:4]
-	// 8. 확장 RIPEMD-160 해시 끝에 7에서 4 체크섬 바이트를 4(25바이트)에서 더합니다.
-	dc8 := make([]byte, 25)
-	copy(dc8[:21], vd4[:])
-	copy(dc8[21:], chksum[:])
-	// 9. 결과를 바이트 문자열에서 base58로 변환합니다.
-	address := base58.Encode(dc8)
-	w.blockchainAddress = address
+	w.blockchainAddress = utils.BlockchainAddress(w.publicKey)
 	return w
 }
 
@@ -98,11 +69,47 @@ type Transaction struct {
 	senderBlockchainAddress    string
 	recipientBlockchainAddress string
 	value                      float32
+	lockTime                   int64
+	memo                       string
+	contract                   *vm.Payload
+	token                      *token.Payload
+	nft                        *token.NFTPayload
 }
 
 func NewTransaction(privateKey *ecdsa.PrivateKey, publicKey *ecdsa.PublicKey,
 	sender string, recipient string, value float32) *Transaction {
-	return &Transaction{privateKey, publicKey, sender, recipient, value}
+	return &Transaction{
+		senderPrivateKey:           privateKey,
+		senderPublicKey:            publicKey,
+		senderBlockchainAddress:    sender,
+		recipientBlockchainAddress: recipient,
+		value:                      value,
+	}
+}
+
+// 0이 아니면 해당 Block 높이 또는 unix time 이후에만 Block에 들어간다
+func (t *Transaction) SetLockTime(lockTime int64) {
+	t.lockTime = lockTime
+}
+
+// 서명에 포함되어 Block에 함께 저장된다
+func (t *Transaction) SetMemo(memo string) {
+	t.memo = memo
+}
+
+// contract 배포 또는 호출 내용
+func (t *Transaction) SetContract(contract *vm.Payload) {
+	t.contract = contract
+}
+
+// token 발행 또는 이동 내용
+func (t *Transaction) SetToken(token *token.Payload) {
+	t.token = token
+}
+
+// NFT 발행 또는 이동 내용
+func (t *Transaction) SetNFT(nft *token.NFTPayload) {
+	t.nft = nft
 }
 
 func (t *Transaction) GenerateSignature() *utils.Signature {
@@ -114,13 +121,23 @@ func (t *Transaction) GenerateSignature() *utils.Signature {
 
 func (t *Transaction) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
-		Sender    string  `json:"sender_blockchain_address"`
-		Recipient string  `json:"recipient_blockchain_address"`
-		Value     float32 `json:"value"`
+		Sender    string            `json:"sender_blockchain_address"`
+		Recipient string            `json:"recipient_blockchain_address"`
+		Value     float32           `json:"value"`
+		LockTime  int64             `json:"lock_time,omitempty"`
+		Memo      string            `json:"memo,omitempty"`
+		Contract  *vm.Payload       `json:"contract,omitempty"`
+		Token     *token.Payload    `json:"token,omitempty"`
+		NFT       *token.NFTPayload `json:"nft,omitempty"`
 	}{
 		Sender:    t.senderBlockchainAddress,
 		Recipient: t.recipientBlockchainAddress,
 		Value:     t.value,
+		LockTime:  t.lockTime,
+		Memo:      t.memo,
+		Contract:  t.contract,
+		Token:     t.token,
+		NFT:       t.nft,
 	})
 }
 
@@ -130,6 +147,10 @@ type TransactionRequest struct {
 	RecipientBlockchainAddress *string `json:"recipient_blockchain_address"`
 	SenderPublicKey            *string `json:"sender_public_key"`
 	Value                      *string `json:"value"`
+	LockTime                   *string `json:"lock_time,omitempty"`
+	Memo                       *string `json:"memo,omitempty"`
+	// 비어 있으면 기본 coin
+	Token *string `json:"token,omitempty"`
 }
 
 func (tr *TransactionRequest) Validate() bool {
@@ -1,8 +1,10 @@
 package main
 
 import (
-	"flag"
 	"log"
+	"os"
+
+	"github.com/sw90lee/blockchain_study/config"
 )
 
 func init() {
@@ -10,10 +12,11 @@ func init() {
 }
 
 func main() {
-	port := flag.Uint("port", 8080, "TCP port Wallet Server")
-	gateway := flag.String("gateway", "http://127.0.0.1:5000", "BlockChain gateway")
-	flag.Parse()
-
-	app := NewWalletServer(uint16(*port), *gateway)
+	cfg, err := config.LoadWallet(os.Args[1:])
+	if err != nil {
+		log.Fatalf("ERROR: invalid config: %v", err)
+	}
+	log.Printf("action=config, %s", cfg)
+	app := NewWalletServer(cfg)
 	app.Run()
 }
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sw90lee/blockchain_study/metrics"
+)
+
+var registry = metrics.NewRegistry()
+
+var (
+	requestsTotal = metrics.NewCounter(registry, "wallet_http_requests_total",
+		"HTTP requests handled by the wallet server", "path", "method", "code")
+	gatewayDuration = metrics.NewHistogram(registry, "wallet_gateway_request_duration_seconds",
+		"Duration of HTTP requests to the blockchain gateway", nil, "endpoint")
+	gatewayFailures = metrics.NewCounter(registry, "wallet_gateway_request_failures_total",
+		"Gateway requests that failed or returned a non-2xx status", "endpoint")
+)
+
+// 응답 코드를 기록하기 위한 ResponseWriter
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// 등록한 경로 단위로 요청 수를 센다
+func instrument(path string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler(rec, req)
+		requestsTotal.Inc(path, req.Method, strconv.Itoa(rec.status))
+	}
+}
+
+func gatewayDo(client *http.Client, req *http.Request) (*http.Response, error) {
+	endpoint := req.Method + " " + req.URL.Path
+	start := time.Now()
+	resp, err := client.Do(req)
+	gatewayDuration.Observe(time.Since(start).Seconds(), endpoint)
+	if err != nil || resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		gatewayFailures.Inc(endpoint)
+	}
+	return resp, err
+}
+
+func gatewayPost(url string, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest("POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return gatewayDo(http.DefaultClient, req)
+}
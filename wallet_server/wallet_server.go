@@ -2,6 +2,9 @@ package main
 
 import (
 	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"html/template"
@@ -10,29 +13,41 @@ import (
 	"net/http"
 	"path"
 	"strconv"
+	"sync"
 
 	"github.com/sw90lee/blockchain_study/block"
+	"github.com/sw90lee/blockchain_study/config"
+	"github.com/sw90lee/blockchain_study/metrics"
+	"github.com/sw90lee/blockchain_study/script"
+	"github.com/sw90lee/blockchain_study/token"
 	"github.com/sw90lee/blockchain_study/utils"
+	"github.com/sw90lee/blockchain_study/vm"
 	"github.com/sw90lee/blockchain_study/wallet"
 )
 
 const tempDir = "./template"
 
 type WalletServer struct {
-	port    uint16
-	gateway string
+	config *config.Wallet
+
+	// 서명이 모두 모이지 않은 다중서명 Transaction
+	multisigPool map[string]*wallet.MultisigTransaction
+	muxMultisig  sync.Mutex
 }
 
-func NewWalletServer(port uint16, gateway string) *WalletServer {
-	return &WalletServer{port, gateway}
+func NewWalletServer(config *config.Wallet) *WalletServer {
+	return &WalletServer{
+		config:       config,
+		multisigPool: make(map[string]*wallet.MultisigTransaction),
+	}
 }
 
 func (ws *WalletServer) Port() uint16 {
-	return ws.port
+	return ws.config.Port
 }
 
 func (ws *WalletServer) Gateway() string {
-	return ws.gateway
+	return ws.config.Gateway
 }
 
 func (ws *WalletServer) Index(w http.ResponseWriter, req *http.Request) {
@@ -84,24 +99,54 @@ func (ws *WalletServer) CreateTransaction(w http.ResponseWriter, req *http.Reque
 			return
 		}
 		value32 := float32(value)
+		var tokenPayload *token.Payload
+		if t.Token != nil && *t.Token != "" {
+			tokenPayload, err = ws.tokenTransfer(*t.Token, *t.Value)
+			if err != nil {
+				log.Printf("ERROR: %v", err)
+				io.WriteString(w, string(utils.JsonStatus("failed")))
+				return
+			}
+			value32 = 0
+		}
+		var lockTime int64
+		if t.LockTime != nil && *t.LockTime != "" {
+			lockTime, err = strconv.ParseInt(*t.LockTime, 10, 64)
+			if err != nil || lockTime < 0 {
+				log.Println("ERROR: parse error")
+				io.WriteString(w, string(utils.JsonStatus("failed")))
+				return
+			}
+		}
 
 		w.Header().Add("Content-Type", "application/json")
 
 		transaction := wallet.NewTransaction(privateKey, publicKey,
 			*t.SenderBlockchainAddress, *t.RecipientBlockchainAddress, value32)
+		transaction.SetLockTime(lockTime)
+		if t.Memo != nil {
+			transaction.SetMemo(*t.Memo)
+		}
+		transaction.SetToken(tokenPayload)
 		signature := transaction.GenerateSignature()
 		signatureStr := signature.String()
 
 		bt := &block.TransactionRequest{
-			t.SenderBlockchainAddress,
-			t.RecipientBlockchainAddress,
-			t.SenderPublicKey,
-			&value32, &signatureStr,
+			SenderBlockchainAddress:    t.SenderBlockchainAddress,
+			RecipientBlockchainAddress: t.RecipientBlockchainAddress,
+			SenderPublicKey:            t.SenderPublicKey,
+			Value:                      &value32,
+			Signature:                  &signatureStr,
+			Memo:                       t.Memo,
+			Token:                      tokenPayload,
+		}
+		if lockTime != 0 {
+			bt.LockTime = &lockTime
 		}
 		m, _ := json.Marshal(bt)
 		buf := bytes.NewBuffer(m)
 
-		resp, _ := http.Post(ws.Gateway()+"/transactions", "application/json", buf)
+		resp, _ := gatewayPost(ws.Gateway()+"/transactions", "application/json", buf)
 		if resp.StatusCode == 201 {
 			io.WriteString(w, string(utils.JsonStatus("success")))
 			return
@@ -125,7 +170,7 @@ func (ws *WalletServer) WalletAmount(w http.ResponseWriter, req *http.Request) {
 		q.Add("blockchain_address", blockchainAddress)
 		bcsReq.URL.RawQuery = q.Encode()
 
-		bcsResp, err := client.Do(bcsReq)
+		bcsResp, err := gatewayDo(client, bcsReq)
 		if err != nil {
 			log.Printf("ERROR: %v", err)
 			io.WriteString(w, string(utils.JsonStatus("fail")))
@@ -160,10 +205,777 @@ func (ws *WalletServer) WalletAmount(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+func (ws *WalletServer) MultisigAddress(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		decoder := json.NewDecoder(req.Body)
+		var mr wallet.MultisigRequest
+		err := decoder.Decode(&mr)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+		if !mr.ValidateAddress() {
+			log.Println("ERROR: missing field(s)")
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		m, _ := json.Marshal(struct {
+			Message           string `json:"message"`
+			BlockchainAddress string `json:"blockchain_address"`
+		}{
+			Message:           "success",
+			BlockchainAddress: utils.MultisigAddress(*mr.Required, mr.ParsePublicKeys()),
+		})
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+func (ws *WalletServer) MultisigTransaction(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		id := req.URL.Query().Get("id")
+		ws.muxMultisig.Lock()
+		mt, ok := ws.multisigPool[id]
+		ws.muxMultisig.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(utils.JsonStatus("not found")))
+			return
+		}
+		ws.writeMultisigTransaction(w, "success", id, mt)
+	case http.MethodPost:
+		decoder := json.NewDecoder(req.Body)
+		var mr wallet.MultisigRequest
+		err := decoder.Decode(&mr)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+		if !mr.Validate() {
+			log.Println("ERROR: missing field(s)")
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+		value, err := strconv.ParseFloat(*mr.Value, 32)
+		if err != nil {
+			log.Println("ERROR: parse error")
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+
+		mt := wallet.NewMultisigTransaction(*mr.Required, mr.ParsePublicKeys(),
+			*mr.RecipientBlockchainAddress, float32(value))
+		b := make([]byte, 16)
+		rand.Read(b)
+		id := fmt.Sprintf("%x", b)
+		ws.muxMultisig.Lock()
+		ws.multisigPool[id] = mt
+		ws.muxMultisig.Unlock()
+
+		w.WriteHeader(http.StatusCreated)
+		ws.writeMultisigTransaction(w, "success", id, mt)
+	case http.MethodPut:
+		decoder := json.NewDecoder(req.Body)
+		var sr wallet.MultisigSignRequest
+		err := decoder.Decode(&sr)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+		if !sr.Validate() {
+			log.Println("ERROR: missing field(s)")
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+
+		ws.muxMultisig.Lock()
+		defer ws.muxMultisig.Unlock()
+		mt, ok := ws.multisigPool[*sr.Id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(utils.JsonStatus("not found")))
+			return
+		}
+
+		publicKey := utils.PublicKeyFromString(*sr.SignerPublicKey)
+		privateKey := utils.PrivateKeyFromString(*sr.SignerPrivateKey, publicKey)
+		if !mt.Sign(privateKey) {
+			log.Println("ERROR: signer is not a key holder")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+		if !mt.IsComplete() {
+			ws.writeMultisigTransaction(w, "signed", *sr.Id, mt)
+			return
+		}
+
+		// 서명이 required개 모이면 Blockchain 노드로 전송
+		sender := mt.SenderBlockchainAddress()
+		recipient := mt.RecipientBlockchainAddress()
+		value := mt.Value()
+		required := mt.Required()
+		bt := &block.TransactionRequest{
+			SenderBlockchainAddress:    &sender,
+			RecipientBlockchainAddress: &recipient,
+			Value:                      &value,
+			MultisigRequired:           &required,
+			MultisigPublicKeys:         mt.PublicKeyStrs(),
+			Signatures:                 mt.SignatureStrs(),
+		}
+		m, _ := json.Marshal(bt)
+		buf := bytes.NewBuffer(m)
+
+		resp, err := gatewayPost(ws.Gateway()+"/transactions", "application/json", buf)
+		if err == nil && resp.StatusCode == 201 {
+			delete(ws.multisigPool, *sr.Id)
+			ws.writeMultisigTransaction(w, "success", *sr.Id, mt)
+			return
+		}
+		io.WriteString(w, string(utils.JsonStatus("failed")))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+func (ws *WalletServer) writeMultisigTransaction(w http.ResponseWriter, message string,
+	id string, mt *wallet.MultisigTransaction) {
+	m, _ := json.Marshal(struct {
+		Message        string                      `json:"message"`
+		Id             string                      `json:"id"`
+		SignatureCount int                         `json:"signature_count"`
+		Transaction    *wallet.MultisigTransaction `json:"transaction"`
+	}{
+		Message:        message,
+		Id:             id,
+		SignatureCount: mt.SignatureCount(),
+		Transaction:    mt,
+	})
+	io.WriteString(w, string(m[:]))
+}
+
+func (ws *WalletServer) HTLC(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		lockingScript, err := hex.DecodeString(req.URL.Query().Get("locking_script"))
+		if err != nil {
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+		htlc, ok := script.ParseHTLC(lockingScript)
+		if !ok {
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+		htlcAddress := script.Address(lockingScript)
+		amount, err := ws.fetchAmount(htlcAddress)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		m, _ := json.Marshal(struct {
+			Message            string  `json:"message"`
+			HTLCAddress        string  `json:"htlc_address"`
+			Hash               string  `json:"hash"`
+			RecipientPublicKey string  `json:"recipient_public_key"`
+			SenderPublicKey    string  `json:"sender_public_key"`
+			Timeout            int64   `json:"timeout"`
+			Amount             float32 `json:"amount"`
+		}{
+			Message:            "success",
+			HTLCAddress:        htlcAddress,
+			Hash:               hex.EncodeToString(htlc.Hash),
+			RecipientPublicKey: hex.EncodeToString(htlc.RecipientPublicKey),
+			SenderPublicKey:    hex.EncodeToString(htlc.SenderPublicKey),
+			Timeout:            htlc.Timeout,
+			Amount:             amount,
+		})
+		io.WriteString(w, string(m[:]))
+	case http.MethodPost:
+		decoder := json.NewDecoder(req.Body)
+		var hr wallet.HTLCRequest
+		err := decoder.Decode(&hr)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+		if !hr.Validate() {
+			log.Println("ERROR: missing field(s)")
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+		value, err := strconv.ParseFloat(*hr.Value, 32)
+		if err != nil {
+			log.Println("ERROR: parse error")
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+		timeout, err := strconv.ParseInt(*hr.Timeout, 10, 64)
+		if err != nil || timeout <= 0 {
+			log.Println("ERROR: parse error")
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+
+		var hash [32]byte
+		var preimage []byte
+		if hr.Hash != nil && *hr.Hash != "" {
+			h, err := hex.DecodeString(*hr.Hash)
+			if err != nil || len(h) != 32 {
+				log.Println("ERROR: invalid hash")
+				io.WriteString(w, string(utils.JsonStatus("failed")))
+				return
+			}
+			copy(hash[:], h)
+		} else {
+			preimage = make([]byte, 32)
+			rand.Read(preimage)
+			hash = sha256.Sum256(preimage)
+		}
+
+		publicKey := utils.PublicKeyFromString(*hr.SenderPublicKey)
+		privateKey := utils.PrivateKeyFromString(*hr.SenderPrivateKey, publicKey)
+		recipientPublicKey := utils.PublicKeyFromString(*hr.RecipientPublicKey)
+		lockingScript, err := script.HTLCScript(hash, recipientPublicKey, publicKey, timeout)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+
+		// HTLC 주소로 보내는 일반 Transaction
+		htlcAddress := script.Address(lockingScript)
+		value32 := float32(value)
+		signature := wallet.NewTransaction(privateKey, publicKey,
+			*hr.SenderBlockchainAddress, htlcAddress, value32).GenerateSignature()
+		signatureStr := signature.String()
+		bt := &block.TransactionRequest{
+			SenderBlockchainAddress:    hr.SenderBlockchainAddress,
+			RecipientBlockchainAddress: &htlcAddress,
+			SenderPublicKey:            hr.SenderPublicKey,
+			Value:                      &value32,
+			Signature:                  &signatureStr,
+		}
+		w.Header().Add("Content-Type", "application/json")
+		if !ws.postTransaction(bt) {
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+
+		m, _ := json.Marshal(struct {
+			Message       string `json:"message"`
+			HTLCAddress   string `json:"htlc_address"`
+			LockingScript string `json:"locking_script"`
+			Hash          string `json:"hash"`
+			Preimage      string `json:"preimage,omitempty"`
+			Timeout       int64  `json:"timeout"`
+		}{
+			Message:       "success",
+			HTLCAddress:   htlcAddress,
+			LockingScript: hex.EncodeToString(lockingScript),
+			Hash:          hex.EncodeToString(hash[:]),
+			Preimage:      hex.EncodeToString(preimage),
+			Timeout:       timeout,
+		})
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+func (ws *WalletServer) HTLCRedeem(w http.ResponseWriter, req *http.Request) {
+	ws.spendHTLC(w, req, true)
+}
+
+func (ws *WalletServer) HTLCRefund(w http.ResponseWriter, req *http.Request) {
+	ws.spendHTLC(w, req, false)
+}
+
+// HTLC 주소의 잔액을 모두 요청한 지갑으로 옮긴다
+func (ws *WalletServer) spendHTLC(w http.ResponseWriter, req *http.Request, redeem bool) {
+	switch req.Method {
+	case http.MethodPost:
+		decoder := json.NewDecoder(req.Body)
+		var sr wallet.HTLCSpendRequest
+		err := decoder.Decode(&sr)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+		if !sr.Validate() || (redeem && sr.Preimage == nil) {
+			log.Println("ERROR: missing field(s)")
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+		lockingScript, err := hex.DecodeString(*sr.LockingScript)
+		if err != nil {
+			log.Println("ERROR: parse error")
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+		htlc, ok := script.ParseHTLC(lockingScript)
+		if !ok {
+			log.Println("ERROR: not a htlc script")
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+
+		publicKey := utils.PublicKeyFromString(*sr.PublicKey)
+		privateKey := utils.PrivateKeyFromString(*sr.PrivateKey, publicKey)
+		expected := htlc.SenderPublicKey
+		if redeem {
+			expected = htlc.RecipientPublicKey
+		}
+		if hex.EncodeToString(expected) != *sr.PublicKey {
+			log.Println("ERROR: public key does not match htlc")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+
+		htlcAddress := script.Address(lockingScript)
+		amount, err := ws.fetchAmount(htlcAddress)
+		if err != nil || amount <= 0 {
+			log.Println("ERROR: htlc is empty")
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+
+		recipient := utils.BlockchainAddress(publicKey)
+		signature := wallet.NewTransaction(privateKey, publicKey,
+			htlcAddress, recipient, amount).GenerateSignature()
+		var unlockingScript []byte
+		if redeem {
+			preimage, err := hex.DecodeString(*sr.Preimage)
+			if err != nil {
+				log.Println("ERROR: parse error")
+				io.WriteString(w, string(utils.JsonStatus("failed")))
+				return
+			}
+			unlockingScript, err = script.HTLCRedeemScript(signature, preimage)
+			if err != nil {
+				log.Printf("ERROR: %v", err)
+				io.WriteString(w, string(utils.JsonStatus("failed")))
+				return
+			}
+		} else {
+			unlockingScript, _ = script.HTLCRefundScript(signature)
+		}
+
+		unlockingScriptStr := hex.EncodeToString(unlockingScript)
+		bt := &block.TransactionRequest{
+			SenderBlockchainAddress:    &htlcAddress,
+			RecipientBlockchainAddress: &recipient,
+			Value:                      &amount,
+			LockingScript:              sr.LockingScript,
+			UnlockingScript:            &unlockingScriptStr,
+		}
+		w.Header().Add("Content-Type", "application/json")
+		if ws.postTransaction(bt) {
+			io.WriteString(w, string(utils.JsonStatus("success")))
+			return
+		}
+		io.WriteString(w, string(utils.JsonStatus("failed")))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// contract 배포 (/contract) 또는 호출 (/contract/call)
+func (ws *WalletServer) Contract(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		var cr wallet.ContractRequest
+		if err := json.NewDecoder(req.Body).Decode(&cr); err != nil || !cr.Validate() {
+			log.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+		if req.URL.Path == "/contract/call" && cr.IsDeploy() {
+			log.Println("ERROR: missing contract address")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+
+		gasLimit, err1 := strconv.ParseUint(*cr.GasLimit, 10, 64)
+		gasPrice, err2 := strconv.ParseFloat(*cr.GasPrice, 32)
+		if err1 != nil || err2 != nil {
+			log.Println("ERROR: parse error")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+
+		var payload *vm.Payload
+		var recipient string
+		if cr.IsDeploy() {
+			var code []byte
+			var err error
+			if cr.Code != nil {
+				code, err = hex.DecodeString(*cr.Code)
+			} else {
+				code, err = vm.Assemble(*cr.Assembly)
+			}
+			if err != nil {
+				log.Printf("ERROR: %v", err)
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(utils.JsonStatus("failed")))
+				return
+			}
+			payload = vm.NewDeployPayload(code, gasLimit, float32(gasPrice))
+			recipient = block.CONTRACT_DEPLOY_RECIPIENT
+		} else {
+			input := make([][]byte, len(cr.Input))
+			for i, h := range cr.Input {
+				b, err := hex.DecodeString(h)
+				if err != nil {
+					log.Printf("ERROR: %v", err)
+					w.WriteHeader(http.StatusBadRequest)
+					io.WriteString(w, string(utils.JsonStatus("failed")))
+					return
+				}
+				input[i] = b
+			}
+			payload = vm.NewCallPayload(input, gasLimit, float32(gasPrice))
+			recipient = *cr.ContractAddress
+		}
+
+		publicKey := utils.PublicKeyFromString(*cr.SenderPublicKey)
+		privateKey := utils.PrivateKeyFromString(*cr.SenderPrivateKey, publicKey)
+		transaction := wallet.NewTransaction(privateKey, publicKey,
+			*cr.SenderBlockchainAddress, recipient, 0)
+		transaction.SetContract(payload)
+		signatureStr := transaction.GenerateSignature().String()
+
+		var value float32
+		bt := &block.TransactionRequest{
+			SenderBlockchainAddress:    cr.SenderBlockchainAddress,
+			RecipientBlockchainAddress: &recipient,
+			SenderPublicKey:            cr.SenderPublicKey,
+			Value:                      &value,
+			Signature:                  &signatureStr,
+			Contract:                   payload,
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		if ws.postTransaction(bt) {
+			io.WriteString(w, string(utils.JsonStatus("success")))
+			return
+		}
+		io.WriteString(w, string(utils.JsonStatus("failed")))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// gateway에서 decimals를 받아 token 금액을 가장 작은 단위로 변환
+func (ws *WalletServer) tokenTransfer(symbol string, value string) (*token.Payload, error) {
+	endpoint := fmt.Sprintf("%s/amount", ws.Gateway())
+	bcsReq, _ := http.NewRequest("GET", endpoint, nil)
+	q := bcsReq.URL.Query()
+	q.Add("token", symbol)
+	bcsReq.URL.RawQuery = q.Encode()
+
+	client := &http.Client{}
+	bcsResp, err := gatewayDo(client, bcsReq)
+	if err != nil {
+		return nil, err
+	}
+	if bcsResp.StatusCode != 200 {
+		return nil, token.ErrUnknownToken
+	}
+	var tar block.TokenAmountResponse
+	if err := json.NewDecoder(bcsResp.Body).Decode(&tar); err != nil {
+		return nil, err
+	}
+	amount, err := token.ParseAmount(value, tar.Decimals)
+	if err != nil {
+		return nil, err
+	}
+	return token.NewTransferPayload(symbol, amount), nil
+}
+
+// 발행한 token은 발행자 주소로 들어간다
+func (ws *WalletServer) TokenIssue(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		var ir wallet.TokenIssueRequest
+		if err := json.NewDecoder(req.Body).Decode(&ir); err != nil || !ir.Validate() {
+			log.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+		decimals, err := strconv.ParseUint(*ir.Decimals, 10, 8)
+		if err != nil || decimals > token.MAX_DECIMALS {
+			log.Println("ERROR: parse error")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+		supply, err := token.ParseAmount(*ir.Supply, uint8(decimals))
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+		payload := token.NewIssuePayload(*ir.Symbol, uint8(decimals), supply)
+		if err := payload.Validate(); err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+
+		publicKey := utils.PublicKeyFromString(*ir.SenderPublicKey)
+		privateKey := utils.PrivateKeyFromString(*ir.SenderPrivateKey, publicKey)
+		transaction := wallet.NewTransaction(privateKey, publicKey,
+			*ir.SenderBlockchainAddress, *ir.SenderBlockchainAddress, 0)
+		transaction.SetToken(payload)
+		signatureStr := transaction.GenerateSignature().String()
+
+		var value float32
+		bt := &block.TransactionRequest{
+			SenderBlockchainAddress:    ir.SenderBlockchainAddress,
+			RecipientBlockchainAddress: ir.SenderBlockchainAddress,
+			SenderPublicKey:            ir.SenderPublicKey,
+			Value:                      &value,
+			Signature:                  &signatureStr,
+			Token:                      payload,
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		if ws.postTransaction(bt) {
+			io.WriteString(w, string(utils.JsonStatus("success")))
+			return
+		}
+		io.WriteString(w, string(utils.JsonStatus("failed")))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 지갑 주소의 token별 잔액
+func (ws *WalletServer) WalletTokens(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		endpoint := fmt.Sprintf("%s/tokens", ws.Gateway())
+		bcsReq, _ := http.NewRequest("GET", endpoint, nil)
+		q := bcsReq.URL.Query()
+		q.Add("blockchain_address", req.URL.Query().Get("blockchain_address"))
+		bcsReq.URL.RawQuery = q.Encode()
+
+		client := &http.Client{}
+		bcsResp, err := gatewayDo(client, bcsReq)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		var tokens struct {
+			Tokens []*block.TokenResponse `json:"tokens"`
+		}
+		if err := json.NewDecoder(bcsResp.Body).Decode(&tokens); err != nil {
+			log.Printf("ERROR: %v", err)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+
+		m, _ := json.Marshal(struct {
+			Message string                 `json:"message"`
+			Tokens  []*block.TokenResponse `json:"tokens"`
+		}{
+			Message: "success",
+			Tokens:  tokens.Tokens,
+		})
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (ws *WalletServer) NFTMint(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		var mr wallet.NFTMintRequest
+		if err := json.NewDecoder(req.Body).Decode(&mr); err != nil || !mr.Validate() {
+			log.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+		var contentHash [32]byte
+		if mr.ContentHash != nil {
+			h, err := hex.DecodeString(*mr.ContentHash)
+			if err != nil || len(h) != 32 {
+				log.Println("ERROR: invalid content hash")
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(utils.JsonStatus("failed")))
+				return
+			}
+			copy(contentHash[:], h)
+		} else {
+			contentHash = sha256.Sum256([]byte(*mr.Content))
+		}
+
+		payload := token.NewMintPayload(contentHash, *mr.Metadata)
+		ok := ws.postNFTTransaction(*mr.SenderPrivateKey, *mr.SenderPublicKey,
+			*mr.SenderBlockchainAddress, *mr.SenderBlockchainAddress, payload)
+
+		w.Header().Add("Content-Type", "application/json")
+		if ok {
+			m, _ := json.Marshal(struct {
+				Message string `json:"message"`
+				Id      string `json:"id"`
+			}{
+				Message: "success",
+				Id:      payload.NFTId(*mr.SenderBlockchainAddress),
+			})
+			io.WriteString(w, string(m[:]))
+			return
+		}
+		io.WriteString(w, string(utils.JsonStatus("failed")))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+func (ws *WalletServer) NFTTransfer(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		var tr wallet.NFTTransferRequest
+		if err := json.NewDecoder(req.Body).Decode(&tr); err != nil || !tr.Validate() {
+			log.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("failed")))
+			return
+		}
+
+		payload := token.NewNFTTransferPayload(*tr.Id)
+		ok := ws.postNFTTransaction(*tr.SenderPrivateKey, *tr.SenderPublicKey,
+			*tr.SenderBlockchainAddress, *tr.RecipientBlockchainAddress, payload)
+
+		w.Header().Add("Content-Type", "application/json")
+		if ok {
+			io.WriteString(w, string(utils.JsonStatus("success")))
+			return
+		}
+		io.WriteString(w, string(utils.JsonStatus("failed")))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+func (ws *WalletServer) postNFTTransaction(privateKeyStr string, publicKeyStr string,
+	sender string, recipient string, payload *token.NFTPayload) bool {
+	if err := payload.Validate(); err != nil {
+		log.Printf("ERROR: %v", err)
+		return false
+	}
+	publicKey := utils.PublicKeyFromString(publicKeyStr)
+	privateKey := utils.PrivateKeyFromString(privateKeyStr, publicKey)
+	transaction := wallet.NewTransaction(privateKey, publicKey, sender, recipient, 0)
+	transaction.SetNFT(payload)
+	signatureStr := transaction.GenerateSignature().String()
+
+	var value float32
+	return ws.postTransaction(&block.TransactionRequest{
+		SenderBlockchainAddress:    &sender,
+		RecipientBlockchainAddress: &recipient,
+		SenderPublicKey:            &publicKeyStr,
+		Value:                      &value,
+		Signature:                  &signatureStr,
+		NFT:                        payload,
+	})
+}
+
+func (ws *WalletServer) postTransaction(bt *block.TransactionRequest) bool {
+	m, _ := json.Marshal(bt)
+	buf := bytes.NewBuffer(m)
+
+	resp, err := gatewayPost(ws.Gateway()+"/transactions", "application/json", buf)
+	if err != nil {
+		log.Printf("ERROR: %v", err)
+		return false
+	}
+	return resp.StatusCode == 201
+}
+
+func (ws *WalletServer) fetchAmount(blockchainAddress string) (float32, error) {
+	endpoint := fmt.Sprintf("%s/amount", ws.Gateway())
+	bcsReq, _ := http.NewRequest("GET", endpoint, nil)
+	q := bcsReq.URL.Query()
+	q.Add("blockchain_address", blockchainAddress)
+	bcsReq.URL.RawQuery = q.Encode()
+
+	client := &http.Client{}
+	bcsResp, err := gatewayDo(client, bcsReq)
+	if err != nil {
+		return 0, err
+	}
+	var bar block.AmountResponse
+	if err := json.NewDecoder(bcsResp.Body).Decode(&bar); err != nil {
+		return 0, err
+	}
+	return bar.Amount, nil
+}
+
+// 실행 중인 설정과 각 값을 정한 곳(default, file, env, flag)
+func (ws *WalletServer) AdminConfig(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		m, _ := json.Marshal(ws.config.Effective())
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
 func (ws *WalletServer) Run() {
-	http.HandleFunc("/", ws.Index)
-	http.HandleFunc("/wallet", ws.Wallet)
-	http.HandleFunc("/wallet/amount", ws.WalletAmount)
-	http.HandleFunc("/transaction", ws.CreateTransaction)
+	http.HandleFunc("/", instrument("/", ws.Index))
+	http.HandleFunc("/wallet", instrument("/wallet", ws.Wallet))
+	http.HandleFunc("/wallet/amount", instrument("/wallet/amount", ws.WalletAmount))
+	http.HandleFunc("/wallet/tokens", instrument("/wallet/tokens", ws.WalletTokens))
+	http.HandleFunc("/token/issue", instrument("/token/issue", ws.TokenIssue))
+	http.HandleFunc("/nft/mint", instrument("/nft/mint", ws.NFTMint))
+	http.HandleFunc("/nft/transfer", instrument("/nft/transfer", ws.NFTTransfer))
+	http.HandleFunc("/transaction", instrument("/transaction", ws.CreateTransaction))
+	http.HandleFunc("/multisig", instrument("/multisig", ws.MultisigAddress))
+	http.HandleFunc("/multisig/transaction", instrument("/multisig/transaction", ws.MultisigTransaction))
+	http.HandleFunc("/htlc", instrument("/htlc", ws.HTLC))
+	http.HandleFunc("/htlc/redeem", instrument("/htlc/redeem", ws.HTLCRedeem))
+	http.HandleFunc("/htlc/refund", instrument("/htlc/refund", ws.HTLCRefund))
+	http.HandleFunc("/contract", instrument("/contract", ws.Contract))
+	http.HandleFunc("/contract/call", instrument("/contract/call", ws.Contract))
+	http.HandleFunc("/metrics", metrics.Handler(registry))
+	http.HandleFunc("/admin/config", instrument("/admin/config", ws.AdminConfig))
 	log.Fatal(http.ListenAndServe("0.0.0.0:"+strconv.Itoa(int(ws.Port())), nil))
 }
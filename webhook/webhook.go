@@ -0,0 +1,495 @@
+// Package webhook 은 주소 활동을 등록된 callback URL로 알려준다.
+//
+// 감시하는 주소가 coin을 받으면 received, 그 Block 위로 정해진 수만큼
+// Block이 쌓이면 confirmed 알림을 보낸다. 알림 본문은 구독마다 만든
+// secret으로 HMAC-SHA256 서명하고, 실패하면 간격을 늘려가며 다시 보낸다.
+// 구독 목록과 confirmed를 기다리는 입금, 마지막으로 읽은 Block 높이는 파일에
+// 저장해서 node를 다시 시작해도 그 사이의 Block부터 이어서 알린다.
+// 아직 전달하지 못한 알림도 저장해서 다시 시작하면 남은 재시도를 이어서 보낸다.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sw90lee/blockchain_study/block"
+	"github.com/sw90lee/blockchain_study/clock"
+)
+
+const (
+	NOTIFICATION_RECEIVED  = "received"
+	NOTIFICATION_CONFIRMED = "confirmed"
+
+	MAX_ADDRESSES     = 100
+	MAX_CONFIRMATIONS = 100
+
+	DEFAULT_RETRIES     = 5
+	DEFAULT_BACKOFF     = time.Second
+	DELIVERY_TIMEOUT    = 10 * time.Second
+	SIGNATURE_HEADER    = "X-Webhook-Signature"
+	SUBSCRIPTION_HEADER = "X-Webhook-Subscription"
+)
+
+var (
+	ErrInvalidURL           = errors.New("invalid callback url")
+	ErrInvalidAddresses     = errors.New("invalid address list")
+	ErrInvalidConfirmations = errors.New("invalid confirmation threshold")
+)
+
+type Subscription struct {
+	Id            string   `json:"id"`
+	Url           string   `json:"url"`
+	Addresses     []string `json:"addresses"`
+	Confirmations int      `json:"confirmations"`
+	Secret        string   `json:"secret,omitempty"`
+}
+
+func (s *Subscription) watches(address string) bool {
+	for _, a := range s.Addresses {
+		if a == address {
+			return true
+		}
+	}
+	return false
+}
+
+// callback으로 보내는 본문
+type Notification struct {
+	Type            string             `json:"type"`
+	Subscription    string             `json:"subscription"`
+	Address         string             `json:"address"`
+	Height          int                `json:"height"`
+	BlockHash       string             `json:"block_hash"`
+	TransactionHash string             `json:"transaction_hash"`
+	Confirmations   int                `json:"confirmations"`
+	Transaction     *block.Transaction `json:"transaction"`
+	Time            int64              `json:"time"`
+}
+
+// confirmations에 닿기를 기다리는 입금
+type pending struct {
+	subscription string
+	notification *Notification
+}
+
+// 아직 전달하지 못한 알림, attempt는 지금까지 보낸 횟수
+type delivery struct {
+	subscription string
+	notification *Notification
+	attempt      int
+}
+
+// 파일에 저장하는 내용, 예전 파일은 구독 목록 배열만 있다
+type savedState struct {
+	Subscriptions []*Subscription  `json:"subscriptions"`
+	Pendings      []*savedPending  `json:"pendings,omitempty"`
+	Deliveries    []*savedDelivery `json:"deliveries,omitempty"`
+	Height        int              `json:"height"`
+	BlockHashes   map[int]string   `json:"block_hashes,omitempty"`
+}
+
+type savedPending struct {
+	Subscription string        `json:"subscription"`
+	Notification *Notification `json:"notification"`
+}
+
+type savedDelivery struct {
+	Subscription string        `json:"subscription"`
+	Notification *Notification `json:"notification"`
+	Attempt      int           `json:"attempt"`
+}
+
+type Manager struct {
+	bc   *block.Blockchain
+	path string
+
+	subscriptions map[string]*Subscription
+	pendings      []*pending
+	deliveries    []*delivery
+	// 알림을 만든 마지막 Block 높이, 아직 읽지 않았으면 -1
+	height int
+	// 최근 Block의 hash, 읽은 뒤에 chain이 바뀌었는지 확인한다
+	blockHashes map[int]string
+	mux         sync.Mutex
+
+	client  *http.Client
+	retries int
+	backoff time.Duration
+	clock   clock.Clock
+}
+
+// path가 비어 있으면 구독을 저장하지 않는다
+func NewManager(bc *block.Blockchain, path string) (*Manager, error) {
+	return NewManagerWithClock(bc, path, clock.System)
+}
+
+// 알림 시각과 재시도 간격을 c로 잰다
+func NewManagerWithClock(bc *block.Blockchain, path string, c clock.Clock) (*Manager, error) {
+	m := &Manager{
+		bc:            bc,
+		path:          path,
+		subscriptions: make(map[string]*Subscription),
+		height:        -1,
+		blockHashes:   make(map[int]string),
+		client:        &http.Client{Timeout: DELIVERY_TIMEOUT},
+		retries:       DEFAULT_RETRIES,
+		backoff:       DEFAULT_BACKOFF,
+		clock:         c,
+	}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// 실패한 전달을 retries번 더 시도하고, n번째 재시도 전에 backoff*2^n만큼 기다린다
+func (m *Manager) SetRetry(retries int, backoff time.Duration) {
+	m.retries = retries
+	m.backoff = backoff
+}
+
+func (m *Manager) Subscribe(callback string, addresses []string, confirmations int) (*Subscription, error) {
+	u, err := url.Parse(callback)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidURL
+	}
+	if len(addresses) == 0 || len(addresses) > MAX_ADDRESSES {
+		return nil, ErrInvalidAddresses
+	}
+	for _, a := range addresses {
+		if a == "" {
+			return nil, ErrInvalidAddresses
+		}
+	}
+	if confirmations < 1 || confirmations > MAX_CONFIRMATIONS {
+		return nil, ErrInvalidConfirmations
+	}
+
+	s := &Subscription{
+		Id:            randomHex(16),
+		Url:           callback,
+		Addresses:     addresses,
+		Confirmations: confirmations,
+		Secret:        randomHex(32),
+	}
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.subscriptions[s.Id] = s
+	if err := m.save(); err != nil {
+		delete(m.subscriptions, s.Id)
+		return nil, err
+	}
+	return s, nil
+}
+
+func (m *Manager) Unsubscribe(id string) bool {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if _, ok := m.subscriptions[id]; !ok {
+		return false
+	}
+	delete(m.subscriptions, id)
+	if err := m.save(); err != nil {
+		log.Printf("ERROR: %v", err)
+	}
+	return true
+}
+
+// secret을 뺀 구독 목록
+func (m *Manager) Subscriptions() []*Subscription {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	subscriptions := []*Subscription{}
+	for _, s := range m.subscriptions {
+		c := *s
+		c.Secret = ""
+		subscriptions = append(subscriptions, &c)
+	}
+	return subscriptions
+}
+
+// Blockchain의 event를 받아서 알림을 보내기 시작한다
+// 구독 channel은 밀리면 event를 버리므로 event는 깨우는 데만 쓰고 Block은 chain에서 읽는다
+// 저장해 둔 전달하지 못한 알림은 바로 다시 보낸다
+func (m *Manager) Run() {
+	_, events := m.bc.Subscribe()
+	m.mux.Lock()
+	for _, d := range m.deliveries {
+		go m.attempt(d)
+	}
+	m.mux.Unlock()
+	m.catchUp()
+	go func() {
+		for range events {
+			m.catchUp()
+		}
+	}()
+}
+
+// 마지막으로 읽은 높이 다음부터 tip까지 알림을 만든다
+func (m *Manager) catchUp() {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	chain := m.bc.Chain()
+	if m.height < 0 {
+		// 처음 실행하면 지금 tip부터 시작한다
+		m.remember(chain, len(chain)-1)
+		return
+	}
+	// 읽은 뒤에 바뀐 Block이 있으면 그 높이부터 다시 읽는다
+	fork := m.height + 1
+	for fork > 0 {
+		hash, ok := m.blockHashes[fork-1]
+		if !ok || (fork-1 < len(chain) && hash == fmt.Sprintf("%x", chain[fork-1].Hash())) {
+			break
+		}
+		fork -= 1
+	}
+	if fork >= len(chain) {
+		return
+	}
+	if fork <= m.height {
+		// 버려진 Block의 입금은 더 기다리지 않는다
+		pendings := m.pendings[:0]
+		for _, p := range m.pendings {
+			if p.notification.Height < fork {
+				pendings = append(pendings, p)
+			}
+		}
+		m.pendings = pendings
+	}
+	for height := fork; height < len(chain); height++ {
+		m.received(chain[height], height)
+		m.confirm(height)
+	}
+	m.remember(chain, fork)
+}
+
+// from 높이부터 tip까지 읽었다고 기록하고 저장한다
+// reorg는 MaxReorgDepth보다 깊지 않으므로 그만큼의 hash만 남긴다
+func (m *Manager) remember(chain []*block.Block, from int) {
+	m.height = len(chain) - 1
+	oldest := m.height - m.bc.Params().MaxReorgDepth
+	for height := range m.blockHashes {
+		if height >= from || height < oldest {
+			delete(m.blockHashes, height)
+		}
+	}
+	for height := from; height < len(chain); height++ {
+		if height >= oldest {
+			m.blockHashes[height] = fmt.Sprintf("%x", chain[height].Hash())
+		}
+	}
+	if err := m.save(); err != nil {
+		log.Printf("ERROR: %v", err)
+	}
+}
+
+// body를 버린 Block은 건너뛴다
+func (m *Manager) received(b *block.Block, height int) {
+	blockHash := fmt.Sprintf("%x", b.Hash())
+	for _, t := range b.Transaction() {
+		for _, s := range m.subscriptions {
+			if !s.watches(t.Recipient()) {
+				continue
+			}
+			n := &Notification{
+				Type:            NOTIFICATION_RECEIVED,
+				Subscription:    s.Id,
+				Address:         t.Recipient(),
+				Height:          height,
+				BlockHash:       blockHash,
+				TransactionHash: fmt.Sprintf("%x", t.Hash()),
+				Confirmations:   1,
+				Transaction:     t,
+			}
+			m.deliver(s, n)
+			m.pendings = append(m.pendings, &pending{subscription: s.Id, notification: n})
+		}
+	}
+}
+
+// height가 tip일 때 confirmations에 닿은 입금을 알린다
+func (m *Manager) confirm(height int) {
+	pendings := m.pendings[:0]
+	for _, p := range m.pendings {
+		s, ok := m.subscriptions[p.subscription]
+		if !ok {
+			continue
+		}
+		confirmations := height - p.notification.Height + 1
+		if confirmations < s.Confirmations {
+			pendings = append(pendings, p)
+			continue
+		}
+		n := *p.notification
+		n.Type = NOTIFICATION_CONFIRMED
+		n.Confirmations = confirmations
+		m.deliver(s, &n)
+	}
+	m.pendings = pendings
+}
+
+// 보내기 전에 deliveries에 넣어 두고, 호출한 쪽이 저장한다
+func (m *Manager) deliver(s *Subscription, n *Notification) {
+	n.Time = m.clock.Now().UnixNano()
+	d := &delivery{subscription: s.Id, notification: n}
+	m.deliveries = append(m.deliveries, d)
+	go m.attempt(d)
+}
+
+// 실패하면 다시 시작해도 이어서 보내도록 저장한 뒤 backoff*2^n만큼 기다린다
+func (m *Manager) attempt(d *delivery) {
+	m.mux.Lock()
+	s, ok := m.subscriptions[d.subscription]
+	if !ok {
+		m.forget(d)
+		m.mux.Unlock()
+		return
+	}
+	m.mux.Unlock()
+
+	body, _ := json.Marshal(d.notification)
+	err := m.post(s, body)
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	d.attempt += 1
+	if err == nil {
+		log.Printf("action=webhook, subscription=%s, type=%s, status=success", s.Id, d.notification.Type)
+		m.forget(d)
+		return
+	}
+	log.Printf("ERROR: webhook %s attempt %d: %v", s.Id, d.attempt, err)
+	if d.attempt > m.retries {
+		m.forget(d)
+		return
+	}
+	if err := m.save(); err != nil {
+		log.Printf("ERROR: %v", err)
+	}
+	m.clock.AfterFunc(m.backoff<<uint(d.attempt-1), func() { m.attempt(d) })
+}
+
+// 끝난 전달을 지우고 저장한다
+func (m *Manager) forget(d *delivery) {
+	deliveries := m.deliveries[:0]
+	for _, other := range m.deliveries {
+		if other != d {
+			deliveries = append(deliveries, other)
+		}
+	}
+	m.deliveries = deliveries
+	if err := m.save(); err != nil {
+		log.Printf("ERROR: %v", err)
+	}
+}
+
+func (m *Manager) post(s *Subscription, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, s.Url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SUBSCRIPTION_HEADER, s.Id)
+	req.Header.Set(SIGNATURE_HEADER, "sha256="+Sign(s.Secret, body))
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// 받는 쪽은 같은 secret으로 본문을 서명해서 SIGNATURE_HEADER와 비교한다
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (m *Manager) load() error {
+	if m.path == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(m.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	state := savedState{Height: -1}
+	if data = bytes.TrimSpace(data); len(data) > 0 && data[0] == '[' {
+		err = json.Unmarshal(data, &state.Subscriptions)
+	} else {
+		err = json.Unmarshal(data, &state)
+	}
+	if err != nil {
+		return err
+	}
+	for _, s := range state.Subscriptions {
+		m.subscriptions[s.Id] = s
+	}
+	for _, p := range state.Pendings {
+		m.pendings = append(m.pendings, &pending{subscription: p.Subscription, notification: p.Notification})
+	}
+	for _, d := range state.Deliveries {
+		m.deliveries = append(m.deliveries, &delivery{subscription: d.Subscription, notification: d.Notification, attempt: d.Attempt})
+	}
+	m.height = state.Height
+	for height, hash := range state.BlockHashes {
+		m.blockHashes[height] = hash
+	}
+	return nil
+}
+
+// 중간에 멈춰도 파일이 깨지지 않도록 임시 파일에 쓰고 바꾼다
+func (m *Manager) save() error {
+	if m.path == "" {
+		return nil
+	}
+	state := &savedState{
+		Subscriptions: make([]*Subscription, 0, len(m.subscriptions)),
+		Height:        m.height,
+		BlockHashes:   m.blockHashes,
+	}
+	for _, s := range m.subscriptions {
+		state.Subscriptions = append(state.Subscriptions, s)
+	}
+	for _, p := range m.pendings {
+		state.Pendings = append(state.Pendings, &savedPending{Subscription: p.subscription, Notification: p.notification})
+	}
+	for _, d := range m.deliveries {
+		state.Deliveries = append(state.Deliveries, &savedDelivery{Subscription: d.subscription, Notification: d.notification, Attempt: d.attempt})
+	}
+	data, _ := json.MarshalIndent(state, "", "  ")
+	tmp := m.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
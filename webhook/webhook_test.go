@@ -0,0 +1,262 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sw90lee/blockchain_study/block"
+	"github.com/sw90lee/blockchain_study/clock"
+)
+
+// 받은 알림을 서명 확인 후 channel로 넘기는 callback 대상
+// 처음 failures번은 500으로 응답해서 재시도를 확인한다
+func receiver(t *testing.T, secret *string, failures int) (*httptest.Server, chan *Notification) {
+	notifications := make(chan *Notification, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get(SIGNATURE_HEADER) != "sha256="+Sign(*secret, body) {
+			t.Errorf("invalid signature")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var n Notification
+		if err := json.Unmarshal(body, &n); err != nil {
+			t.Errorf("invalid body: %v", err)
+		}
+		notifications <- &n
+	}))
+	return server, notifications
+}
+
+func fund(t *testing.T, bc *block.Blockchain, address string, value float32) {
+	bc.AddTransaction(block.NewTransaction(block.MINING_SENDER, address, value), nil, nil)
+	if !bc.Mining() {
+		t.Fatal("mining failed")
+	}
+}
+
+func next(t *testing.T, notifications chan *Notification) *Notification {
+	select {
+	case n := <-notifications:
+		return n
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification")
+	}
+	return nil
+}
+
+// 보내던 알림이 모두 끝나 파일에 저장될 때까지 기다린다
+func settle(t *testing.T, m *Manager) {
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		m.mux.Lock()
+		deliveries := len(m.deliveries)
+		m.mux.Unlock()
+		if deliveries == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d deliveries left", deliveries)
+		}
+	}
+}
+
+func TestReceivedAndConfirmed(t *testing.T) {
+	bc := block.NewBlockchain("miner", 0)
+	m, err := NewManager(bc, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.SetRetry(3, 10*time.Millisecond)
+	m.Run()
+
+	var secret string
+	server, notifications := receiver(t, &secret, 2)
+	defer server.Close()
+	s, err := m.Subscribe(server.URL, []string{"alice"}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret = s.Secret
+
+	fund(t, bc, "alice", 5)
+	n := next(t, notifications)
+	if n.Type != NOTIFICATION_RECEIVED || n.Address != "alice" || n.Height != 1 || n.Confirmations != 1 {
+		t.Fatalf("unexpected notification %+v", n)
+	}
+
+	fund(t, bc, "bob", 1)
+	fund(t, bc, "bob", 1)
+	n = next(t, notifications)
+	if n.Type != NOTIFICATION_CONFIRMED || n.Height != 1 || n.Confirmations != 3 {
+		t.Fatalf("unexpected notification %+v", n)
+	}
+}
+
+func TestSubscriptionsPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	bc := block.NewBlockchain("miner", 0)
+	m, _ := NewManager(bc, path)
+	s, err := m.Subscribe("http://127.0.0.1:1/hook", []string{"alice"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restarted, err := NewManager(bc, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subscriptions := restarted.Subscriptions()
+	if len(subscriptions) != 1 || subscriptions[0].Id != s.Id || subscriptions[0].Secret != "" {
+		t.Fatalf("unexpected subscriptions %+v", subscriptions)
+	}
+	if restarted.subscriptions[s.Id].Secret != s.Secret {
+		t.Fatal("secret not kept")
+	}
+
+	restarted.Unsubscribe(s.Id)
+	again, _ := NewManager(bc, path)
+	if len(again.Subscriptions()) != 0 {
+		t.Fatal("unsubscribe not saved")
+	}
+}
+
+func TestSubscribeValidation(t *testing.T) {
+	m, _ := NewManager(block.NewBlockchain("miner", 0), "")
+	if _, err := m.Subscribe("ftp://example.com", []string{"alice"}, 1); err != ErrInvalidURL {
+		t.Fatal(err)
+	}
+	if _, err := m.Subscribe("http://example.com", nil, 1); err != ErrInvalidAddresses {
+		t.Fatal(err)
+	}
+	if _, err := m.Subscribe("http://example.com", []string{"alice"}, 0); err != ErrInvalidConfirmations {
+		t.Fatal(err)
+	}
+}
+
+// 구독 channel이 넘쳐 event가 버려져도 chain에서 Block을 읽어 알린다
+func TestCatchUpAfterDroppedEvents(t *testing.T) {
+	bc := block.NewBlockchain("miner", 0)
+	p := block.DefaultParams()
+	p.MiningDifficulty = 1
+	bc.SetParams(p)
+	m, _ := NewManager(bc, "")
+	m.Run()
+
+	var secret string
+	server, notifications := receiver(t, &secret, 0)
+	defer server.Close()
+	s, _ := m.Subscribe(server.URL, []string{"alice"}, 1)
+	secret = s.Secret
+
+	// 알림을 만드는 goroutine을 막아 두고 buffer보다 많은 Block을 만든다
+	m.mux.Lock()
+	for i := 0; i < block.EVENT_BUFFER_SIZE+5; i++ {
+		fund(t, bc, "bob", 1)
+	}
+	m.mux.Unlock()
+	fund(t, bc, "alice", 5)
+
+	received := map[string]int{}
+	for i := 0; i < 2; i++ {
+		n := next(t, notifications)
+		received[n.Type] = n.Height
+	}
+	height := block.EVENT_BUFFER_SIZE + 6
+	if received[NOTIFICATION_RECEIVED] != height || received[NOTIFICATION_CONFIRMED] != height {
+		t.Fatalf("unexpected notifications %v", received)
+	}
+}
+
+// 다시 시작해도 기다리던 입금과 그 사이에 쌓인 Block을 이어서 처리한다
+func TestPendingsPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	bc := block.NewBlockchain("miner", 0)
+	var secret string
+	server, notifications := receiver(t, &secret, 0)
+	defer server.Close()
+
+	m, _ := NewManager(bc, path)
+	s, _ := m.Subscribe(server.URL, []string{"alice"}, 3)
+	secret = s.Secret
+	m.catchUp()
+	fund(t, bc, "alice", 5)
+	m.catchUp()
+	if n := next(t, notifications); n.Type != NOTIFICATION_RECEIVED || n.Height != 1 {
+		t.Fatalf("unexpected notification %+v", n)
+	}
+	settle(t, m)
+
+	// 멈춘 동안 만들어진 Block
+	fund(t, bc, "bob", 1)
+	fund(t, bc, "alice", 2)
+	restarted, err := NewManager(bc, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restarted.pendings) != 1 || restarted.height != 1 {
+		t.Fatalf("unexpected state %d %d", len(restarted.pendings), restarted.height)
+	}
+	restarted.catchUp()
+	received := map[string]int{}
+	for i := 0; i < 2; i++ {
+		n := next(t, notifications)
+		received[n.Type] = n.Height
+	}
+	if received[NOTIFICATION_RECEIVED] != 3 || received[NOTIFICATION_CONFIRMED] != 1 {
+		t.Fatalf("unexpected notifications %v", received)
+	}
+	settle(t, restarted)
+}
+
+// 재시도를 기다리는 중에 멈춰도 다시 시작하면 처음 만든 알림을 이어서 보낸다
+func TestDeliveriesPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := clock.NewFake(start)
+	bc := block.NewBlockchainWithClock("miner", 0, c)
+	var secret string
+	server, notifications := receiver(t, &secret, 1)
+	defer server.Close()
+
+	// Fake 시계를 움직이지 않으므로 첫 전달이 실패하면 재시도를 기다린 채로 남는다
+	m, _ := NewManagerWithClock(bc, path, c)
+	m.SetRetry(3, time.Second)
+	s, _ := m.Subscribe(server.URL, []string{"alice"}, 3)
+	secret = s.Secret
+	m.catchUp()
+	fund(t, bc, "alice", 5)
+	m.catchUp()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		saved, _ := NewManager(bc, path)
+		if len(saved.deliveries) == 1 && saved.deliveries[0].attempt == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("retry not saved")
+		}
+	}
+
+	restarted, err := NewManagerWithClock(bc, path, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted.Run()
+	n := next(t, notifications)
+	if n.Type != NOTIFICATION_RECEIVED || n.Height != 1 || n.Time != start.UnixNano() {
+		t.Fatalf("unexpected notification %+v", n)
+	}
+	settle(t, restarted)
+	if again, _ := NewManager(bc, path); len(again.deliveries) != 0 {
+		t.Fatal("delivered notification still saved")
+	}
+}